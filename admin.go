@@ -0,0 +1,64 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// ErrAdminCommandsDisabled is returned by admin commands, like CompactServerMemberCollection, unless
+// Config.AdminCommandsEnabled is set.
+var ErrAdminCommandsDisabled = errors.New("admin commands are disabled")
+
+// CollStats is the result of the collStats command, as returned by ServerMemberCollectionStats and the
+// like. Sizes are in bytes.
+type CollStats struct {
+  Namespace      string           `bson:"ns"             json:"ns"`
+  Count          int64            `bson:"count"          json:"count"`
+  Size           int64            `bson:"size"           json:"size"`
+  AvgObjSize     int64            `bson:"avgObjSize"     json:"avgObjSize"`
+  StorageSize    int64            `bson:"storageSize"    json:"storageSize"`
+  NIndexes       int              `bson:"nindexes"       json:"nindexes"`
+  TotalIndexSize int64            `bson:"totalIndexSize" json:"totalIndexSize"`
+  IndexSizes     map[string]int64 `bson:"indexSizes"     json:"indexSizes"`
+  // Raw holds the rest of the stats document, which varies by storage engine.
+  Raw            bson.M           `bson:",inline"        json:"raw"`
+}
+
+// compactCollection runs the compact command against the model's collection.
+func compactCollection(ctx context.Context, model, client, database, collection string) (err error) {
+
+  defer observeOperation(model, "Compact", time.Now(), &err)
+
+  if !getConfig().AdminCommandsEnabled {
+    return ErrAdminCommandsDisabled
+  }
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Database.Run(bson.D{{Name: "compact", Value: collection}}, nil)
+  })
+  return wrapDBError(model, "Compact", "", "", err)
+}
+
+// collectionStats runs the collStats command against the model's collection.
+func collectionStats(ctx context.Context, model, client, database, collection string) (_ *CollStats, err error) {
+
+  defer observeOperation(model, "CollectionStats", time.Now(), &err)
+
+  if !getConfig().AdminCommandsEnabled {
+    return nil, ErrAdminCommandsDisabled
+  }
+  stats := new(CollStats)
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Database.Run(bson.D{{Name: "collStats", Value: collection}}, stats)
+  })
+  if err != nil {
+    return nil, wrapDBError(model, "CollectionStats", "", "", err)
+  }
+  return stats, nil
+}
@@ -0,0 +1,126 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "encoding/json"
+  "reflect"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// serializeForAPI encodes the struct pointed to by doc as JSON for API responses. ObjectIds are rendered
+// as hex strings and times as RFC3339, embeddables which weren't loaded are omitted, and fields tagged
+// json_api:"-" are left out.
+func serializeForAPI(doc interface{}) ([]byte, error) {
+
+  return json.Marshal(apiValue(reflect.ValueOf(doc)))
+}
+
+// apiValue converts v into plain values for serializeForAPI, recursing into structs, maps, and slices.
+func apiValue(v reflect.Value) interface{} {
+
+  switch v.Kind() {
+  case reflect.Ptr, reflect.Interface:
+    if v.IsNil() {
+      return nil
+    }
+    return apiValue(v.Elem())
+  case reflect.Struct:
+    if v.Type() == timeType {
+      return v.Interface().(time.Time).Format(time.RFC3339)
+    }
+    out := map[string]interface{}{}
+    for i := 0; i < v.NumField(); i++ {
+      field := v.Type().Field(i)
+      name := apiFieldName(field)
+      if name == "" {
+        continue
+      }
+      if _, embeddable := bsonFieldName(field); embeddable && isEmptyEmbeddable(v.Field(i)) {
+        continue
+      }
+      out[name] = apiValue(v.Field(i))
+    }
+    return out
+  case reflect.Slice:
+    if v.IsNil() {
+      return nil
+    }
+    out := make([]interface{}, v.Len())
+    for i := 0; i < v.Len(); i++ {
+      out[i] = apiValue(v.Index(i))
+    }
+    return out
+  case reflect.Map:
+    if v.IsNil() {
+      return nil
+    }
+    out := make(map[string]interface{}, v.Len())
+    for _, key := range v.MapKeys() {
+      out[key.String()] = apiValue(v.MapIndex(key))
+    }
+    return out
+  default:
+    if id, ok := v.Interface().(bson.ObjectId); ok {
+      return id.Hex()
+    }
+    return v.Interface()
+  }
+}
+
+// isEmptyEmbeddable reports whether the embeddable wasn't loaded: a nil pointer or an empty slice.
+func isEmptyEmbeddable(v reflect.Value) bool {
+
+  switch v.Kind() {
+  case reflect.Ptr:
+    return v.IsNil()
+  case reflect.Slice:
+    return v.Len() == 0
+  default:
+    return false
+  }
+}
+
+// apiFieldName returns the field's JSON key in API payloads. An empty name means the field is left out,
+// either because it's unexported or tagged json:"-" or json_api:"-".
+func apiFieldName(field reflect.StructField) string {
+
+  if field.PkgPath != "" || field.Tag.Get("json_api") == "-" {
+    return ""
+  }
+  name := strings.Split(field.Tag.Get("json"), ",")[0]
+  if name == "-" {
+    return ""
+  }
+  if name == "" {
+    name = field.Name
+  }
+  return name
+}
+
+// deserializeFromAPI decodes an API payload into the struct pointed to by doc, using the same keys as
+// serializeForAPI. Keys missing from the payload leave their fields as they are, and fields tagged
+// json_api:"-" are never set.
+func deserializeFromAPI(doc interface{}, b []byte) error {
+
+  payload := map[string]json.RawMessage{}
+  if err := json.Unmarshal(b, &payload); err != nil {
+    return err
+  }
+  v := reflect.ValueOf(doc).Elem()
+  for i := 0; i < v.NumField(); i++ {
+    name := apiFieldName(v.Type().Field(i))
+    raw, ok := payload[name]
+    if name == "" || !ok {
+      continue
+    }
+    if err := json.Unmarshal(raw, v.Field(i).Addr().Interface()); err != nil {
+      return err
+    }
+  }
+  return nil
+}
@@ -0,0 +1,24 @@
+//go:build test
+// +build test
+
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "testing"
+)
+
+// AssertModelsEqual fails the test with an error for each field differing between the documents, as
+// found by CompareModels. Only built with the test build tag.
+func AssertModelsEqual(t *testing.T, expected, actual interface{}) {
+
+  t.Helper()
+  for _, diff := range CompareModels(expected, actual) {
+    if diff.Field == "" {
+      t.Errorf("expected %#v, got %#v", diff.Expected, diff.Actual)
+      continue
+    }
+    t.Errorf("%s: expected %#v, got %#v", diff.Field, diff.Expected, diff.Actual)
+  }
+}
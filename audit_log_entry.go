@@ -0,0 +1,308 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// AuditLogEntryClientName is the name of the MgoDriver to use for AuditLogEntry.
+const AuditLogEntryClientName = "main"
+
+// AuditLogEntryDBName is the name of the database to use for AuditLogEntry.
+const AuditLogEntryDBName = "badpetbot"
+
+// AuditLogEntryColName is the name of the collection to use for AuditLogEntry.
+const AuditLogEntryColName = "audit_log_entries"
+
+// AuditLogEntryModelVersion is the current schema version of AuditLogEntry documents, stored in their ModelVersion.
+const AuditLogEntryModelVersion = 1
+
+// Audit operations, used for AuditLogEntry.Operation.
+const (
+  AuditOperationCreate = "create"
+  AuditOperationUpdate = "update"
+  AuditOperationDelete = "delete"
+)
+
+// AuditLogEntryCol gets a collection reference for AuditLogEntry.
+func AuditLogEntryCol() *mgo.Collection {
+  return net.MgoCol(AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName)
+}
+
+// Register AuditLogEntry, so tooling can find it.
+func init() {
+  RegisterModel("AuditLogEntry", AuditLogEntryCol, func() interface{} { return new(AuditLogEntry) })
+}
+
+// EnsureAuditLogEntryIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureAuditLogEntryIndices() error {
+
+  col := AuditLogEntryCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"target_model", "target_id", "-created_at"}, Background: true}); err != nil {
+    return wrapDBError("AuditLogEntry", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"actor_discord_id", "created_at"}, Background: true}); err != nil {
+    return wrapDBError("AuditLogEntry", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { target_model: 1, target_id: 1, created_at: -1 }
+// { actor_discord_id: 1, created_at: 1 }
+
+// AuditLogEntry is an immutable record of a mutation made to another document. The audit log is
+// append-only: entries are written with RecordAuditEntry and never updated or deleted.
+type AuditLogEntry struct {
+  // ID is a BSON ID generated in RecordAuditEntry.
+  ID              bson.ObjectId   `bson:"_id"                json:"_id"                validate:"required"`
+  ActorDiscordID  string          `bson:"actor_discord_id"   json:"actor_discord_id"   validate:"required,discord_id"`
+  TargetModel     string          `bson:"target_model"       json:"target_model"       validate:"required"`
+  TargetID        bson.ObjectId   `bson:"target_id"          json:"target_id"          validate:"required"`
+  Operation       string          `bson:"operation"          json:"operation"          validate:"oneof=create update delete"`
+  // Before and After are the target's fields before and after the mutation. Before is nil for
+  // creates, and After is nil for deletes.
+  Before          bson.M          `bson:"before"             json:"before"             validate:"-"`
+  After           bson.M          `bson:"after"              json:"after"              validate:"-"`
+  DiscordServerID string          `bson:"discord_server_id"  json:"discord_server_id"  validate:"omitempty,discord_id"`
+  CreatedAt       time.Time       `bson:"created_at"         json:"created_at"         validate:"required"`
+  ModelVersion    int             `bson:"model_version"      json:"model_version"      validate:"-"`
+}
+
+// RecordAuditEntry persists the entry in the audit log. It is the only way to write to the audit log.
+// The entry's ID and timestamp are always generated here.
+func RecordAuditEntry(entry *AuditLogEntry) (err error) {
+
+  ctx, span := startSpan(context.Background(), "AuditLogEntry.Record", AuditLogEntryDBName, AuditLogEntryColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("AuditLogEntry", "Record", time.Now(), &err)
+
+  // Ensure ID, timestamp, and model version.
+  entry.ID = bson.NewObjectId()
+  entry.CreatedAt = clockNow()
+  entry.ModelVersion = AuditLogEntryModelVersion
+
+  // Run validations and return if they fail.
+  if err := entry.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the AuditLogEntry.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, func(col *mgo.Collection) error {
+      return col.Insert(entry)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("AuditLogEntry", "Record", "_id", entry.ID.Hex(), err)
+}
+
+// Validate runs validations against the model's fields.
+func (this *AuditLogEntry) Validate(ctx context.Context) error {
+
+  return wrapValidationError("AuditLogEntry", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *AuditLogEntry) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("AuditLogEntry", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("AuditLogEntry", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *AuditLogEntry) Clone() *AuditLogEntry {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*AuditLogEntry)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *AuditLogEntry) Diff(other *AuditLogEntry) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *AuditLogEntry) DiffDeep(other *AuditLogEntry) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, using dotted keys
+// for the fields of Before and After, e.g. "before.version" or "after.version".
+func (this *AuditLogEntry) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *AuditLogEntry) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *AuditLogEntry) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *AuditLogEntry) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *AuditLogEntry) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *AuditLogEntry) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the AuditLogEntry is uninitialized, having neither an ID nor a ActorDiscordID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *AuditLogEntry) IsZero() bool {
+  return this.ID == "" && this.ActorDiscordID == ""
+}
+
+// Age returns how long ago the AuditLogEntry was created.
+func (this *AuditLogEntry) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// Misc functions.
+
+// AggregateAuditLogEntries runs the aggregation pipeline on the AuditLogEntry collection, unmarshalling
+// every result into result, which must be a pointer to a slice.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateAuditLogEntries(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("AuditLogEntry", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, pipeline, opts)
+  defer done()
+  return wrapDBError("AuditLogEntry", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateAuditLogEntryOne runs the aggregation pipeline on the AuditLogEntry collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateAuditLogEntryOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("AuditLogEntry", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, pipeline, opts)
+  defer done()
+  return wrapDBError("AuditLogEntry", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindEntriesForTarget finds the most recent entries for the target document, newest first. A limit
+// of 0 means no limit.
+func FindEntriesForTarget(model string, id bson.ObjectId, limit int) (_ []*AuditLogEntry, err error) {
+
+  defer observeOperation("AuditLogEntry", "Find", time.Now(), &err)
+
+  query := AuditLogEntryCol().Find(bson.M{"target_model": model, "target_id": id}).Sort("-created_at")
+  if limit > 0 {
+    query = query.Limit(limit)
+  }
+
+  results := []*AuditLogEntry{}
+  if err := query.All(&results); err != nil {
+    return nil, wrapDBError("AuditLogEntry", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindEntriesByActor finds every entry recorded for the actor since the given time, oldest first.
+func FindEntriesByActor(actorID string, since time.Time) (_ []*AuditLogEntry, err error) {
+
+  defer observeOperation("AuditLogEntry", "Find", time.Now(), &err)
+
+  results := []*AuditLogEntry{}
+  err = AuditLogEntryCol().Find(bson.M{
+    "actor_discord_id": actorID,
+    "created_at":       bson.M{"$gte": since},
+  }).Sort("created_at").All(&results)
+  if err != nil {
+    return nil, wrapDBError("AuditLogEntry", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// DistinctAuditLogEntryFields finds the distinct values of the field among the AuditLogEntries matching the
+// filter.
+func DistinctAuditLogEntryFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("AuditLogEntry", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, field, filter)
+  if err != nil {
+    return nil, wrapDBError("AuditLogEntry", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctAuditLogEntryStringFields is DistinctAuditLogEntryFields for string fields, like actor_discord_id. It fails if
+// any of the values isn't a string.
+func DistinctAuditLogEntryStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctAuditLogEntryFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactAuditLogEntryCollection runs the compact command against the AuditLogEntry collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactAuditLogEntryCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "AuditLogEntry", AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName)
+}
+
+// AuditLogEntryCollectionStats returns the AuditLogEntry collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func AuditLogEntryCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "AuditLogEntry", AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName)
+}
+
+// ExplainAuditLogEntryQuery returns MongoDB's explain output for finding the filter in the AuditLogEntry collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainAuditLogEntryQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "AuditLogEntry", AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, filter)
+}
@@ -0,0 +1,669 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// BanClientName is the name of the MgoDriver to use for Ban.
+const BanClientName = "main"
+
+// BanDBName is the name of the database to use for Ban.
+const BanDBName = "badpetbot"
+
+// BanColName is the name of the collection to use for Ban.
+const BanColName = "bans"
+
+// BanModelVersion is the current schema version of Ban documents, stored in their ModelVersion.
+const BanModelVersion = 1
+
+// BanCol gets a collection reference for Ban.
+func BanCol() *mgo.Collection {
+  return net.MgoCol(BanClientName, BanDBName, BanColName)
+}
+
+// Register Ban, so tooling can find it.
+func init() {
+  RegisterModel("Ban", BanCol, func() interface{} { return new(Ban) })
+}
+
+// EnsureBanIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureBanIndices() error {
+
+  col := BanCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_user_id"}, Background: true}); err != nil {
+    return wrapDBError("Ban", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"expires_at"}, Background: true}); err != nil {
+    return wrapDBError("Ban", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_user_id: 1 }
+// { expires_at: 1 }
+
+// Ban is a user's ban, issued by an owner, which is enforced across servers. A ban may be limited to
+// a single server, and may expire.
+type Ban struct {
+  // ID is a BSON ID generated in Create.
+  ID                bson.ObjectId   `bson:"_id"                   json:"_id"                    validate:"required"`
+  DiscordUserID     string          `bson:"discord_user_id"       json:"discord_user_id"        validate:"required,discord_id"`
+  IssuedByDiscordID string          `bson:"issued_by_discord_id"  json:"issued_by_discord_id"   validate:"required,discord_id"`
+  // ServerID is the Discord ID of the server the ban applies to. Nil means the ban is global.
+  ServerID          *string         `bson:"server_id"             json:"server_id"              validate:"omitempty,discord_id"`
+  Reason            string          `bson:"reason"                json:"reason"                 validate:"-"`
+  // ExpiresAt is when the ban lifts. Nil means the ban is permanent.
+  ExpiresAt         *time.Time      `bson:"expires_at"            json:"expires_at"             validate:"-"`
+  CreatedAt         time.Time       `bson:"created_at"            json:"created_at"             validate:"required"`
+  UpdatedAt         time.Time       `bson:"updated_at"            json:"updated_at"             validate:"required"`
+  DeletedAt         *time.Time      `bson:"deleted_at"            json:"deleted_at"             validate:"-"`
+  Version           int             `bson:"version"               json:"version"                validate:"-"`
+  ModelVersion      int             `bson:"model_version"         json:"model_version"          validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *Ban) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Ban.Create", BanDBName, BanColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Ban", "Create", time.Now(), &err)
+  defer logSlowQuery("Ban", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = BanModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the Ban.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, BanClientName, BanDBName, BanColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("Ban", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *Ban) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *Ban) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *Ban) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Ban.Update", BanDBName, BanColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Ban", "Update", time.Now(), &err)
+  defer logSlowQuery("Ban", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, BanClientName, BanDBName, BanColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheBan(net.RedisGetClient(BanClientName), this.cacheKeys())
+  }
+  return wrapDBError("Ban", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *Ban) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "Ban.Delete", BanDBName, BanColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Ban", "Delete", time.Now(), &err)
+  defer logSlowQuery("Ban", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the Ban.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, BanClientName, BanDBName, BanColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheBan(net.RedisGetClient(BanClientName), this.cacheKeys())
+  }
+  return wrapDBError("Ban", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *Ban) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *Ban) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *Ban) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("Ban", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, BanClientName, BanDBName, BanColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("Ban", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheBan(net.RedisGetClient(BanClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *Ban) Validate(ctx context.Context) error {
+
+  return wrapValidationError("Ban", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *Ban) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("Ban", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("Ban", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *Ban) Clone() *Ban {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*Ban)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *Ban) Diff(other *Ban) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *Ban) DiffDeep(other *Ban) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "reason". A nil
+// ExpiresAt or ServerID produces no key.
+func (this *Ban) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *Ban) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *Ban) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *Ban) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *Ban) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *Ban) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the Ban is uninitialized, having neither an ID nor a DiscordUserID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *Ban) IsZero() bool {
+  return this.ID == "" && this.DiscordUserID == ""
+}
+
+// Age returns how long ago the Ban was created.
+func (this *Ban) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the Ban was last updated.
+func (this *Ban) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the Ban hasn't been updated for longer than the threshold.
+func (this *Ban) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetBan attempts to find a Ban by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetBan(key, value string, negCache bool) (found *Ban, err error) {
+
+  client := net.RedisGetClient(BanClientName)
+  cacheKey := BanClientName+":"+BanDBName+":"+BanColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "Ban.CacheGet", BanDBName, BanColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Ban", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("Ban", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("Ban", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "Ban", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Ban", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("Ban", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    ban := new(Ban)
+    if err := json.Unmarshal([]byte(result), ban); err != nil {
+      return nil, wrapDBError("Ban", "CacheGet", key, value, err)
+    }
+    return ban, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("Ban", key, "miss")
+  ban := new(Ban)
+  err = net.MgoCol(BanClientName, BanDBName, BanColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(ban)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheBan(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheBan(client, cacheKey, ban)
+  }
+  if err != nil {
+    return nil, wrapDBError("Ban", "CacheGet", key, value, err)
+  }
+  return ban, nil
+}
+
+func fillCacheBan(client *redis.Client, key string, value *Ban) {
+  _, span := startCacheSpan(context.Background(), "Ban.fillCache", BanDBName, BanColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for Ban")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for Ban")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheBan(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "Ban.fillNegCache", BanDBName, BanColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for Ban")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheBan(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for Ban")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetBan.
+func (this *Ban) cacheKeys() []string {
+  prefix := BanClientName+":"+BanDBName+":"+BanColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateBans runs the aggregation pipeline on the Ban collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateBans(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Ban", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(BanClientName, BanDBName, BanColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Ban", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateBanOne runs the aggregation pipeline on the Ban collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateBanOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Ban", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(BanClientName, BanDBName, BanColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Ban", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyBan atomically applies the update to the first Ban matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyBan(selector, update bson.M, returnNew bool) (_ *Ban, err error) {
+
+  defer observeOperation("Ban", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(Ban)
+  _, err = BanCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("Ban", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheBan(net.RedisGetClient(BanClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindBans finds all Bans matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindBans(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*Ban, err error) {
+
+  defer observeOperation("Ban", "Find", time.Now(), &err)
+  defer logSlowQuery("Ban", "Find", filter)()
+
+  results := []*Ban{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, BanClientName, BanDBName, BanColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Ban", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindBansWithDeleted finds all Bans matching the filter, including soft-deleted ones.
+func FindBansWithDeleted(filter bson.M) (_ []*Ban, err error) {
+
+  defer observeOperation("Ban", "Find", time.Now(), &err)
+
+  results := []*Ban{}
+  if err := net.MgoCol(BanClientName, BanDBName, BanColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("Ban", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountBans counts the Bans matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountBans(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("Ban", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(BanClientName, BanDBName, BanColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("Ban", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// BanExists reports whether any Ban matches the filter, excluding soft-deleted ones.
+func BanExists(filter bson.M) (bool, error) {
+
+  count, err := CountBans(filter)
+  return count > 0, err
+}
+
+// DistinctBanFields finds the distinct values of the field among the Bans matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctBanFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("Ban", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, BanClientName, BanDBName, BanColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("Ban", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctBanStringFields is DistinctBanFields for string fields, like reason. It fails if
+// any of the values isn't a string.
+func DistinctBanStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctBanFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactBanCollection runs the compact command against the Ban collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactBanCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "Ban", BanClientName, BanDBName, BanColName)
+}
+
+// BanCollectionStats returns the Ban collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func BanCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "Ban", BanClientName, BanDBName, BanColName)
+}
+
+// ExplainBanQuery returns MongoDB's explain output for finding the filter in the Ban collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainBanQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "Ban", BanClientName, BanDBName, BanColName, filter)
+}
+
+// UpdateManyBans applies the updates to every Ban matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateBans for that. The Ban cache is flushed.
+func UpdateManyBans(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "Ban", BanClientName, BanDBName, BanColName, filter, updates)
+}
+
+// BulkUpdateBans is UpdateManyBans, but validates each Ban as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateBans(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "Ban", BanClientName, BanDBName, BanColName, filter, updates, func() validatable { return new(Ban) })
+}
+
+// DeleteManyBans soft-deletes every Ban matching the filter in a single query, returning how many
+// were deleted. The Ban cache is evicted.
+func DeleteManyBans(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "Ban", BanClientName, BanDBName, BanColName, filter)
+}
+
+// FindActiveBans finds every ban against the Discord user which hasn't expired, whether global or
+// limited to a server.
+func FindActiveBans(discordUserID string) ([]*Ban, error) {
+
+  return FindBans(context.Background(), bson.M{
+    "discord_user_id": discordUserID,
+    "$or": []bson.M{
+      {"expires_at": nil},
+      {"expires_at": bson.M{"$gt": clockNow()}},
+    },
+  }, "", 0)
+}
+
+// ExpireBans permanently removes every ban which has expired, returning how many were removed.
+func ExpireBans() (removed int, err error) {
+
+  defer observeOperation("Ban", "Expire", time.Now(), &err)
+
+  // Find the expired bans first, so their cache entries can be evicted.
+  col := BanCol()
+  expired := []*Ban{}
+  if err = col.Find(bson.M{"expires_at": bson.M{"$lte": clockNow()}}).Select(bson.M{"_id": 1}).All(&expired); err != nil {
+    return 0, wrapDBError("Ban", "Expire", "", "", err)
+  }
+  if len(expired) == 0 {
+    return 0, nil
+  }
+  ids := make([]bson.ObjectId, len(expired))
+  keys := []string{}
+  for i, ban := range expired {
+    ids[i] = ban.ID
+    keys = append(keys, ban.cacheKeys()...)
+  }
+
+  // Remove them and evict stale cache entries.
+  info, err := col.RemoveAll(bson.M{"_id": bson.M{"$in": ids}})
+  if err != nil {
+    return 0, wrapDBError("Ban", "Expire", "", "", err)
+  }
+  go invalidateCacheBan(net.RedisGetClient(BanClientName), keys)
+  return info.Removed, nil
+}
@@ -0,0 +1,151 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+)
+
+// validatable is implemented by every model.
+type validatable interface {
+  Validate(ctx context.Context) error
+}
+
+// stampUpdates returns a copy of the updates which also sets updated-at and increments the version, the
+// way a single document's Update does.
+func stampUpdates(updates bson.M) bson.M {
+
+  stamped := make(bson.M, len(updates)+2)
+  for operator, fields := range updates {
+    stamped[operator] = fields
+  }
+  set := bson.M{}
+  if fields, ok := updates["$set"].(bson.M); ok {
+    for k, v := range fields {
+      set[k] = v
+    }
+  }
+  set["updated_at"] = clockNow()
+  stamped["$set"] = set
+  inc := bson.M{}
+  if fields, ok := updates["$inc"].(bson.M); ok {
+    for k, v := range fields {
+      inc[k] = v
+    }
+  }
+  inc["version"] = 1
+  stamped["$inc"] = inc
+  return stamped
+}
+
+// updateMany applies the updates to every document in the model's collection matching the filter,
+// excluding soft-deleted ones, without validating them. The collection's cache is flushed, since the
+// updated documents aren't known.
+func updateMany(ctx context.Context, model, client, database, collection string, filter, updates bson.M) (updated int, err error) {
+
+  defer observeOperation(model, "UpdateMany", time.Now(), &err)
+  defer logSlowQuery(model, "UpdateMany", filter)()
+
+  stamped := stampUpdates(updates)
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    info, err := col.UpdateAll(notDeleted(filter), stamped)
+    if info != nil {
+      updated = info.Updated
+    }
+    return err
+  })
+  if err != nil {
+    return updated, wrapDBError(model, "UpdateMany", "", "", err)
+  }
+  if updated > 0 {
+    go flushCollectionCache(client, database, collection)
+  }
+  log.Info().Int("updated", updated).Msgf("Updated many %s documents", model)
+  return updated, nil
+}
+
+// bulkUpdate applies the updates to every document in the model's collection matching the filter,
+// excluding soft-deleted ones, one at a time, skipping those which wouldn't pass validation once
+// updated. Only the $set, $unset, and $inc operators can be validated. newDoc returns a pointer to a
+// new zero value of the model. Returns how many were updated, and every failure together.
+func bulkUpdate(ctx context.Context, model, client, database, collection string, filter, updates bson.M, newDoc func() validatable) (updated int, err error) {
+
+  defer observeOperation(model, "BulkUpdate", time.Now(), &err)
+  defer logSlowQuery(model, "BulkUpdate", filter)()
+
+  stamped := stampUpdates(updates)
+  failures := []string{}
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    iter := col.Find(notDeleted(filter)).Iter()
+    stored := bson.M{}
+    for iter.Next(&stored) {
+      id, _ := stored["_id"].(bson.ObjectId)
+
+      // Validate the document as it would be once updated.
+      doc := newDoc()
+      result, err := applyUpdate(stored, stamped)
+      if err == nil {
+        err = fromBSONDoc(result, doc)
+      }
+      if err == nil {
+        err = doc.Validate(ctx)
+      }
+      if err == nil {
+        err = col.UpdateId(id, stamped)
+      }
+      if err != nil {
+        failures = append(failures, fmt.Sprintf("%s: %s", id.Hex(), err.Error()))
+      } else {
+        updated++
+      }
+      stored = bson.M{}
+    }
+    return iter.Close()
+  })
+  if updated > 0 {
+    go flushCollectionCache(client, database, collection)
+  }
+  if err != nil {
+    return updated, wrapDBError(model, "BulkUpdate", "", "", err)
+  }
+  log.Info().Int("updated", updated).Int("failed", len(failures)).Msgf("Bulk updated %s documents", model)
+  if len(failures) > 0 {
+    return updated, errors.New("error bulk updating " + model + ": " + strings.Join(failures, "; "))
+  }
+  return updated, nil
+}
+
+// softDeleteMany marks every document in the model's collection matching the filter as deleted, unless it
+// already is, returning how many were. Every cache entry of the collection is evicted, since the deleted
+// documents aren't known.
+func softDeleteMany(ctx context.Context, model, client, database, collection string, filter bson.M) (deleted int, err error) {
+
+  defer observeOperation(model, "DeleteMany", time.Now(), &err)
+  defer logSlowQuery(model, "DeleteMany", filter)()
+
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    info, err := col.UpdateAll(notDeleted(filter), stampUpdates(bson.M{"$set": bson.M{"deleted_at": clockNow()}}))
+    if info != nil {
+      deleted = info.Updated
+    }
+    return err
+  })
+  if err != nil {
+    return deleted, wrapDBError(model, "DeleteMany", "", "", err)
+  }
+  if deleted > 0 {
+    if err := CacheDeletePattern(ctx, client+":"+database+":"+collection+":*"); err != nil {
+      log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for %s", model)
+    }
+  }
+  return deleted, nil
+}
@@ -0,0 +1,163 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// cacheLockWaitAttempts and cacheLockWaitDelay define how long a caller which didn't get a cache-miss
+// lock waits for the holder to fill the cache, before fetching from the database itself.
+const cacheLockWaitAttempts = 5
+const cacheLockWaitDelay = 50*time.Millisecond
+
+// cacheDeleteBatchSize is how many keys are scanned, then deleted, at a time by CacheDeletePattern.
+const cacheDeleteBatchSize = 100
+
+// CacheDeletePattern deletes every cache key matching the glob pattern, such as
+// "main:badpetbot:server_members:discord_server_id:*", scanning and deleting in batches so Redis isn't
+// blocked. The Redis client is the one named by the pattern's first segment, after any "neg:" prefix.
+func CacheDeletePattern(ctx context.Context, pattern string) error {
+
+  client := strings.SplitN(strings.TrimPrefix(pattern, "neg:"), ":", 2)[0]
+  if client == "" || strings.ContainsAny(client, "*?[") {
+    return fmt.Errorf("can't delete cache pattern %q: it must start with a client name", pattern)
+  }
+  deleted, err := deleteCachePattern(ctx, net.RedisGetClient(client), pattern)
+  log.Info().Int("deleted", deleted).Msgf("Deleted cache keys matching %s", pattern)
+  return err
+}
+
+// deleteCachePattern deletes every key matching the pattern in batches, returning how many were deleted.
+func deleteCachePattern(ctx context.Context, client *redis.Client, pattern string) (deleted int, err error) {
+
+  var cursor uint64
+  for {
+    if err := ctx.Err(); err != nil {
+      return deleted, err
+    }
+    var keys []string
+    keys, cursor, err = client.Scan(cursor, pattern, cacheDeleteBatchSize).Result()
+    if err != nil {
+      return deleted, err
+    }
+    if len(keys) > 0 {
+      removed, err := client.Del(keys...).Result()
+      if err != nil {
+        return deleted, err
+      }
+      deleted += int(removed)
+    }
+    if cursor == 0 {
+      return deleted, nil
+    }
+  }
+}
+
+// BatchCacheInvalidate deletes the fully-formed cache keys, such as those of documents changed by a bulk
+// operation, in one transactional pipeline per Redis client rather than a round trip per key. Like
+// CacheDeletePattern, each key's client is the one named by its first segment, after any "neg:" prefix.
+// Keys which don't exist are ignored, and so are empty keys.
+func BatchCacheInvalidate(ctx context.Context, keys []string) error {
+
+  // Group the keys by client.
+  byClient := map[string][]string{}
+  for _, key := range keys {
+    if key == "" {
+      continue
+    }
+    client := strings.SplitN(strings.TrimPrefix(key, "neg:"), ":", 2)[0]
+    byClient[client] = append(byClient[client], key)
+  }
+
+  failures := []string{}
+  for client, keys := range byClient {
+    pipe := net.RedisGetClient(client).WithContext(ctx).TxPipeline()
+    for _, key := range keys {
+      pipe.Del(key)
+    }
+    if _, err := pipe.Exec(); err != nil {
+      failures = append(failures, client+": "+err.Error())
+    }
+    pipe.Close()
+  }
+  if len(failures) > 0 {
+    return errors.New("error invalidating cache: " + strings.Join(failures, "; "))
+  }
+  return nil
+}
+
+// acquireCacheLock tries to take the lock for fetching the cache key's document from the database,
+// returning whether it was taken, and the token to release it with. If Redis fails, the lock is
+// considered taken, since waiting for another caller to fill the cache would be pointless.
+func acquireCacheLock(client *redis.Client, cacheKey string) (token string, locked bool) {
+
+  token, err := newLockToken()
+  if err == nil {
+    locked, err = client.SetNX("lock:"+cacheKey, token, getConfig().cacheLockTTL()).Result()
+  }
+  if err != nil {
+    log.Warn().AnErr("cacheLock", err).Msgf("Error taking cache lock for %s", cacheKey)
+    return "", true
+  }
+  return token, locked
+}
+
+// releaseCacheLock releases the lock taken with acquireCacheLock, unless it expired and was taken by
+// someone else, or was never really taken because Redis failed.
+func releaseCacheLock(client *redis.Client, cacheKey, token string) {
+
+  if token == "" {
+    return
+  }
+  if err := unlockScript.Run(client, []string{"lock:"+cacheKey}, token).Err(); err != nil {
+    log.Warn().AnErr("cacheLock", err).Msgf("Error releasing cache lock for %s", cacheKey)
+  }
+}
+
+// waitForCacheFill polls the cache key, and its neg-cache key if negCache, while the lock holder fills
+// it. Returns the cached value, or neg true if the neg-cache was filled. Returns "" and false if
+// neither was filled in time.
+func waitForCacheFill(client *redis.Client, cacheKey string, negCache bool) (result string, neg bool) {
+
+  for attempt := 0; attempt < cacheLockWaitAttempts; attempt++ {
+    time.Sleep(cacheLockWaitDelay)
+    if result, _ := client.Get(cacheKey).Result(); result != "" {
+      return result, false
+    }
+    if negCache {
+      if result, _ := client.Get("neg:"+cacheKey).Result(); result != "" {
+        return "", true
+      }
+    }
+  }
+  return "", false
+}
+
+// refreshCacheTTL resets the cache key's TTL to ttl if less than the configured refresh threshold of
+// it remains, so frequently read entries don't expire. Failures are only logged.
+func refreshCacheTTL(client *redis.Client, cacheKey string, ttl time.Duration) {
+
+  remaining, err := client.TTL(cacheKey).Result()
+  if err != nil {
+    log.Warn().AnErr("refreshCache", err).Msgf("Error getting cache TTL for %s", cacheKey)
+    return
+  }
+  if remaining < 0 || remaining >= time.Duration(float64(ttl)*getConfig().CacheRefreshThreshold) {
+    return
+  }
+  if err := client.Expire(cacheKey, ttl).Err(); err != nil {
+    log.Warn().AnErr("refreshCache", err).Msgf("Error refreshing cache TTL for %s", cacheKey)
+  }
+}
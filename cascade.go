@@ -0,0 +1,128 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// DeleteManyServerMembersByServerID permanently removes every ServerMember in the Discord server,
+// including soft-deleted ones, and evicts them from cache. Returns how many were removed.
+func DeleteManyServerMembersByServerID(discordServerID string) (removed int, err error) {
+
+  defer observeOperation("ServerMember", "DeleteMany", time.Now(), &err)
+
+  // Find the members first, so their cache entries can be evicted.
+  col := ServerMemberCol()
+  selector := bson.M{"discord_server_id": discordServerID}
+  members := []*ServerMember{}
+  if err = col.Find(selector).All(&members); err != nil {
+    return 0, wrapDBError("ServerMember", "DeleteMany", "discord_server_id", discordServerID, err)
+  }
+  if len(members) == 0 {
+    return 0, nil
+  }
+  keys := []string{}
+  for _, member := range members {
+    keys = append(keys, member.cacheKeys()...)
+  }
+
+  // Remove them and evict stale cache entries.
+  info, err := col.RemoveAll(selector)
+  if err != nil {
+    return 0, wrapDBError("ServerMember", "DeleteMany", "discord_server_id", discordServerID, err)
+  }
+  go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), keys)
+  bustGroupByServerCache()
+  return info.Removed, nil
+}
+
+// DeleteCascade permanently removes the Server along with all of its ServerMembers. If the members
+// can't be removed, the Server is left in place and the error is returned.
+func (this *Server) DeleteCascade(ctx context.Context) error {
+
+  removed, err := DeleteManyServerMembersByServerID(this.DiscordID)
+  if err != nil {
+    log.Error().AnErr("deleteCascade", err).Msgf("Error deleting ServerMembers of Server %s, keeping the Server", this.DiscordID)
+    return err
+  }
+  log.Info().Int("removed", removed).Msgf("Deleted ServerMembers of Server %s", this.DiscordID)
+
+  if err := this.Delete(ctx); err != nil {
+    log.Error().AnErr("deleteCascade", err).Msgf("Error deleting Server %s", this.DiscordID)
+    return err
+  }
+  log.Info().Msgf("Deleted Server %s", this.DiscordID)
+  return nil
+}
+
+// DeleteCascadeAll permanently removes the Server with the Discord ID along with everything belonging
+// to it: its ServerMembers, ServerConfig, Warnings, CustomCommands, ReactionRoles, ScheduledTasks,
+// UserPreferences, Tags, and Leaderboards. Related documents are removed first and the Server last,
+// so a failed cascade can be retried. A failure in one collection doesn't stop the others, and every
+// failure is returned together, in which case the Server is kept.
+func DeleteCascadeAll(serverID string) error {
+
+  server := new(Server)
+  if err := ServerCol().Find(bson.M{"discord_id": serverID}).One(server); err != nil {
+    return wrapDBError("Server", "DeleteCascade", "discord_id", serverID, err)
+  }
+
+  failures := []string{}
+  if removed, err := DeleteManyServerMembersByServerID(serverID); err != nil {
+    log.Error().AnErr("deleteCascade", err).Msgf("Error deleting ServerMembers of Server %s", serverID)
+    failures = append(failures, ServerMemberColName+": "+err.Error())
+  } else {
+    log.Info().Int("removed", removed).Msgf("Deleted ServerMembers of Server %s", serverID)
+  }
+
+  // Remove everything else belonging to the server, flushing each collection's cache.
+  related := []userDataQuery{
+    {ServerConfigClientName, ServerConfigDBName, ServerConfigColName, bson.M{"server_id": server.ID}},
+    {WarningClientName, WarningDBName, WarningColName, bson.M{"discord_server_id": serverID}},
+    {CustomCommandClientName, CustomCommandDBName, CustomCommandColName, bson.M{"discord_server_id": serverID}},
+    {ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, bson.M{"discord_server_id": serverID}},
+    {ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, bson.M{"discord_server_id": serverID}},
+    {UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, bson.M{"discord_server_id": serverID}},
+    {TagClientName, TagDBName, TagColName, bson.M{"discord_server_id": serverID}},
+    {LeaderboardClientName, LeaderboardDBName, LeaderboardColName, bson.M{"discord_server_id": serverID}},
+  }
+  for _, query := range related {
+    var info *mgo.ChangeInfo
+    err := mgoDo(context.Background(), query.client, query.database, query.collection, func(col *mgo.Collection) (err error) {
+      info, err = col.RemoveAll(query.filter)
+      return err
+    })
+    if err != nil {
+      log.Error().AnErr("deleteCascade", err).Msgf("Error deleting %s of Server %s", query.collection, serverID)
+      failures = append(failures, query.collection+": "+err.Error())
+      continue
+    }
+    log.Info().Int("removed", info.Removed).Msgf("Deleted %s of Server %s", query.collection, serverID)
+    if info.Removed > 0 {
+      flushCollectionCache(query.client, query.database, query.collection)
+    }
+  }
+  if len(failures) > 0 {
+    return wrapDBError("Server", "DeleteCascade", "discord_id", serverID, errors.New(strings.Join(failures, "; ")))
+  }
+
+  // Finally, remove the server itself.
+  if err := server.Delete(context.Background()); err != nil {
+    log.Error().AnErr("deleteCascade", err).Msgf("Error deleting Server %s", serverID)
+    return err
+  }
+  log.Info().Msgf("Deleted Server %s", serverID)
+  return nil
+}
@@ -0,0 +1,22 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "time"
+)
+
+// clockNow gets the current time for timestamps and expiry checks. It's time.Now unless replaced with
+// SetClock.
+var clockNow = time.Now
+
+// SetClock replaces the clock used for timestamps and expiry checks, so tests get deterministic
+// CreatedAt and UpdatedAt values. A nil fn restores the real clock. It isn't safe to call while models
+// are in use.
+func SetClock(fn func() time.Time) {
+
+  if fn == nil {
+    fn = time.Now
+  }
+  clockNow = fn
+}
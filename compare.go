@@ -0,0 +1,57 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "reflect"
+)
+
+// FieldDiff describes a field whose value differs between two documents.
+type FieldDiff struct {
+  // Field is the Go field name, dotted for fields of embedded documents.
+  Field    string
+  Expected interface{}
+  Actual   interface{}
+}
+
+// compareSkippedFields are left out by CompareModels, since they're set by the database operations.
+var compareSkippedFields = map[string]bool{"CreatedAt": true, "UpdatedAt": true}
+
+// CompareModels compares every exported field of two documents of the same model, given as structs or
+// pointers to them, and returns a diff for each one that differs. CreatedAt and UpdatedAt are skipped,
+// including in embedded documents, which are compared field by field when both have them. If a and b
+// aren't of the same model, a single diff with an empty Field is returned.
+func CompareModels(a, b interface{}) []FieldDiff {
+
+  expected, actual := reflect.Indirect(reflect.ValueOf(a)), reflect.Indirect(reflect.ValueOf(b))
+  if !expected.IsValid() || !actual.IsValid() || expected.Type() != actual.Type() || expected.Kind() != reflect.Struct {
+    if reflect.DeepEqual(a, b) {
+      return nil
+    }
+    return []FieldDiff{{Expected: a, Actual: b}}
+  }
+  diffs := []FieldDiff{}
+  compareStructs(&diffs, "", expected, actual)
+  return diffs
+}
+
+// compareStructs adds a diff for each differing field of the structs a and b, prefixing field names.
+func compareStructs(diffs *[]FieldDiff, prefix string, a, b reflect.Value) {
+
+  for i := 0; i < a.NumField(); i++ {
+    field := a.Type().Field(i)
+    if field.PkgPath != "" || compareSkippedFields[field.Name] {
+      continue
+    }
+    fieldA, fieldB := a.Field(i), b.Field(i)
+
+    // Compare embedded documents field by field, so their timestamps are skipped too.
+    if fieldA.Kind() == reflect.Ptr && !fieldA.IsNil() && !fieldB.IsNil() && fieldA.Elem().Kind() == reflect.Struct && fieldA.Type().Elem() != timeType {
+      compareStructs(diffs, prefix+field.Name+".", fieldA.Elem(), fieldB.Elem())
+      continue
+    }
+    if !reflect.DeepEqual(fieldA.Interface(), fieldB.Interface()) {
+      *diffs = append(*diffs, FieldDiff{Field: prefix+field.Name, Expected: fieldA.Interface(), Actual: fieldB.Interface()})
+    }
+  }
+}
@@ -0,0 +1,237 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "errors"
+  "sync"
+  "time"
+)
+
+// CacheTTL is the default time documents can remain in cache.
+const CacheTTL = 120*time.Second
+
+// NegCacheTTL is the default time neg-cache can remain in cache.
+const NegCacheTTL = 60*time.Second
+
+// LeaderboardTopCacheTTL is the default time GetTopMembers results can remain in cache. It's kept
+// short since scores change constantly.
+const LeaderboardTopCacheTTL = 15*time.Second
+
+// SlowQueryThreshold is the default duration above which database operations are logged as slow.
+const SlowQueryThreshold = 200*time.Millisecond
+
+// DefaultMaxQueryTime is the default time MongoDB lets Find, Count, and Aggregate queries run for.
+const DefaultMaxQueryTime = 5*time.Second
+
+// CountByOwnerCacheTTL is the time CountByOwner's pet counts can remain in cache.
+const CountByOwnerCacheTTL = 30*time.Second
+
+// IdempotencyTTL is the default time CreateIdempotent remembers an idempotency key for.
+const IdempotencyTTL = 24*time.Hour
+
+// GroupByServerCacheTTL is the time GroupByServer's member counts can remain in cache.
+const GroupByServerCacheTTL = 60*time.Second
+
+// CacheLockTTL is the default time a cache-miss lock is held for. It should be longer than the slowest
+// expected database query, so the lock doesn't expire while its holder is still fetching.
+const CacheLockTTL = 2*time.Second
+
+// CacheRefreshThreshold is the default fraction of a cache entry's TTL below which reading it resets
+// its TTL.
+const CacheRefreshThreshold = 0.2
+
+// Config defines the tunable behaviour of the package. Zero-valued durations fall back to their
+// defaults, and zero-valued per-model overrides fall back to the package-wide values.
+type Config struct {
+  DefaultCacheTTL         time.Duration `json:"default_cache_ttl"`
+  DefaultNegCacheTTL      time.Duration `json:"default_neg_cache_ttl"`
+
+  // Per-model overrides.
+  ServerCacheTTL          time.Duration `json:"server_cache_ttl"`
+  ServerNegCacheTTL       time.Duration `json:"server_neg_cache_ttl"`
+  ServerMemberCacheTTL    time.Duration `json:"server_member_cache_ttl"`
+  ServerMemberNegCacheTTL time.Duration `json:"server_member_neg_cache_ttl"`
+  LeaderboardTopCacheTTL  time.Duration `json:"leaderboard_top_cache_ttl"`
+
+  // CacheLockTTL is how long the lock taken while fetching a cache miss from the database is held for.
+  CacheLockTTL            time.Duration `json:"cache_lock_ttl"`
+
+  // CacheRefreshThreshold is the fraction of a cache entry's TTL below which reading it resets its TTL,
+  // so frequently read entries don't expire.
+  CacheRefreshThreshold   float64       `json:"cache_refresh_threshold"`
+
+  // SlowQueryThreshold is the duration above which Create, Update, Delete, and Find operations are
+  // logged as slow.
+  SlowQueryThreshold      time.Duration `json:"slow_query_threshold"`
+
+  // DefaultMaxQueryTime is how long MongoDB lets Find, Count, and Aggregate queries run for, unless
+  // they're given WithMaxTime.
+  DefaultMaxQueryTime     time.Duration `json:"default_max_query_time"`
+
+  // IdempotencyTTL is how long CreateIdempotent remembers an idempotency key, and so how long re-submitted
+  // requests are deduplicated for.
+  IdempotencyTTL          time.Duration `json:"idempotency_ttl"`
+
+  // Retry defines how Create, Update, and Delete retry transient errors.
+  Retry                   RetryConfig   `json:"retry"`
+
+  // LockRetries is how many times DistributedLock retries taking a held lock, backing off from
+  // Retry.BaseDelay.
+  LockRetries             int           `json:"lock_retries"`
+
+  // BackfillBatchSize is how many documents Backfill updates at a time.
+  BackfillBatchSize       int           `json:"backfill_batch_size"`
+
+  // AdminCommandsEnabled allows admin commands, like CompactServerMemberCollection, which are too
+  // disruptive to run by accident from a request handler.
+  AdminCommandsEnabled    bool          `json:"admin_commands_enabled"`
+
+  // DebugMode allows debugging helpers, like ExplainServerMemberQuery, which shouldn't be used in
+  // production.
+  DebugMode               bool          `json:"debug_mode"`
+
+  // OTelEnabled turns on OpenTelemetry spans for database and cache operations.
+  OTelEnabled             bool          `json:"otel_enabled"`
+
+  // EncryptionKey is the 32-byte key the AES-256 keys EncryptedString fields are encrypted with are
+  // derived from. If empty, they're stored as plaintext.
+  EncryptionKey           []byte        `json:"encryption_key"`
+}
+
+var config = Config{
+  DefaultCacheTTL:       CacheTTL,
+  DefaultNegCacheTTL:    NegCacheTTL,
+  CacheRefreshThreshold: CacheRefreshThreshold,
+  SlowQueryThreshold:    SlowQueryThreshold,
+  DefaultMaxQueryTime:   DefaultMaxQueryTime,
+  IdempotencyTTL:        IdempotencyTTL,
+  LockRetries:           DefaultLockRetries,
+  BackfillBatchSize:     DefaultBackfillBatchSize,
+  Retry:                 RetryConfig{
+    MaxAttempts: DefaultRetryMaxAttempts,
+    BaseDelay:   DefaultRetryBaseDelay,
+  },
+}
+var configMu sync.RWMutex
+
+// Configure validates the given config, fills in defaults, and makes it the package's config.
+func Configure(cfg Config) error {
+
+  // Validate the config.
+  durations := []time.Duration{
+    cfg.DefaultCacheTTL, cfg.DefaultNegCacheTTL,
+    cfg.ServerCacheTTL, cfg.ServerNegCacheTTL,
+    cfg.ServerMemberCacheTTL, cfg.ServerMemberNegCacheTTL,
+    cfg.LeaderboardTopCacheTTL,
+    cfg.CacheLockTTL,
+    cfg.SlowQueryThreshold,
+    cfg.DefaultMaxQueryTime,
+    cfg.IdempotencyTTL,
+    cfg.Retry.BaseDelay,
+  }
+  for _, d := range durations {
+    if d < 0 {
+      return errors.New("gomodel config durations can't be negative")
+    }
+  }
+
+  if cfg.CacheRefreshThreshold < 0 || cfg.CacheRefreshThreshold > 1 {
+    return errors.New("gomodel config cache refresh threshold must be between 0 and 1")
+  }
+
+  if len(cfg.EncryptionKey) != 0 && len(cfg.EncryptionKey) != 32 {
+    return errors.New("gomodel config encryption key must be 32 bytes")
+  }
+
+  // Fill in defaults.
+  if cfg.DefaultCacheTTL == 0 {
+    cfg.DefaultCacheTTL = CacheTTL
+  }
+  if cfg.DefaultNegCacheTTL == 0 {
+    cfg.DefaultNegCacheTTL = NegCacheTTL
+  }
+  if cfg.CacheRefreshThreshold == 0 {
+    cfg.CacheRefreshThreshold = CacheRefreshThreshold
+  }
+  if cfg.SlowQueryThreshold == 0 {
+    cfg.SlowQueryThreshold = SlowQueryThreshold
+  }
+  if cfg.DefaultMaxQueryTime == 0 {
+    cfg.DefaultMaxQueryTime = DefaultMaxQueryTime
+  }
+  if cfg.IdempotencyTTL == 0 {
+    cfg.IdempotencyTTL = IdempotencyTTL
+  }
+  if cfg.Retry.MaxAttempts < 0 {
+    return errors.New("gomodel config retry attempts can't be negative")
+  }
+  if cfg.Retry.MaxAttempts == 0 {
+    cfg.Retry.MaxAttempts = DefaultRetryMaxAttempts
+  }
+  if cfg.Retry.BaseDelay == 0 {
+    cfg.Retry.BaseDelay = DefaultRetryBaseDelay
+  }
+  if cfg.LockRetries < 0 {
+    return errors.New("gomodel config lock retries can't be negative")
+  }
+  if cfg.LockRetries == 0 {
+    cfg.LockRetries = DefaultLockRetries
+  }
+  if cfg.BackfillBatchSize < 0 {
+    return errors.New("gomodel config backfill batch size can't be negative")
+  }
+  if cfg.BackfillBatchSize == 0 {
+    cfg.BackfillBatchSize = DefaultBackfillBatchSize
+  }
+
+  configMu.Lock()
+  config = cfg
+  otelEnabled = cfg.OTelEnabled
+  configMu.Unlock()
+  return nil
+}
+
+// getConfig returns a copy of the package's current config.
+func getConfig() Config {
+
+  configMu.RLock()
+  defer configMu.RUnlock()
+  return config
+}
+
+// cacheTTL returns the given per-model override if set, otherwise the default cache TTL.
+func (this Config) cacheTTL(override time.Duration) time.Duration {
+
+  if override > 0 {
+    return override
+  }
+  return this.DefaultCacheTTL
+}
+
+// negCacheTTL returns the given per-model override if set, otherwise the default neg-cache TTL.
+func (this Config) negCacheTTL(override time.Duration) time.Duration {
+
+  if override > 0 {
+    return override
+  }
+  return this.DefaultNegCacheTTL
+}
+
+// leaderboardTopCacheTTL returns the configured TTL for cached top members, or its short default.
+func (this Config) leaderboardTopCacheTTL() time.Duration {
+
+  if this.LeaderboardTopCacheTTL > 0 {
+    return this.LeaderboardTopCacheTTL
+  }
+  return LeaderboardTopCacheTTL
+}
+
+// cacheLockTTL returns the configured TTL for cache-miss locks, or its default.
+func (this Config) cacheLockTTL() time.Duration {
+
+  if this.CacheLockTTL > 0 {
+    return this.CacheLockTTL
+  }
+  return CacheLockTTL
+}
@@ -0,0 +1,103 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "encoding/csv"
+  "encoding/json"
+  "fmt"
+  "io"
+  "reflect"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// CSVRowable is implemented by every model, for exporting documents with WriteCSV.
+type CSVRowable interface {
+  CSVHeaders() []string
+  ToCSVRow() []string
+}
+
+// WriteCSV writes the headers of the first row, then every row. Rows should all be of the same model.
+// Nothing is written if there are no rows.
+func WriteCSV(w io.Writer, rows []CSVRowable) error {
+
+  if len(rows) == 0 {
+    return nil
+  }
+  writer := csv.NewWriter(w)
+  if err := writer.Write(rows[0].CSVHeaders()); err != nil {
+    return err
+  }
+  for _, row := range rows {
+    if err := writer.Write(row.ToCSVRow()); err != nil {
+      return err
+    }
+  }
+  writer.Flush()
+  return writer.Error()
+}
+
+// csvHeaders returns the bson field names of the struct pointed to by doc, in declaration order.
+// Embeddables aren't stored, so they aren't exported either.
+func csvHeaders(doc interface{}) []string {
+
+  headers := []string{}
+  t := reflect.TypeOf(doc).Elem()
+  for i := 0; i < t.NumField(); i++ {
+    if name, embeddable := bsonFieldName(t.Field(i)); name != "" && !embeddable {
+      headers = append(headers, name)
+    }
+  }
+  return headers
+}
+
+// toCSVRow returns the values of the struct pointed to by doc, in the same order as csvHeaders.
+func toCSVRow(doc interface{}) []string {
+
+  row := []string{}
+  v := reflect.ValueOf(doc).Elem()
+  for i := 0; i < v.NumField(); i++ {
+    if name, embeddable := bsonFieldName(v.Type().Field(i)); name != "" && !embeddable {
+      row = append(row, csvValue(v.Field(i)))
+    }
+  }
+  return row
+}
+
+// csvValue formats a single field for CSV. Times are RFC3339, ObjectIds are hex, nil pointers are
+// empty, slices are semicolon-joined, and documents are JSON.
+func csvValue(v reflect.Value) string {
+
+  switch value := v.Interface().(type) {
+  case time.Time:
+    return value.Format(time.RFC3339)
+  case bson.ObjectId:
+    return value.Hex()
+  case bson.M:
+    if value == nil {
+      return ""
+    }
+    serialized, _ := json.Marshal(apiValue(v))
+    return string(serialized)
+  }
+
+  switch v.Kind() {
+  case reflect.Ptr:
+    if v.IsNil() {
+      return ""
+    }
+    return csvValue(v.Elem())
+  case reflect.Slice:
+    values := make([]string, v.Len())
+    for i := range values {
+      values[i] = csvValue(v.Index(i))
+    }
+    return strings.Join(values, ";")
+  default:
+    return fmt.Sprint(v.Interface())
+  }
+}
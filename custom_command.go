@@ -0,0 +1,674 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "regexp"
+  "strings"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// CustomCommandClientName is the name of the MgoDriver to use for CustomCommand.
+const CustomCommandClientName = "main"
+
+// CustomCommandDBName is the name of the database to use for CustomCommand.
+const CustomCommandDBName = "badpetbot"
+
+// CustomCommandColName is the name of the collection to use for CustomCommand.
+const CustomCommandColName = "custom_commands"
+
+// CustomCommandModelVersion is the current schema version of CustomCommand documents, stored in their ModelVersion.
+const CustomCommandModelVersion = 1
+
+// customCommandNamePattern is what a CustomCommand's Name must match.
+var customCommandNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// CustomCommandCol gets a collection reference for CustomCommand.
+func CustomCommandCol() *mgo.Collection {
+  return net.MgoCol(CustomCommandClientName, CustomCommandDBName, CustomCommandColName)
+}
+
+// Register CustomCommand, so tooling can find it.
+func init() {
+  RegisterModel("CustomCommand", CustomCommandCol, func() interface{} { return new(CustomCommand) })
+}
+
+// EnsureCustomCommandIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureCustomCommandIndices() error {
+
+  col := CustomCommandCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id", "name"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("CustomCommand", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_server_id: 1, name: 1 } unique
+
+// CustomCommand is a text command defined by a server's admins, which the bot responds to with a fixed
+// message, an embed, or both.
+type CustomCommand struct {
+  // ID is a BSON ID generated in Create.
+  ID                 bson.ObjectId   `bson:"_id"                    json:"_id"                    validate:"required"`
+  DiscordServerID    string          `bson:"discord_server_id"      json:"discord_server_id"      validate:"required,discord_id"`
+  // Name is the trigger word. It must match customCommandNamePattern.
+  Name               string          `bson:"name"                   json:"name"                   validate:"required"`
+  ResponseText       string          `bson:"response_text"          json:"response_text"          validate:"-"`
+  EmbedJSON          *string         `bson:"embed_json"             json:"embed_json"             validate:"-"`
+  CreatedByDiscordID string          `bson:"created_by_discord_id"  json:"created_by_discord_id"  validate:"required,discord_id"`
+  CreatedAt          time.Time       `bson:"created_at"             json:"created_at"             validate:"required"`
+  UpdatedAt          time.Time       `bson:"updated_at"             json:"updated_at"             validate:"required"`
+  DeletedAt          *time.Time      `bson:"deleted_at"             json:"deleted_at"             validate:"-"`
+  Version            int             `bson:"version"                json:"version"                validate:"-"`
+  ModelVersion       int             `bson:"model_version"          json:"model_version"          validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *CustomCommand) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "CustomCommand.Create", CustomCommandDBName, CustomCommandColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("CustomCommand", "Create", time.Now(), &err)
+  defer logSlowQuery("CustomCommand", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = CustomCommandModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the CustomCommand.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("CustomCommand", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *CustomCommand) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *CustomCommand) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *CustomCommand) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "CustomCommand.Update", CustomCommandDBName, CustomCommandColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("CustomCommand", "Update", time.Now(), &err)
+  defer logSlowQuery("CustomCommand", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheCustomCommand(net.RedisGetClient(CustomCommandClientName), this.cacheKeys())
+  }
+  return wrapDBError("CustomCommand", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *CustomCommand) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "CustomCommand.Delete", CustomCommandDBName, CustomCommandColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("CustomCommand", "Delete", time.Now(), &err)
+  defer logSlowQuery("CustomCommand", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the CustomCommand.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheCustomCommand(net.RedisGetClient(CustomCommandClientName), this.cacheKeys())
+  }
+  return wrapDBError("CustomCommand", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *CustomCommand) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *CustomCommand) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *CustomCommand) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("CustomCommand", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("CustomCommand", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheCustomCommand(net.RedisGetClient(CustomCommandClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *CustomCommand) Validate(ctx context.Context) error {
+
+  // Run the tag validations, then the rules tags can't express, collecting every failure.
+  err := wrapValidationError("CustomCommand", newValidator().StructCtx(ctx, this))
+  invalid := &ModelValidationError{Model: "CustomCommand"}
+  if !errors.As(err, &invalid) && err != nil {
+    return err
+  }
+  if this.Name != "" && !customCommandNamePattern.MatchString(this.Name) {
+    invalid.Errors = append(invalid.Errors, newValidationFieldError("Name", "name", "pattern", "", this.Name))
+  }
+  if this.ResponseText == "" && (this.EmbedJSON == nil || *this.EmbedJSON == "") {
+    invalid.Errors = append(invalid.Errors, newValidationFieldError("ResponseText", "response_text", "required_without", "", this.ResponseText))
+  }
+  if len(invalid.Errors) > 0 {
+    return invalid
+  }
+  return nil
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set. The name pattern is
+// checked if Name is given.
+func (this *CustomCommand) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("CustomCommand", this, fields)
+  if err != nil {
+    return err
+  }
+  err = wrapValidationError("CustomCommand", newValidator().StructPartialCtx(context.Background(), this, names...))
+  invalid := &ModelValidationError{Model: "CustomCommand"}
+  if !errors.As(err, &invalid) && err != nil {
+    return err
+  }
+  for _, name := range names {
+    if name == "Name" && this.Name != "" && !customCommandNamePattern.MatchString(this.Name) {
+      invalid.Errors = append(invalid.Errors, newValidationFieldError("Name", "name", "pattern", "", this.Name))
+    }
+  }
+  if len(invalid.Errors) > 0 {
+    return invalid
+  }
+  return nil
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *CustomCommand) Clone() *CustomCommand {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*CustomCommand)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *CustomCommand) Diff(other *CustomCommand) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *CustomCommand) DiffDeep(other *CustomCommand) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g.
+// "response_text". A nil EmbedJSON produces no key.
+func (this *CustomCommand) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *CustomCommand) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *CustomCommand) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *CustomCommand) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *CustomCommand) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *CustomCommand) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the CustomCommand is uninitialized, having neither an ID nor a Name, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *CustomCommand) IsZero() bool {
+  return this.ID == "" && this.Name == ""
+}
+
+// Age returns how long ago the CustomCommand was created.
+func (this *CustomCommand) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the CustomCommand was last updated.
+func (this *CustomCommand) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the CustomCommand hasn't been updated for longer than the threshold.
+func (this *CustomCommand) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetCustomCommand attempts to find the server's CustomCommand with the given name in cache before
+// looking in the database and setting cache if found. Commands are cached by the composite key
+// discord_server_id:name. If "negCache" is true, will check for neg-cache first, and also set
+// neg-cache if the document wasn't found in the database either.
+func CacheGetCustomCommand(serverID, name string, negCache bool) (found *CustomCommand, err error) {
+
+  client := net.RedisGetClient(CustomCommandClientName)
+  cacheKey := customCommandCacheKey(serverID, name)
+  _, span := startCacheSpan(context.Background(), "CustomCommand.CacheGet", CustomCommandDBName, CustomCommandColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("CustomCommand", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("CustomCommand", "CacheGet", "name", name, err)
+    } else if result != "" {
+      recordCacheResult("CustomCommand", "discord_server_id:name", "neg_hit")
+      return nil, &ModelNotFoundError{Model: "CustomCommand", Key: "name", Value: name}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("CustomCommand", "CacheGet", "name", name, err)
+  } else if result != "" {
+    recordCacheResult("CustomCommand", "discord_server_id:name", "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    command := new(CustomCommand)
+    if err := json.Unmarshal([]byte(result), command); err != nil {
+      return nil, wrapDBError("CustomCommand", "CacheGet", "name", name, err)
+    }
+    return command, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("CustomCommand", "discord_server_id:name", "miss")
+  command := new(CustomCommand)
+  err = net.MgoCol(CustomCommandClientName, CustomCommandDBName, CustomCommandColName).Find(notDeleted(bson.M{
+    "discord_server_id": serverID,
+    "name":              name,
+  })).One(command)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheCustomCommand(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheCustomCommand(client, cacheKey, command)
+  }
+  if err != nil {
+    return nil, wrapDBError("CustomCommand", "CacheGet", "name", name, err)
+  }
+  return command, nil
+}
+
+// customCommandCacheKey builds the composite cache key for the server's command with the given name.
+func customCommandCacheKey(serverID, name string) string {
+  return CustomCommandClientName+":"+CustomCommandDBName+":"+CustomCommandColName+":discord_server_id:name:"+serverID+":"+name
+}
+
+func fillCacheCustomCommand(client *redis.Client, key string, value *CustomCommand) {
+  _, span := startCacheSpan(context.Background(), "CustomCommand.fillCache", CustomCommandDBName, CustomCommandColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for CustomCommand")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for CustomCommand")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheCustomCommand(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "CustomCommand.fillNegCache", CustomCommandDBName, CustomCommandColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for CustomCommand")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheCustomCommand(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for CustomCommand")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetCustomCommand.
+func (this *CustomCommand) cacheKeys() []string {
+  return []string{
+    customCommandCacheKey(this.DiscordServerID, this.Name),
+  }
+}
+
+// Misc functions.
+
+// AggregateCustomCommands runs the aggregation pipeline on the CustomCommand collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateCustomCommands(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("CustomCommand", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(CustomCommandClientName, CustomCommandDBName, CustomCommandColName, pipeline, opts)
+  defer done()
+  return wrapDBError("CustomCommand", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateCustomCommandOne runs the aggregation pipeline on the CustomCommand collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateCustomCommandOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("CustomCommand", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(CustomCommandClientName, CustomCommandDBName, CustomCommandColName, pipeline, opts)
+  defer done()
+  return wrapDBError("CustomCommand", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyCustomCommand atomically applies the update to the first CustomCommand matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyCustomCommand(selector, update bson.M, returnNew bool) (_ *CustomCommand, err error) {
+
+  defer observeOperation("CustomCommand", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(CustomCommand)
+  _, err = CustomCommandCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("CustomCommand", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheCustomCommand(net.RedisGetClient(CustomCommandClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindCustomCommands finds all CustomCommands matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindCustomCommands(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*CustomCommand, err error) {
+
+  defer observeOperation("CustomCommand", "Find", time.Now(), &err)
+  defer logSlowQuery("CustomCommand", "Find", filter)()
+
+  results := []*CustomCommand{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("CustomCommand", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindCustomCommandsWithDeleted finds all CustomCommands matching the filter, including soft-deleted ones.
+func FindCustomCommandsWithDeleted(filter bson.M) (_ []*CustomCommand, err error) {
+
+  defer observeOperation("CustomCommand", "Find", time.Now(), &err)
+
+  results := []*CustomCommand{}
+  if err := net.MgoCol(CustomCommandClientName, CustomCommandDBName, CustomCommandColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("CustomCommand", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountCustomCommands counts the CustomCommands matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountCustomCommands(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("CustomCommand", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(CustomCommandClientName, CustomCommandDBName, CustomCommandColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("CustomCommand", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// CustomCommandExists reports whether any CustomCommand matches the filter, excluding soft-deleted ones.
+func CustomCommandExists(filter bson.M) (bool, error) {
+
+  count, err := CountCustomCommands(filter)
+  return count > 0, err
+}
+
+// DistinctCustomCommandFields finds the distinct values of the field among the CustomCommands matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctCustomCommandFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("CustomCommand", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, CustomCommandClientName, CustomCommandDBName, CustomCommandColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("CustomCommand", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctCustomCommandStringFields is DistinctCustomCommandFields for string fields, like name. It fails if
+// any of the values isn't a string.
+func DistinctCustomCommandStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctCustomCommandFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactCustomCommandCollection runs the compact command against the CustomCommand collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactCustomCommandCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName)
+}
+
+// CustomCommandCollectionStats returns the CustomCommand collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func CustomCommandCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName)
+}
+
+// ExplainCustomCommandQuery returns MongoDB's explain output for finding the filter in the CustomCommand collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainCustomCommandQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName, filter)
+}
+
+// UpdateManyCustomCommands applies the updates to every CustomCommand matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateCustomCommands for that. The CustomCommand cache is flushed.
+func UpdateManyCustomCommands(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName, filter, updates)
+}
+
+// BulkUpdateCustomCommands is UpdateManyCustomCommands, but validates each CustomCommand as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateCustomCommands(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName, filter, updates, func() validatable { return new(CustomCommand) })
+}
+
+// DeleteManyCustomCommands soft-deletes every CustomCommand matching the filter in a single query, returning how many
+// were deleted. The CustomCommand cache is evicted.
+func DeleteManyCustomCommands(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "CustomCommand", CustomCommandClientName, CustomCommandDBName, CustomCommandColName, filter)
+}
+
+// FindCommandByName finds the server's command with the given trigger word, using the cache.
+func FindCommandByName(serverID, name string) (*CustomCommand, error) {
+
+  return CacheGetCustomCommand(serverID, strings.ToLower(name), true)
+}
+
+// FindCommandsByServer finds all of the server's commands, sorted by name. This does not touch the
+// cache.
+func FindCommandsByServer(serverID string) ([]*CustomCommand, error) {
+
+  return FindCustomCommands(context.Background(), bson.M{"discord_server_id": serverID}, "name", 0)
+}
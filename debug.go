@@ -0,0 +1,50 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// ErrDebugModeDisabled is returned by debugging helpers, like ExplainServerMemberQuery, unless
+// Config.DebugMode is set.
+var ErrDebugModeDisabled = errors.New("debug mode is disabled")
+
+// explainQuery returns the explain output for finding the filter in the model's collection.
+func explainQuery(ctx context.Context, model, client, database, collection string, filter bson.M) (_ bson.M, err error) {
+
+  defer observeOperation(model, "Explain", time.Now(), &err)
+
+  if !getConfig().DebugMode {
+    return nil, ErrDebugModeDisabled
+  }
+  explain := bson.M{}
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Find(filter).Explain(explain)
+  })
+  if err != nil {
+    return nil, wrapDBError(model, "Explain", "", "", err)
+  }
+  return explain, nil
+}
+
+// WinningPlan returns the stage name of the winning plan in explain output, like "IXSCAN", "FETCH", or
+// "COLLSCAN". It returns an empty string if the output has no winning plan.
+func WinningPlan(explain bson.M) string {
+
+  planner, _ := explain["queryPlanner"].(bson.M)
+  plan, _ := planner["winningPlan"].(bson.M)
+
+  // Servers using the slot-based engine nest the plan one level deeper.
+  if queryPlan, ok := plan["queryPlan"].(bson.M); ok {
+    plan = queryPlan
+  }
+  stage, _ := plan["stage"].(string)
+  return stage
+}
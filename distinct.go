@@ -0,0 +1,37 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "fmt"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// distinctValues finds the distinct values of the field among the collection's documents matching the
+// filter.
+func distinctValues(ctx context.Context, client, database, collection, field string, filter bson.M) ([]interface{}, error) {
+
+  values := []interface{}{}
+  err := mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Find(filter).Distinct(field, &values)
+  })
+  return values, err
+}
+
+// distinctStrings converts the field's distinct values into strings, failing if any of them isn't one.
+func distinctStrings(field string, values []interface{}) ([]string, error) {
+
+  out := make([]string, 0, len(values))
+  for _, value := range values {
+    s, ok := value.(string)
+    if !ok {
+      return nil, fmt.Errorf("can't use %T value of %q as a string", value, field)
+    }
+    out = append(out, s)
+  }
+  return out, nil
+}
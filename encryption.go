@@ -0,0 +1,146 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "crypto/aes"
+  "crypto/cipher"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/base64"
+  "errors"
+  "io"
+  "strings"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+  "golang.org/x/crypto/hkdf"
+)
+
+// encryptedPrefix marks stored values as ciphertext, so values stored before encryption was enabled
+// are still read as plaintext.
+const encryptedPrefix = "enc:"
+
+// Subkeys are derived from Config.EncryptionKey for each use, so the cipher and nonce derivation never
+// share a key.
+const (
+  cipherKeyInfo = "gomodel encrypted string cipher"
+  nonceKeyInfo  = "gomodel encrypted string nonce"
+)
+
+// EncryptedString is a string which is stored in the database encrypted with AES-256-GCM, using a key
+// derived from Config.EncryptionKey, and decrypted automatically when read. In memory and in JSON (and so in
+// cache) it is plaintext. If no key is configured, it is stored as plaintext.
+//
+// Encryption is deterministic, so the same plaintext always produces the same ciphertext and encrypted
+// fields can still be queried by equality, using EncryptValue or an EncryptedString in the filter. Values
+// stored before encryption was enabled stay plaintext until they're rewritten, so queries should match
+// either, with MatchEncrypted.
+type EncryptedString string
+
+// GetBSON encrypts the string for storage.
+func (this EncryptedString) GetBSON() (interface{}, error) {
+
+  return EncryptValue(string(this))
+}
+
+// SetBSON decrypts the stored string.
+func (this *EncryptedString) SetBSON(raw bson.Raw) error {
+
+  var stored string
+  if err := raw.Unmarshal(&stored); err != nil {
+    return err
+  }
+  plaintext, err := decryptValue(stored)
+  if err != nil {
+    return err
+  }
+  *this = EncryptedString(plaintext)
+  return nil
+}
+
+// EncryptValue encrypts plaintext the way EncryptedString is stored, for building queries against
+// encrypted fields. If no key is configured, plaintext is returned as-is.
+func EncryptValue(plaintext string) (string, error) {
+
+  key := getConfig().EncryptionKey
+  if len(key) == 0 {
+    return plaintext, nil
+  }
+  gcm, err := newGCM(key)
+  if err != nil {
+    return "", err
+  }
+  nonceKey, err := deriveKey(key, nonceKeyInfo)
+  if err != nil {
+    return "", err
+  }
+
+  // Derive the nonce from the plaintext so equal values encrypt equally.
+  mac := hmac.New(sha256.New, nonceKey)
+  mac.Write([]byte(plaintext))
+  nonce := mac.Sum(nil)[:gcm.NonceSize()]
+  sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+  return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// MatchEncrypted returns a query value matching an EncryptedString field holding the plaintext, whether
+// it was stored encrypted or as plaintext, before encryption was enabled.
+func MatchEncrypted(plaintext string) bson.M {
+
+  return bson.M{"$in": []interface{}{EncryptedString(plaintext), plaintext}}
+}
+
+// decryptValue decrypts a value stored by EncryptValue. Values without the encrypted prefix are
+// returned as-is.
+func decryptValue(stored string) (string, error) {
+
+  if !strings.HasPrefix(stored, encryptedPrefix) {
+    return stored, nil
+  }
+  key := getConfig().EncryptionKey
+  if len(key) == 0 {
+    return "", errors.New("can't decrypt value: no encryption key configured")
+  }
+  gcm, err := newGCM(key)
+  if err != nil {
+    return "", err
+  }
+  sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+  if err != nil {
+    return "", err
+  }
+  if len(sealed) < gcm.NonceSize() {
+    return "", errors.New("can't decrypt value: ciphertext too short")
+  }
+  plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+  if err != nil {
+    return "", err
+  }
+  return string(plaintext), nil
+}
+
+// newGCM creates an AES-GCM cipher with the subkey derived from the key for it.
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+  cipherKey, err := deriveKey(key, cipherKeyInfo)
+  if err != nil {
+    return nil, err
+  }
+  block, err := aes.NewCipher(cipherKey)
+  if err != nil {
+    return nil, err
+  }
+  return cipher.NewGCM(block)
+}
+
+// deriveKey derives the subkey for the use described by info from the key with HKDF-SHA256. The subkey
+// is as long as the key, so AES-256 keys derive AES-256 subkeys.
+func deriveKey(key []byte, info string) ([]byte, error) {
+
+  subkey := make([]byte, len(key))
+  if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(info)), subkey); err != nil {
+    return nil, err
+  }
+  return subkey, nil
+}
@@ -0,0 +1,128 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "bytes"
+  "context"
+  "strings"
+  "testing"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// configureEncryptionKey configures an encryption key for the rest of the test.
+func configureEncryptionKey(t *testing.T) []byte {
+
+  previous := getConfig()
+  key := bytes.Repeat([]byte{7}, 32)
+  cfg := previous
+  cfg.EncryptionKey = key
+  if err := Configure(cfg); err != nil {
+    t.Fatalf("Configure: %v", err)
+  }
+  t.Cleanup(func() { Configure(previous) })
+  return key
+}
+
+func TestEncryptedStringRoundTrip(t *testing.T) {
+
+  configureEncryptionKey(t)
+  plaintext := strings.Repeat("1", 18)
+
+  // The stored value is ciphertext, and the same every time.
+  stored, err := EncryptValue(plaintext)
+  if err != nil {
+    t.Fatalf("EncryptValue: %v", err)
+  }
+  if !strings.HasPrefix(stored, encryptedPrefix) || strings.Contains(stored, plaintext) {
+    t.Errorf("got stored value %q, want ciphertext", stored)
+  }
+  if again, _ := EncryptValue(plaintext); again != stored {
+    t.Errorf("got %q encrypting again, want %q", again, stored)
+  }
+
+  // It decrypts back through BSON.
+  raw, err := bson.Marshal(bson.M{"value": EncryptedString(plaintext)})
+  if err != nil {
+    t.Fatalf("Marshal: %v", err)
+  }
+  decoded := struct {
+    Value EncryptedString `bson:"value"`
+  }{}
+  if err := bson.Unmarshal(raw, &decoded); err != nil {
+    t.Fatalf("Unmarshal: %v", err)
+  }
+  if string(decoded.Value) != plaintext {
+    t.Errorf("got %q after a round trip, want %q", decoded.Value, plaintext)
+  }
+}
+
+func TestEncryptedStringLegacyPlaintext(t *testing.T) {
+
+  configureEncryptionKey(t)
+  plaintext := strings.Repeat("1", 18)
+
+  // Values stored before encryption was enabled are read as-is.
+  raw, err := bson.Marshal(bson.M{"value": plaintext})
+  if err != nil {
+    t.Fatalf("Marshal: %v", err)
+  }
+  decoded := struct {
+    Value EncryptedString `bson:"value"`
+  }{}
+  if err := bson.Unmarshal(raw, &decoded); err != nil {
+    t.Fatalf("Unmarshal: %v", err)
+  }
+  if string(decoded.Value) != plaintext {
+    t.Errorf("got %q for a plaintext value, want it as-is", decoded.Value)
+  }
+}
+
+func TestEncryptionSubkeys(t *testing.T) {
+
+  key := configureEncryptionKey(t)
+  cipherKey, err := deriveKey(key, cipherKeyInfo)
+  if err != nil {
+    t.Fatalf("deriveKey: %v", err)
+  }
+  nonceKey, err := deriveKey(key, nonceKeyInfo)
+  if err != nil {
+    t.Fatalf("deriveKey: %v", err)
+  }
+  if len(cipherKey) != len(key) || bytes.Equal(cipherKey, key) || bytes.Equal(nonceKey, key) || bytes.Equal(cipherKey, nonceKey) {
+    t.Errorf("want distinct %d-byte subkeys for the cipher and nonce", len(key))
+  }
+}
+
+func TestMatchEncryptedFindsLegacyPlaintext(t *testing.T) {
+
+  configureEncryptionKey(t)
+  store := injectServerMemberStore(t)
+
+  // One ServerMember is stored encrypted, and the other as plaintext, from before encryption.
+  encrypted := newTestServerMember("1")
+  if err := encrypted.Create(context.Background()); err != nil {
+    t.Fatalf("Create: %v", err)
+  }
+  legacy := newTestServerMember("2")
+  legacy.ID = bson.NewObjectId()
+  legacy.DiscordUserID = encrypted.DiscordUserID
+  doc, err := toBSONDoc(legacy)
+  if err != nil {
+    t.Fatalf("toBSONDoc: %v", err)
+  }
+  doc["discord_user_id"] = string(encrypted.DiscordUserID)
+  if err := store.Insert(doc); err != nil {
+    t.Fatalf("Insert: %v", err)
+  }
+
+  members := []*ServerMember{}
+  if err := store.FindMany(bson.M{"discord_user_id": MatchEncrypted(string(encrypted.DiscordUserID))}, &members); err != nil {
+    t.Fatalf("FindMany: %v", err)
+  }
+  if len(members) != 2 {
+    t.Errorf("got %d ServerMembers, want both the encrypted and plaintext ones", len(members))
+  }
+}
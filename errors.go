@@ -0,0 +1,200 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "errors"
+  "fmt"
+  "strings"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  validator "github.com/go-playground/validator/v10"
+)
+
+// ErrVersionConflict is returned by UpdateWithVersion when the stored document's version no longer
+// matches the expected one, meaning someone else updated it first.
+var ErrVersionConflict = errors.New("document version conflict")
+
+// ErrSameOwner is returned by TransferOwnership when the new owner is already the owner.
+var ErrSameOwner = errors.New("new owner is already the owner")
+
+// ErrAlreadyRevoked is returned by RoleAssignment's Revoke when the assignment was already revoked.
+var ErrAlreadyRevoked = errors.New("role assignment is already revoked")
+
+// ErrIdempotencyKeyInProgress is returned by CreateIdempotent when another call with the same
+// idempotency key is still creating its document.
+var ErrIdempotencyKeyInProgress = errors.New("idempotency key is in progress")
+
+// ErrQueryTimeout is matched by errors returned from queries which MongoDB aborted for running longer
+// than their max time, set with WithMaxTime or Config.DefaultMaxQueryTime.
+var ErrQueryTimeout = errors.New("query exceeded its max time")
+
+// maxTimeExpiredCode is the MongoDB error code for operations aborted by maxTimeMS.
+const maxTimeExpiredCode = 50
+
+// ModelNotFoundError is returned when no document of the model exists for the key and value looked up.
+// For compatibility, errors.Is reports it as mgo.ErrNotFound.
+type ModelNotFoundError struct {
+  Model string
+  Key   string
+  Value string
+}
+
+func (this *ModelNotFoundError) Error() string {
+  if this.Key == "" {
+    return this.Model + " not found"
+  }
+  return fmt.Sprintf("%s not found by %s %q", this.Model, this.Key, this.Value)
+}
+
+// Is reports whether target is mgo.ErrNotFound, so existing checks keep working via errors.Is.
+func (this *ModelNotFoundError) Is(target error) bool {
+  return target == mgo.ErrNotFound
+}
+
+// ValidationFieldError describes a single field which failed validation.
+type ValidationFieldError struct {
+  // Field is the Go field name, and BSONKey the bson field name. Both include the index for slice elements.
+  Field   string
+  BSONKey string
+  // Tag is the violated rule.
+  Tag     string
+  Value   interface{}
+  // Message is a human-readable description of the failure.
+  Message string
+}
+
+// newValidationFieldError describes the field's failure of the rule, generating its message.
+func newValidationFieldError(field, bsonKey, tag, param string, value interface{}) ValidationFieldError {
+
+  return ValidationFieldError{
+    Field:   field,
+    BSONKey: bsonKey,
+    Tag:     tag,
+    Value:   value,
+    Message: validationMessage(field, tag, param),
+  }
+}
+
+// validationMessage describes the field's failure of the rule in plain words.
+func validationMessage(field, tag, param string) string {
+
+  switch tag {
+  case "required":
+    return field+" is required"
+  case "required_without":
+    return field+" is required unless another field is set"
+  case "discord_id":
+    return field+" must be a Discord ID"
+  case "pattern":
+    return field+" has an invalid format"
+  case "oneof":
+    return field+" must be one of: "+param
+  case "gt":
+    return field+" must be greater than "+param
+  case "gte", "min":
+    return field+" must be at least "+param
+  case "lt":
+    return field+" must be less than "+param
+  case "lte", "max":
+    return field+" must be at most "+param
+  default:
+    return fmt.Sprintf("%s failed the %s rule", field, tag)
+  }
+}
+
+// ModelValidationError is returned when a document fails validation, listing every failing field.
+type ModelValidationError struct {
+  Model  string
+  Errors []ValidationFieldError
+}
+
+func (this *ModelValidationError) Error() string {
+  fields := make([]string, len(this.Errors))
+  for i, fieldErr := range this.Errors {
+    fields[i] = fmt.Sprintf("%s (%s)", fieldErr.Field, fieldErr.Tag)
+  }
+  return fmt.Sprintf("%s failed validation: %s", this.Model, strings.Join(fields, ", "))
+}
+
+// ModelDatabaseError is returned when a database or cache operation fails for any reason other than a
+// missing document. The cause is available via errors.Unwrap.
+type ModelDatabaseError struct {
+  Model     string
+  Operation string
+  Cause     error
+}
+
+func (this *ModelDatabaseError) Error() string {
+  return fmt.Sprintf("%s %s failed: %v", this.Model, this.Operation, this.Cause)
+}
+
+func (this *ModelDatabaseError) Unwrap() error {
+  return this.Cause
+}
+
+// wrapDBError wraps err for the model and operation. mgo.ErrNotFound becomes a ModelNotFoundError for
+// the given key and value, and anything else becomes a ModelDatabaseError, whose cause matches
+// ErrQueryTimeout if MongoDB aborted the query for exceeding its max time. Nil errors and errors which
+// are already wrapped are returned as-is.
+func wrapDBError(model, operation, key, value string, err error) error {
+
+  var notFound *ModelNotFoundError
+  var invalid *ModelValidationError
+  var database *ModelDatabaseError
+  switch {
+  case err == nil, errors.As(err, &notFound), errors.As(err, &invalid), errors.As(err, &database):
+    return err
+  case err == mgo.ErrNotFound:
+    return &ModelNotFoundError{Model: model, Key: key, Value: value}
+  case isMaxTimeExpired(err):
+    return &ModelDatabaseError{Model: model, Operation: operation, Cause: fmt.Errorf("%w: %s", ErrQueryTimeout, err.Error())}
+  default:
+    return &ModelDatabaseError{Model: model, Operation: operation, Cause: err}
+  }
+}
+
+// isMaxTimeExpired reports whether MongoDB aborted the operation for exceeding its max time.
+func isMaxTimeExpired(err error) bool {
+
+  var queryErr *mgo.QueryError
+  if errors.As(err, &queryErr) {
+    return queryErr.Code == maxTimeExpiredCode
+  }
+  var lastErr *mgo.LastError
+  return errors.As(err, &lastErr) && lastErr.Code == maxTimeExpiredCode
+}
+
+// wrapValidationError converts validator errors into a ModelValidationError for the model. Other
+// errors are returned as-is.
+func wrapValidationError(model string, err error) error {
+
+  var fieldErrs validator.ValidationErrors
+  if !errors.As(err, &fieldErrs) {
+    return err
+  }
+  out := &ModelValidationError{Model: model, Errors: make([]ValidationFieldError, len(fieldErrs))}
+  for i, fieldErr := range fieldErrs {
+    // Validators register bson names as field names, so StructField is the Go name.
+    out.Errors[i] = newValidationFieldError(fieldErr.StructField(), fieldErr.Field(), fieldErr.Tag(), fieldErr.Param(), fieldErr.Value())
+  }
+  return out
+}
+
+// FormatValidationError summarizes a validation error as its fields' messages, comma-joined. Other
+// errors are formatted as-is.
+func FormatValidationError(err error) string {
+
+  var invalid *ModelValidationError
+  if err == nil {
+    return ""
+  } else if !errors.As(err, &invalid) {
+    return err.Error()
+  }
+  messages := make([]string, len(invalid.Errors))
+  for i, fieldErr := range invalid.Errors {
+    messages[i] = fieldErr.Message
+  }
+  return strings.Join(messages, ", ")
+}
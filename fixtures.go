@@ -0,0 +1,147 @@
+//go:build integration
+// +build integration
+
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// Fixture modes, for LoadFixtures.
+const (
+  // FixtureModeInsert inserts documents, skipping those whose _id already exists.
+  FixtureModeInsert  = "insert"
+  // FixtureModeUpsert inserts documents, merging fields into those whose _id already exists.
+  FixtureModeUpsert  = "upsert"
+  // FixtureModeReplace inserts documents, replacing those whose _id already exists.
+  FixtureModeReplace = "replace"
+)
+
+// fixtureCollection is a collection which can be seeded with fixtures and truncated.
+type fixtureCollection struct {
+  client     string
+  database   string
+  collection string
+  // newSlice returns a pointer to an empty slice of the collection's model.
+  newSlice   func() interface{}
+}
+
+// fixtureCollections are every model's collections. A collection's fixtures file is named after it,
+// e.g. servers.json.
+var fixtureCollections = []fixtureCollection{
+  {ServerClientName, ServerDBName, ServerColName, func() interface{} { return &[]*Server{} }},
+  {ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func() interface{} { return &[]*ServerMember{} }},
+  {BanClientName, BanDBName, BanColName, func() interface{} { return &[]*Ban{} }},
+  {WarningClientName, WarningDBName, WarningColName, func() interface{} { return &[]*Warning{} }},
+  {ServerConfigClientName, ServerConfigDBName, ServerConfigColName, func() interface{} { return &[]*ServerConfig{} }},
+  {AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, func() interface{} { return &[]*AuditLogEntry{} }},
+  {CustomCommandClientName, CustomCommandDBName, CustomCommandColName, func() interface{} { return &[]*CustomCommand{} }},
+  {ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, func() interface{} { return &[]*ReactionRole{} }},
+  {ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, func() interface{} { return &[]*ScheduledTask{} }},
+  {UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, func() interface{} { return &[]*UserPreference{} }},
+  {TagClientName, TagDBName, TagColName, func() interface{} { return &[]*Tag{} }},
+  {LeaderboardClientName, LeaderboardDBName, LeaderboardColName, func() interface{} { return &[]*Leaderboard{} }},
+  {RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, func() interface{} { return &[]*RoleAssignment{} }},
+}
+
+// LoadFixtures seeds collections from the JSON files in the directory, each named after its collection
+// (e.g. servers.json, server_members.json) and holding an array of documents in their JSON form. Files
+// for collections which don't exist are ignored, as are collections without a file. mode is one of the
+// FixtureMode* constants, deciding what happens to documents whose _id already exists.
+func LoadFixtures(path, mode string) error {
+
+  if mode != FixtureModeInsert && mode != FixtureModeUpsert && mode != FixtureModeReplace {
+    return fmt.Errorf("unknown fixture mode %q", mode)
+  }
+
+  for _, fixture := range fixtureCollections {
+    data, err := ioutil.ReadFile(filepath.Join(path, fixture.collection+".json"))
+    if os.IsNotExist(err) {
+      continue
+    } else if err != nil {
+      return err
+    }
+    docs := fixture.newSlice()
+    if err := json.Unmarshal(data, docs); err != nil {
+      return fmt.Errorf("can't load %s fixtures: %s", fixture.collection, err.Error())
+    }
+
+    err = mgoDo(context.Background(), fixture.client, fixture.database, fixture.collection, func(col *mgo.Collection) error {
+      slice := reflect.ValueOf(docs).Elem()
+      for i := 0; i < slice.Len(); i++ {
+        if err := loadFixture(col, mode, slice.Index(i).Interface()); err != nil {
+          return err
+        }
+      }
+      return nil
+    })
+    if err != nil {
+      return fmt.Errorf("can't load %s fixtures: %s", fixture.collection, err.Error())
+    }
+    flushCollectionCache(fixture.client, fixture.database, fixture.collection)
+  }
+  return nil
+}
+
+// loadFixture writes a single fixture document according to the mode.
+func loadFixture(col *mgo.Collection, mode string, doc interface{}) error {
+
+  id := reflect.ValueOf(doc).Elem().FieldByName("ID").Interface().(bson.ObjectId)
+  if !id.Valid() {
+    return errors.New("fixture is missing its _id")
+  }
+
+  switch mode {
+  case FixtureModeUpsert:
+    set, err := bsonFields(doc, "_id")
+    if err != nil {
+      return err
+    }
+    _, err = col.UpsertId(id, bson.M{"$set": set})
+    return err
+  case FixtureModeReplace:
+    _, err := col.UpsertId(id, doc)
+    return err
+  default:
+    if err := col.Insert(doc); err != nil && !mgo.IsDup(err) {
+      return err
+    }
+    return nil
+  }
+}
+
+// TruncateAll removes every document from every model's collection, and flushes their caches. It's only
+// built with the integration build tag, so it can't run against production data by accident.
+func TruncateAll(ctx context.Context) error {
+
+  failures := []string{}
+  for _, fixture := range fixtureCollections {
+    err := mgoDo(ctx, fixture.client, fixture.database, fixture.collection, func(col *mgo.Collection) error {
+      _, err := col.RemoveAll(bson.M{})
+      return err
+    })
+    if err != nil {
+      failures = append(failures, fixture.collection+": "+err.Error())
+      continue
+    }
+    flushCollectionCache(fixture.client, fixture.database, fixture.collection)
+  }
+  if len(failures) > 0 {
+    return errors.New("error truncating collections: " + strings.Join(failures, "; "))
+  }
+  return nil
+}
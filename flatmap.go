@@ -0,0 +1,172 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "fmt"
+  "reflect"
+  "strconv"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// timeType is reflected once, since time.Time is a struct but is flattened as a single value.
+var timeType = reflect.TypeOf(time.Time{})
+
+// objectIdType is reflected once, since hex strings are accepted for ObjectId fields.
+var objectIdType = reflect.TypeOf(bson.ObjectId(""))
+
+// toFlatMap flattens the struct pointed to by doc into a single-level map keyed by bson field name.
+// Embedded documents and maps use dotted keys (e.g. "owner.discord_member_id"), and slices use their
+// indices (e.g. "sec_owner_discord_ids.0"). Nil pointers, slices, and maps produce no keys, so empty
+// slices and maps don't survive a round trip through fromFlatMap.
+func toFlatMap(doc interface{}) map[string]interface{} {
+
+  flat := map[string]interface{}{}
+  flattenInto(flat, "", reflect.ValueOf(doc))
+  return flat
+}
+
+// flattenInto adds v to flat under key, recursing into structs, maps, and slices.
+func flattenInto(flat map[string]interface{}, key string, v reflect.Value) {
+
+  prefix := key
+  if prefix != "" {
+    prefix += "."
+  }
+
+  switch v.Kind() {
+  case reflect.Ptr, reflect.Interface:
+    if !v.IsNil() {
+      flattenInto(flat, key, v.Elem())
+    }
+  case reflect.Struct:
+    if v.Type() == timeType {
+      flat[key] = v.Interface()
+      return
+    }
+    for i := 0; i < v.NumField(); i++ {
+      if name, _ := bsonFieldName(v.Type().Field(i)); name != "" {
+        flattenInto(flat, prefix+name, v.Field(i))
+      }
+    }
+  case reflect.Slice:
+    for i := 0; i < v.Len(); i++ {
+      flattenInto(flat, prefix+strconv.Itoa(i), v.Index(i))
+    }
+  case reflect.Map:
+    for _, mapKey := range v.MapKeys() {
+      flattenInto(flat, prefix+fmt.Sprint(mapKey.Interface()), v.MapIndex(mapKey))
+    }
+  default:
+    flat[key] = v.Interface()
+  }
+}
+
+// fromFlatMap populates the struct pointed to by doc from a map produced by toFlatMap. Keys not present
+// in the map are left as they are. Values are converted to the field's type where possible, and hex
+// strings are accepted for ObjectIds.
+func fromFlatMap(doc interface{}, flat map[string]interface{}) error {
+
+  for key, value := range flat {
+    if err := setFlatValue(reflect.ValueOf(doc).Elem(), strings.Split(key, "."), value); err != nil {
+      return fmt.Errorf("can't set %q: %s", key, err.Error())
+    }
+  }
+  return nil
+}
+
+// setFlatValue sets the value at the path within v, allocating pointers, slices, and maps on the way.
+func setFlatValue(v reflect.Value, path []string, value interface{}) error {
+
+  // Set the value once the end of the path is reached.
+  if len(path) == 0 {
+    return setConverted(v, value)
+  }
+
+  switch v.Kind() {
+  case reflect.Ptr:
+    if v.IsNil() {
+      v.Set(reflect.New(v.Type().Elem()))
+    }
+    return setFlatValue(v.Elem(), path, value)
+  case reflect.Struct:
+    for i := 0; i < v.NumField(); i++ {
+      if name, _ := bsonFieldName(v.Type().Field(i)); name == path[0] {
+        return setFlatValue(v.Field(i), path[1:], value)
+      }
+    }
+    return fmt.Errorf("unknown field %q", path[0])
+  case reflect.Slice:
+    index, err := strconv.Atoi(path[0])
+    if err != nil || index < 0 {
+      return fmt.Errorf("invalid index %q", path[0])
+    }
+    for v.Len() <= index {
+      v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+    }
+    return setFlatValue(v.Index(index), path[1:], value)
+  case reflect.Map:
+    if v.IsNil() {
+      v.Set(reflect.MakeMap(v.Type()))
+    }
+    mapKey := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+    elem := reflect.New(v.Type().Elem()).Elem()
+    if existing := v.MapIndex(mapKey); existing.IsValid() {
+      elem.Set(existing)
+    }
+    if err := setFlatValue(elem, path[1:], value); err != nil {
+      return err
+    }
+    v.SetMapIndex(mapKey, elem)
+    return nil
+  case reflect.Interface:
+    // Nested keys under an untyped value become embedded documents.
+    if _, ok := v.Interface().(bson.M); !ok {
+      v.Set(reflect.ValueOf(bson.M{}))
+    }
+    return setFlatValue(v.Elem(), path, value)
+  default:
+    return fmt.Errorf("can't set nested field %q on %s", path[0], v.Type())
+  }
+}
+
+// setConverted sets v to value, converting it to v's type if needed.
+func setConverted(v reflect.Value, value interface{}) error {
+
+  if value == nil {
+    v.Set(reflect.Zero(v.Type()))
+    return nil
+  }
+  given := reflect.ValueOf(value)
+
+  switch {
+  case given.Type().AssignableTo(v.Type()):
+    v.Set(given)
+  case v.Type() == objectIdType && given.Kind() == reflect.String:
+    if !bson.IsObjectIdHex(given.String()) {
+      return fmt.Errorf("invalid ObjectId %q", given.String())
+    }
+    v.Set(reflect.ValueOf(bson.ObjectIdHex(given.String())))
+  case v.Kind() == reflect.Ptr:
+    elem := reflect.New(v.Type().Elem())
+    if err := setConverted(elem.Elem(), value); err != nil {
+      return err
+    }
+    v.Set(elem)
+  case given.Type().ConvertibleTo(v.Type()) && (given.Kind() == v.Kind() || isNumeric(given.Kind()) && isNumeric(v.Kind())):
+    v.Set(given.Convert(v.Type()))
+  default:
+    return fmt.Errorf("can't use %s as %s", given.Type(), v.Type())
+  }
+  return nil
+}
+
+// isNumeric reports whether values of the kind are numbers.
+func isNumeric(kind reflect.Kind) bool {
+
+  return kind >= reflect.Int && kind <= reflect.Float64
+}
@@ -5,8 +5,14 @@ go 1.14
 require (
 	github.com/badpetbot/gocommon v0.0.0-20211009221702-8962210fd7eb
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8
+	github.com/go-playground/validator/v10 v10.4.1
 	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/prometheus/client_golang v1.12.2
 	github.com/rs/zerolog v1.25.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/sync v0.0.0-20220907140024-f12130a52804
 )
 
 replace github.com/globalsign/mgo => github.com/Nifty255/mgo v0.0.0-20200423052436-ae3b558ebcf4
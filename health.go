@@ -0,0 +1,72 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// HealthStatus is the result of CheckHealth. MongoDB and Redis are true if every client the models use
+// for them responded.
+type HealthStatus struct {
+  MongoDB bool    `json:"mongodb"`
+  Redis   bool    `json:"redis"`
+  Errors  []error `json:"-"`
+}
+
+// CheckHealth pings MongoDB and Redis with every client the models use, within ctx's deadline. Every
+// client is pinged even if some fail, so the status reports on both. The error joins every failure, or
+// is nil if everything responded.
+func CheckHealth(ctx context.Context) (*HealthStatus, error) {
+
+  status := &HealthStatus{MongoDB: true, Redis: true}
+  for _, client := range healthClients() {
+    err := mgoDo(ctx, client, "admin", "", func(col *mgo.Collection) error {
+      return col.Database.Session.Ping()
+    })
+    if err != nil {
+      status.MongoDB = false
+      status.Errors = append(status.Errors, fmt.Errorf("can't ping MongoDB client %q: %s", client, err.Error()))
+    }
+    if err := net.RedisGetClient(client).WithContext(ctx).Ping().Err(); err != nil {
+      status.Redis = false
+      status.Errors = append(status.Errors, fmt.Errorf("can't ping Redis client %q: %s", client, err.Error()))
+    }
+  }
+
+  if len(status.Errors) > 0 {
+    failures := make([]string, len(status.Errors))
+    for i, err := range status.Errors {
+      failures[i] = err.Error()
+    }
+    return status, errors.New("unhealthy: " + strings.Join(failures, "; "))
+  }
+  return status, nil
+}
+
+// healthClients returns the names of every client the models use, sorted.
+func healthClients() []string {
+
+  seen := map[string]bool{}
+  seen[LockClientName] = true
+  seen[MigrationRecordClientName] = true
+  for _, location := range modelCollections {
+    seen[location.client] = true
+  }
+  clients := make([]string, 0, len(seen))
+  for client := range seen {
+    clients = append(clients, client)
+  }
+  sort.Strings(clients)
+  return clients
+}
@@ -0,0 +1,36 @@
+package gomodel
+
+// Hook events, for registering functions to run around a model's operations. A before_* hook returning
+// an error aborts the operation.
+const (
+  HookBeforeCreate = "before_create"
+  HookAfterCreate  = "after_create"
+  HookBeforeUpdate = "before_update"
+  HookAfterUpdate  = "after_update"
+  HookBeforeDelete = "before_delete"
+  HookAfterDelete  = "after_delete"
+)
+
+// Validate hook events, for registering functions to run around a model's validations. A
+// before_validate hook can normalize fields before they're validated, and an after_validate hook can
+// run checks which don't belong in struct tags. Either returning an error fails validation.
+const (
+  HookBeforeValidate = "before_validate"
+  HookAfterValidate  = "after_validate"
+)
+
+// hookEvents are the events hooks can be registered for.
+var hookEvents = map[string]bool{
+  HookBeforeCreate: true,
+  HookAfterCreate:  true,
+  HookBeforeUpdate: true,
+  HookAfterUpdate:  true,
+  HookBeforeDelete: true,
+  HookAfterDelete:  true,
+}
+
+// validateHookEvents are the events validate hooks can be registered for.
+var validateHookEvents = map[string]bool{
+  HookBeforeValidate: true,
+  HookAfterValidate:  true,
+}
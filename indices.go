@@ -0,0 +1,46 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "errors"
+  "strings"
+)
+
+// EnsureAllIndices creates every model's indices, continuing past failures and returning all of their
+// errors together. It is idempotent, so it can be called every time the service starts.
+func EnsureAllIndices() error {
+
+  ensurers := []struct {
+    model  string
+    ensure func() error
+  }{
+    {"Server", EnsureServerIndices},
+    {"ServerMember", EnsureServerMemberIndices},
+    {"Ban", EnsureBanIndices},
+    {"Warning", EnsureWarningIndices},
+    {"ServerConfig", EnsureServerConfigIndices},
+    {"AuditLogEntry", EnsureAuditLogEntryIndices},
+    {"CustomCommand", EnsureCustomCommandIndices},
+    {"ReactionRole", EnsureReactionRoleIndices},
+    {"ScheduledTask", EnsureScheduledTaskIndices},
+    {"UserPreference", EnsureUserPreferenceIndices},
+    {"Tag", EnsureTagIndices},
+    {"Tag", EnsureTagTextIndex},
+    {"Leaderboard", EnsureLeaderboardIndices},
+    {"RoleAssignment", EnsureRoleAssignmentIndices},
+    {"MigrationRecord", EnsureMigrationRecordIndices},
+    {"TwoPhaseUpdate", EnsureTwoPhaseUpdateIndices},
+  }
+
+  failures := []string{}
+  for _, e := range ensurers {
+    if err := e.ensure(); err != nil {
+      failures = append(failures, e.model+": "+err.Error())
+    }
+  }
+  if len(failures) > 0 {
+    return errors.New("error ensuring indices: " + strings.Join(failures, "; "))
+  }
+  return nil
+}
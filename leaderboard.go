@@ -0,0 +1,720 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "strconv"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// LeaderboardClientName is the name of the MgoDriver to use for Leaderboard.
+const LeaderboardClientName = "main"
+
+// LeaderboardDBName is the name of the database to use for Leaderboard.
+const LeaderboardDBName = "badpetbot"
+
+// LeaderboardColName is the name of the collection to use for Leaderboard.
+const LeaderboardColName = "leaderboards"
+
+// LeaderboardModelVersion is the current schema version of Leaderboard documents, stored in their ModelVersion.
+const LeaderboardModelVersion = 1
+
+// LeaderboardCol gets a collection reference for Leaderboard.
+func LeaderboardCol() *mgo.Collection {
+  return net.MgoCol(LeaderboardClientName, LeaderboardDBName, LeaderboardColName)
+}
+
+// Register Leaderboard, so tooling can find it.
+func init() {
+  RegisterModel("Leaderboard", LeaderboardCol, func() interface{} { return new(Leaderboard) })
+}
+
+// EnsureLeaderboardIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureLeaderboardIndices() error {
+
+  col := LeaderboardCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id", "discord_member_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("Leaderboard", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id", "-score"}, Background: true}); err != nil {
+    return wrapDBError("Leaderboard", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_server_id: 1, discord_member_id: 1 } unique
+// { discord_server_id: 1, score: -1 }
+
+// Leaderboard is a member's activity score in a server. Each member has at most one per server, created
+// by RecordActivity the first time they're active.
+type Leaderboard struct {
+  // ID is a BSON ID generated in Create.
+  ID              bson.ObjectId   `bson:"_id"                json:"_id"                validate:"required"`
+  DiscordServerID string          `bson:"discord_server_id"  json:"discord_server_id"  validate:"required,discord_id"`
+  DiscordMemberID string          `bson:"discord_member_id"  json:"discord_member_id"  validate:"required,discord_id"`
+  Score           int64           `bson:"score"              json:"score"              validate:"-"`
+  LastActivityAt  time.Time       `bson:"last_activity_at"   json:"last_activity_at"   validate:"-"`
+  CreatedAt       time.Time       `bson:"created_at"         json:"created_at"         validate:"required"`
+  UpdatedAt       time.Time       `bson:"updated_at"         json:"updated_at"         validate:"required"`
+  DeletedAt       *time.Time      `bson:"deleted_at"         json:"deleted_at"         validate:"-"`
+  Version         int             `bson:"version"            json:"version"            validate:"-"`
+  ModelVersion    int             `bson:"model_version"      json:"model_version"      validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *Leaderboard) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Leaderboard.Create", LeaderboardDBName, LeaderboardColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Leaderboard", "Create", time.Now(), &err)
+  defer logSlowQuery("Leaderboard", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = LeaderboardModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the Leaderboard.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("Leaderboard", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *Leaderboard) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *Leaderboard) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *Leaderboard) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Leaderboard.Update", LeaderboardDBName, LeaderboardColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Leaderboard", "Update", time.Now(), &err)
+  defer logSlowQuery("Leaderboard", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheLeaderboard(net.RedisGetClient(LeaderboardClientName), this.cacheKeys())
+  }
+  return wrapDBError("Leaderboard", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *Leaderboard) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "Leaderboard.Delete", LeaderboardDBName, LeaderboardColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Leaderboard", "Delete", time.Now(), &err)
+  defer logSlowQuery("Leaderboard", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the Leaderboard.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheLeaderboard(net.RedisGetClient(LeaderboardClientName), this.cacheKeys())
+  }
+  return wrapDBError("Leaderboard", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *Leaderboard) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *Leaderboard) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *Leaderboard) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("Leaderboard", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("Leaderboard", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheLeaderboard(net.RedisGetClient(LeaderboardClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *Leaderboard) Validate(ctx context.Context) error {
+
+  return wrapValidationError("Leaderboard", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *Leaderboard) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("Leaderboard", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("Leaderboard", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *Leaderboard) Clone() *Leaderboard {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*Leaderboard)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *Leaderboard) Diff(other *Leaderboard) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *Leaderboard) DiffDeep(other *Leaderboard) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "score".
+func (this *Leaderboard) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *Leaderboard) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *Leaderboard) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *Leaderboard) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *Leaderboard) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *Leaderboard) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the Leaderboard is uninitialized, having neither an ID nor a DiscordMemberID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *Leaderboard) IsZero() bool {
+  return this.ID == "" && this.DiscordMemberID == ""
+}
+
+// Age returns how long ago the Leaderboard was created.
+func (this *Leaderboard) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the Leaderboard was last updated.
+func (this *Leaderboard) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the Leaderboard hasn't been updated for longer than the threshold.
+func (this *Leaderboard) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetLeaderboard attempts to find a Leaderboard by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetLeaderboard(key, value string, negCache bool) (found *Leaderboard, err error) {
+
+  client := net.RedisGetClient(LeaderboardClientName)
+  cacheKey := LeaderboardClientName+":"+LeaderboardDBName+":"+LeaderboardColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "Leaderboard.CacheGet", LeaderboardDBName, LeaderboardColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Leaderboard", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("Leaderboard", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("Leaderboard", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "Leaderboard", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Leaderboard", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("Leaderboard", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    leaderboard := new(Leaderboard)
+    if err := json.Unmarshal([]byte(result), leaderboard); err != nil {
+      return nil, wrapDBError("Leaderboard", "CacheGet", key, value, err)
+    }
+    return leaderboard, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("Leaderboard", key, "miss")
+  leaderboard := new(Leaderboard)
+  err = net.MgoCol(LeaderboardClientName, LeaderboardDBName, LeaderboardColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(leaderboard)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheLeaderboard(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheLeaderboard(client, cacheKey, leaderboard)
+  }
+  if err != nil {
+    return nil, wrapDBError("Leaderboard", "CacheGet", key, value, err)
+  }
+  return leaderboard, nil
+}
+
+func fillCacheLeaderboard(client *redis.Client, key string, value *Leaderboard) {
+  _, span := startCacheSpan(context.Background(), "Leaderboard.fillCache", LeaderboardDBName, LeaderboardColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for Leaderboard")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for Leaderboard")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheLeaderboard(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "Leaderboard.fillNegCache", LeaderboardDBName, LeaderboardColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for Leaderboard")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheLeaderboard(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for Leaderboard")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetLeaderboard.
+func (this *Leaderboard) cacheKeys() []string {
+  prefix := LeaderboardClientName+":"+LeaderboardDBName+":"+LeaderboardColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+    prefix+"discord_member_id:"+this.DiscordMemberID,
+  }
+}
+
+// Misc functions.
+
+// AggregateLeaderboards runs the aggregation pipeline on the Leaderboard collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateLeaderboards(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Leaderboard", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(LeaderboardClientName, LeaderboardDBName, LeaderboardColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Leaderboard", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateLeaderboardOne runs the aggregation pipeline on the Leaderboard collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateLeaderboardOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Leaderboard", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(LeaderboardClientName, LeaderboardDBName, LeaderboardColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Leaderboard", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyLeaderboard atomically applies the update to the first Leaderboard matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyLeaderboard(selector, update bson.M, returnNew bool) (_ *Leaderboard, err error) {
+
+  defer observeOperation("Leaderboard", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(Leaderboard)
+  _, err = LeaderboardCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("Leaderboard", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheLeaderboard(net.RedisGetClient(LeaderboardClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindLeaderboards finds all Leaderboards matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindLeaderboards(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*Leaderboard, err error) {
+
+  defer observeOperation("Leaderboard", "Find", time.Now(), &err)
+  defer logSlowQuery("Leaderboard", "Find", filter)()
+
+  results := []*Leaderboard{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Leaderboard", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindLeaderboardsWithDeleted finds all Leaderboards matching the filter, including soft-deleted ones.
+func FindLeaderboardsWithDeleted(filter bson.M) (_ []*Leaderboard, err error) {
+
+  defer observeOperation("Leaderboard", "Find", time.Now(), &err)
+
+  results := []*Leaderboard{}
+  if err := net.MgoCol(LeaderboardClientName, LeaderboardDBName, LeaderboardColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("Leaderboard", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountLeaderboards counts the Leaderboards matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountLeaderboards(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("Leaderboard", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(LeaderboardClientName, LeaderboardDBName, LeaderboardColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("Leaderboard", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// LeaderboardExists reports whether any Leaderboard matches the filter, excluding soft-deleted ones.
+func LeaderboardExists(filter bson.M) (bool, error) {
+
+  count, err := CountLeaderboards(filter)
+  return count > 0, err
+}
+
+// DistinctLeaderboardFields finds the distinct values of the field among the Leaderboards matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctLeaderboardFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("Leaderboard", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("Leaderboard", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctLeaderboardStringFields is DistinctLeaderboardFields for string fields, like discord_member_id. It fails if
+// any of the values isn't a string.
+func DistinctLeaderboardStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctLeaderboardFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactLeaderboardCollection runs the compact command against the Leaderboard collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactLeaderboardCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName)
+}
+
+// LeaderboardCollectionStats returns the Leaderboard collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func LeaderboardCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName)
+}
+
+// ExplainLeaderboardQuery returns MongoDB's explain output for finding the filter in the Leaderboard collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainLeaderboardQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName, filter)
+}
+
+// UpdateManyLeaderboards applies the updates to every Leaderboard matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateLeaderboards for that. The Leaderboard cache is flushed.
+func UpdateManyLeaderboards(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName, filter, updates)
+}
+
+// BulkUpdateLeaderboards is UpdateManyLeaderboards, but validates each Leaderboard as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateLeaderboards(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName, filter, updates, func() validatable { return new(Leaderboard) })
+}
+
+// DeleteManyLeaderboards soft-deletes every Leaderboard matching the filter in a single query, returning how many
+// were deleted. The Leaderboard cache is evicted.
+func DeleteManyLeaderboards(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName, filter)
+}
+
+// RecordActivity adds delta to the member's score in the server and marks them active now, creating
+// their Leaderboard if they don't have one yet.
+func RecordActivity(serverID, memberID string, delta int64) (err error) {
+
+  defer observeOperation("Leaderboard", "RecordActivity", time.Now(), &err)
+
+  now := clockNow()
+  doc := new(Leaderboard)
+  query := LeaderboardCol().Find(bson.M{"discord_server_id": serverID, "discord_member_id": memberID})
+  change := mgo.Change{
+    Update:    bson.M{
+      "$setOnInsert": bson.M{"_id": bson.NewObjectId(), "created_at": now, "model_version": LeaderboardModelVersion},
+      "$set":         bson.M{"last_activity_at": now, "updated_at": now},
+      "$inc":         bson.M{"score": delta, "version": 1},
+    },
+    Upsert:    true,
+    ReturnNew: true,
+  }
+  _, err = query.Apply(change, doc)
+
+  // Concurrent first activity can make both upserts miss, in which case the unique index rejects all
+  // but one insert. Retrying updates the winner's document instead.
+  if mgo.IsDup(err) {
+    _, err = query.Apply(change, doc)
+  }
+  if err != nil {
+    return wrapDBError("Leaderboard", "RecordActivity", "discord_member_id", memberID, err)
+  }
+
+  // Evict the member's cached Leaderboard. Cached top lists are left to expire on their short TTL.
+  go invalidateCacheLeaderboard(net.RedisGetClient(LeaderboardClientName), doc.cacheKeys())
+  return nil
+}
+
+// GetTopMembers finds the server's highest scoring members, highest first. Results are cached for
+// Config.LeaderboardTopCacheTTL, so they may lag behind recent activity.
+func GetTopMembers(serverID string, limit int) (found []*Leaderboard, err error) {
+
+  client := net.RedisGetClient(LeaderboardClientName)
+  cacheKey := LeaderboardClientName+":"+LeaderboardDBName+":"+LeaderboardColName+":top:"+serverID+":"+strconv.Itoa(limit)
+  _, span := startCacheSpan(context.Background(), "Leaderboard.GetTopMembers", LeaderboardDBName, LeaderboardColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Leaderboard", "GetTopMembers", time.Now(), &err)
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Leaderboard", "GetTopMembers", "discord_server_id", serverID, err)
+  } else if result != "" {
+    recordCacheResult("Leaderboard", "top", "hit")
+    top := []*Leaderboard{}
+    if err := json.Unmarshal([]byte(result), &top); err != nil {
+      return nil, wrapDBError("Leaderboard", "GetTopMembers", "discord_server_id", serverID, err)
+    }
+    return top, nil
+  }
+
+  // Get what's in the database and cache it briefly.
+  recordCacheResult("Leaderboard", "top", "miss")
+  top, err := FindLeaderboards(context.Background(), bson.M{"discord_server_id": serverID}, "-score", limit)
+  if err != nil {
+    return nil, err
+  }
+  go func() {
+    serialized, err := json.Marshal(top)
+    if err == nil {
+      err = client.Set(cacheKey, string(serialized), getConfig().leaderboardTopCacheTTL()).Err()
+    }
+    if err != nil {
+      log.Warn().AnErr("fillCache", err).Msgf("Error filling top members cache for Leaderboard")
+    }
+  }()
+  return top, nil
+}
+
+// GetMemberRank returns the member's 1-based rank by score in the server, or a ModelNotFoundError if
+// they have no Leaderboard there. Members with the same score share a rank.
+func GetMemberRank(serverID, memberID string) (rank int, err error) {
+
+  defer observeOperation("Leaderboard", "GetMemberRank", time.Now(), &err)
+
+  // Find the member's score.
+  col := LeaderboardCol()
+  member := new(Leaderboard)
+  err = col.Find(notDeleted(bson.M{"discord_server_id": serverID, "discord_member_id": memberID})).Select(bson.M{"score": 1}).One(member)
+  if err != nil {
+    return 0, wrapDBError("Leaderboard", "GetMemberRank", "discord_member_id", memberID, err)
+  }
+
+  // Count the members scoring higher.
+  higher, err := col.Find(notDeleted(bson.M{"discord_server_id": serverID, "score": bson.M{"$gt": member.Score}})).Count()
+  if err != nil {
+    return 0, wrapDBError("Leaderboard", "GetMemberRank", "discord_member_id", memberID, err)
+  }
+  return higher + 1, nil
+}
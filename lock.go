@@ -0,0 +1,92 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "crypto/rand"
+  "encoding/hex"
+  "errors"
+  mathrand "math/rand"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// LockClientName is the name of the RedisDriver to use for distributed locks.
+const LockClientName = "main"
+
+// DefaultLockRetries is the default number of times DistributedLock retries taking a held lock.
+const DefaultLockRetries = 5
+
+// ErrLockNotAcquired is returned by DistributedLock when the lock is still held by someone else after
+// every retry.
+var ErrLockNotAcquired = errors.New("lock not acquired")
+
+// unlockScript deletes the lock only if it still holds the caller's token, so a caller whose lock
+// expired can't release someone else's.
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+  return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// DistributedLock takes an exclusive lock on the named resource across every worker, held for at most
+// ttl. If it's held by someone else, taking it is retried up to Config.LockRetries times, with the delay
+// doubling from the retry base delay. The returned unlock releases the lock, unless it already expired
+// and was taken by someone else.
+func DistributedLock(ctx context.Context, resource string, ttl time.Duration) (unlock func(), err error) {
+
+  defer observeOperation("Lock", "Acquire", time.Now(), &err)
+
+  // Identify this holder with a random token, so only it can release the lock.
+  token, err := newLockToken()
+  if err != nil {
+    return nil, err
+  }
+
+  client := net.RedisGetClient(LockClientName)
+  key := "lock:resource:"+resource
+  cfg := getConfig()
+  for attempt := 0; ; attempt++ {
+    locked, err := client.SetNX(key, token, ttl).Result()
+    if err != nil {
+      return nil, err
+    }
+    if locked {
+      return func() {
+        if err := unlockScript.Run(client, []string{key}, token).Err(); err != nil {
+          log.Warn().AnErr("unlock", err).Msgf("Error releasing lock on %s", resource)
+        }
+      }, nil
+    }
+    if attempt == cfg.LockRetries {
+      return nil, ErrLockNotAcquired
+    }
+
+    // Wait between half and all of the backoff before trying again.
+    backoff := cfg.Retry.BaseDelay << uint(attempt)
+    delay := backoff/2 + time.Duration(mathrand.Int63n(int64(backoff/2)+1))
+    select {
+    case <-ctx.Done():
+      return nil, ctx.Err()
+    case <-time.After(delay):
+    }
+  }
+}
+
+// newLockToken returns a random token identifying a lock's holder, for releasing it with unlockScript.
+func newLockToken() (string, error) {
+
+  tokenBytes := make([]byte, 16)
+  if _, err := rand.Read(tokenBytes); err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(tokenBytes), nil
+}
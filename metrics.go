@@ -0,0 +1,97 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "errors"
+  "sync"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+var operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+  Name: "gomodel_operation_duration_seconds",
+  Help: "Duration of model operations.",
+}, []string{"model", "operation"})
+
+var operationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+  Name: "gomodel_operation_errors_total",
+  Help: "Number of failed model operations.",
+}, []string{"model", "operation", "error_type"})
+
+var cacheResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+  Name: "gomodel_cache_results_total",
+  Help: "Number of cache lookups by result (hit, miss, or neg_hit).",
+}, []string{"model", "key", "result"})
+
+// metricsEnabled is set once the metric families are registered. Until then, recording is a no-op.
+var metricsEnabled bool
+var metricsMu sync.RWMutex
+
+// RegisterMetrics registers the package's metric families with reg and starts recording to them. If
+// reg is nil, nothing is registered and recording stays a no-op.
+func RegisterMetrics(reg prometheus.Registerer) error {
+
+  if reg == nil {
+    return nil
+  }
+  for _, collector := range []prometheus.Collector{operationDuration, operationErrors, cacheResults} {
+    if err := reg.Register(collector); err != nil {
+      return err
+    }
+  }
+
+  metricsMu.Lock()
+  metricsEnabled = true
+  metricsMu.Unlock()
+  return nil
+}
+
+// observeOperation records the duration of an operation started at start, and its error if the one
+// pointed to by err is non-nil. It's meant to be deferred with a pointer to a named error result.
+func observeOperation(model, operation string, start time.Time, err *error) {
+
+  metricsMu.RLock()
+  enabled := metricsEnabled
+  metricsMu.RUnlock()
+  if !enabled {
+    return
+  }
+
+  operationDuration.WithLabelValues(model, operation).Observe(time.Since(start).Seconds())
+  if err != nil && *err != nil {
+    operationErrors.WithLabelValues(model, operation, errorType(*err)).Inc()
+  }
+}
+
+// recordCacheResult records the result of a cache lookup by the given key.
+func recordCacheResult(model, key, result string) {
+
+  metricsMu.RLock()
+  enabled := metricsEnabled
+  metricsMu.RUnlock()
+  if !enabled {
+    return
+  }
+
+  cacheResults.WithLabelValues(model, key, result).Inc()
+}
+
+// errorType classifies err for the error_type label.
+func errorType(err error) string {
+
+  var notFound *ModelNotFoundError
+  var invalid *ModelValidationError
+  switch {
+  case errors.As(err, &notFound):
+    return "not_found"
+  case errors.As(err, &invalid):
+    return "validation"
+  case errors.Is(err, ErrVersionConflict):
+    return "version_conflict"
+  default:
+    return "database"
+  }
+}
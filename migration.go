@@ -0,0 +1,295 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "fmt"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// MigrationRecordClientName is the name of the MgoDriver to use for MigrationRecord.
+const MigrationRecordClientName = "main"
+
+// MigrationRecordDBName is the name of the database to use for MigrationRecord.
+const MigrationRecordDBName = "badpetbot"
+
+// MigrationRecordColName is the name of the collection to use for MigrationRecord.
+const MigrationRecordColName = "migration_records"
+
+// MigrationLockTTL is the longest a migration can hold its model's migration lock.
+const MigrationLockTTL = 10*time.Minute
+
+// DefaultBackfillBatchSize is the default number of documents Backfill updates at a time.
+const DefaultBackfillBatchSize = 500
+
+// renameFieldBatchSize is how many documents RenameField updates at a time.
+const renameFieldBatchSize = 1000
+
+// MigrationRecordCol gets a collection reference for MigrationRecord.
+func MigrationRecordCol() *mgo.Collection {
+  return net.MgoCol(MigrationRecordClientName, MigrationRecordDBName, MigrationRecordColName)
+}
+
+// Register MigrationRecord, so tooling can find it.
+func init() {
+  RegisterModel("MigrationRecord", MigrationRecordCol, func() interface{} { return new(MigrationRecord) })
+}
+
+// EnsureMigrationRecordIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureMigrationRecordIndices() error {
+
+  col := MigrationRecordCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"model_name", "version"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("MigrationRecord", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { model_name: 1, version: 1 } unique
+
+// MigrationRecord records a migration of a model's documents to a schema version, written by RunMigration.
+type MigrationRecord struct {
+  // ID is a BSON ID generated in RunMigration.
+  ID        bson.ObjectId   `bson:"_id"         json:"_id"         validate:"required"`
+  ModelName string          `bson:"model_name"  json:"model_name"  validate:"required"`
+  Version   int             `bson:"version"     json:"version"     validate:"gte=1"`
+  AppliedAt time.Time       `bson:"applied_at"  json:"applied_at"  validate:"required"`
+}
+
+// modelCollection locates a model's collection.
+type modelCollection struct {
+  client     string
+  database   string
+  collection string
+}
+
+// modelCollections are every migratable model's collections, by model name.
+var modelCollections = map[string]modelCollection{
+  "Server":         {ServerClientName, ServerDBName, ServerColName},
+  "ServerMember":   {ServerMemberClientName, ServerMemberDBName, ServerMemberColName},
+  "Ban":            {BanClientName, BanDBName, BanColName},
+  "Warning":        {WarningClientName, WarningDBName, WarningColName},
+  "ServerConfig":   {ServerConfigClientName, ServerConfigDBName, ServerConfigColName},
+  "AuditLogEntry":  {AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName},
+  "CustomCommand":  {CustomCommandClientName, CustomCommandDBName, CustomCommandColName},
+  "ReactionRole":   {ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName},
+  "ScheduledTask":  {ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName},
+  "UserPreference": {UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName},
+  "Tag":            {TagClientName, TagDBName, TagColName},
+  "Leaderboard":    {LeaderboardClientName, LeaderboardDBName, LeaderboardColName},
+  "RoleAssignment": {RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName},
+}
+
+// findModelCollection finds the model collection with the collection name.
+func findModelCollection(collection string) (*modelCollection, error) {
+
+  for _, location := range modelCollections {
+    if location.collection == collection {
+      return &location, nil
+    }
+  }
+  return nil, fmt.Errorf("unknown model collection %q", collection)
+}
+
+// RunMigration migrates the model's documents from schema version from to version to, by calling fn
+// with the model's collection, then records it. fn is responsible for setting the documents'
+// model_version. A migration already recorded is skipped, so it's safe to run on every start. Only one
+// worker migrates a model at a time. Returns an error if the model is already at some version other
+// than from.
+func RunMigration(model string, from, to int, fn func(*mgo.Collection) error) (err error) {
+
+  defer observeOperation("MigrationRecord", "RunMigration", time.Now(), &err)
+
+  location, ok := modelCollections[model]
+  if !ok {
+    return fmt.Errorf("can't migrate unknown model %q", model)
+  }
+  if to <= from {
+    return fmt.Errorf("can't migrate %s from version %d to %d", model, from, to)
+  }
+
+  // Hold the model's migration lock while checking and migrating.
+  unlock, err := DistributedLock(context.Background(), "migration:"+model, MigrationLockTTL)
+  if err != nil {
+    return err
+  }
+  defer unlock()
+
+  current, err := GetCurrentVersion(model)
+  if err != nil {
+    return err
+  }
+  if current >= to {
+    return nil
+  }
+  if current != 0 && current != from {
+    return fmt.Errorf("can't migrate %s from version %d, it's at version %d", model, from, current)
+  }
+
+  // Migrate, then record it.
+  err = mgoDo(context.Background(), location.client, location.database, location.collection, fn)
+  if err != nil {
+    return wrapDBError(model, "Migrate", "version", fmt.Sprint(to), err)
+  }
+  record := &MigrationRecord{ID: bson.NewObjectId(), ModelName: model, Version: to, AppliedAt: clockNow()}
+  if err := record.Validate(context.Background()); err != nil {
+    return err
+  }
+  if err := MigrationRecordCol().Insert(record); err != nil {
+    return wrapDBError("MigrationRecord", "Create", "_id", record.ID.Hex(), err)
+  }
+  log.Info().Int("from", from).Int("to", to).Msgf("Migrated %s", model)
+  return nil
+}
+
+// GetCurrentVersion gets the highest schema version the model has been migrated to, or 0 if it has
+// never been migrated.
+func GetCurrentVersion(model string) (_ int, err error) {
+
+  defer observeOperation("MigrationRecord", "Find", time.Now(), &err)
+
+  record := new(MigrationRecord)
+  err = MigrationRecordCol().Find(bson.M{"model_name": model}).Sort("-version").One(record)
+  if err == mgo.ErrNotFound {
+    return 0, nil
+  } else if err != nil {
+    return 0, wrapDBError("MigrationRecord", "Find", "model_name", model, err)
+  }
+  return record.Version, nil
+}
+
+// Backfill sets the field to defaultValue on every document in the collection which doesn't have it,
+// in batches of Config.BackfillBatchSize, until none are left. Returns how many documents were updated.
+// The collection must be one of the models' collections, and its cache is flushed afterwards.
+func Backfill(ctx context.Context, collection string, field string, defaultValue interface{}) (updated int, err error) {
+
+  defer observeOperation("MigrationRecord", "Backfill", time.Now(), &err)
+
+  location, err := findModelCollection(collection)
+  if err != nil {
+    return 0, err
+  }
+
+  batchSize := getConfig().BackfillBatchSize
+  missing := bson.M{field: bson.M{"$exists": false}}
+  defer func() {
+    if updated > 0 {
+      flushCollectionCache(location.client, location.database, location.collection)
+    }
+  }()
+  for {
+    found, batchUpdated := 0, 0
+    err = mgoDo(ctx, location.client, location.database, location.collection, func(col *mgo.Collection) error {
+
+      // Find the next batch, then update just those documents.
+      batch := []bson.M{}
+      if err := col.Find(missing).Select(bson.M{"_id": 1}).Limit(batchSize).All(&batch); err != nil {
+        return err
+      }
+      found = len(batch)
+      if found == 0 {
+        return nil
+      }
+      ids := make([]interface{}, len(batch))
+      for i, doc := range batch {
+        ids[i] = doc["_id"]
+      }
+      info, err := col.UpdateAll(bson.M{"_id": bson.M{"$in": ids}, field: bson.M{"$exists": false}}, bson.M{"$set": bson.M{field: defaultValue}})
+      if err != nil {
+        return err
+      }
+      batchUpdated = info.Updated
+      return nil
+    })
+    if err != nil {
+      return updated, wrapDBError(collection, "Backfill", field, "", err)
+    }
+    if found == 0 {
+      log.Info().Int("updated", updated).Msgf("Finished backfilling %s.%s", collection, field)
+      return updated, nil
+    }
+    updated += batchUpdated
+    log.Info().Int("batch", batchUpdated).Int("updated", updated).Msgf("Backfilling %s.%s", collection, field)
+  }
+}
+
+// RenameField renames the field to newName on every document in the collection which has it, in batches
+// of renameFieldBatchSize, logging progress after each. Returns how many documents were updated. The
+// collection must be one of the models' collections, and its cache is flushed afterwards.
+func RenameField(ctx context.Context, collection, oldName, newName string) (updated int, err error) {
+
+  defer observeOperation("MigrationRecord", "RenameField", time.Now(), &err)
+
+  location, err := findModelCollection(collection)
+  if err != nil {
+    return 0, err
+  }
+  has := bson.M{oldName: bson.M{"$exists": true}}
+
+  // Return right away if there's nothing to rename.
+  var remaining int
+  err = mgoDo(ctx, location.client, location.database, location.collection, func(col *mgo.Collection) (err error) {
+    remaining, err = col.Find(has).Count()
+    return err
+  })
+  if err != nil || remaining == 0 {
+    return 0, wrapDBError(collection, "RenameField", oldName, "", err)
+  }
+
+  defer func() {
+    if updated > 0 {
+      flushCollectionCache(location.client, location.database, location.collection)
+    }
+  }()
+  for {
+    found, batchUpdated := 0, 0
+    err = mgoDo(ctx, location.client, location.database, location.collection, func(col *mgo.Collection) error {
+
+      // Find the next batch, then rename the field on just those documents.
+      batch := []bson.M{}
+      if err := col.Find(has).Select(bson.M{"_id": 1}).Limit(renameFieldBatchSize).All(&batch); err != nil {
+        return err
+      }
+      found = len(batch)
+      if found == 0 {
+        return nil
+      }
+      ids := make([]interface{}, len(batch))
+      for i, doc := range batch {
+        ids[i] = doc["_id"]
+      }
+      info, err := col.UpdateAll(bson.M{"_id": bson.M{"$in": ids}, oldName: bson.M{"$exists": true}}, bson.M{"$rename": bson.M{oldName: newName}})
+      if err != nil {
+        return err
+      }
+      batchUpdated = info.Updated
+      return nil
+    })
+    if err != nil {
+      return updated, wrapDBError(collection, "RenameField", oldName, "", err)
+    }
+    if found == 0 {
+      log.Info().Int("updated", updated).Msgf("Finished renaming %s.%s to %s", collection, oldName, newName)
+      return updated, nil
+    }
+    updated += batchUpdated
+    log.Info().Int("updated", updated).Msgf("Renaming %s.%s to %s", collection, oldName, newName)
+  }
+}
+
+// Validate runs validations against the model's fields.
+func (this *MigrationRecord) Validate(ctx context.Context) error {
+
+  return wrapValidationError("MigrationRecord", newValidator().StructCtx(ctx, this))
+}
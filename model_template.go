@@ -8,12 +8,17 @@ How to use:
 3. Replace (case sensitive) "model_template" with your model's underscored_name.
 4. Modify your fields and relationships.
 5. Add your validations as needed. https://github.com/go-playground/validator
-6. Comment your indices for easy reference later.
-7. Change the comments!
+6. Comment your indices for easy reference later, ensure them in EnsureModelTemplateIndices, and add
+   that to EnsureAllIndices.
+7. Uncomment the init registering the model with RegisterModel.
+8. Change the comments!
 
 FYI: Embeddable related documents only works because of the go.mod replacement
 from globalsign/mgo to Nifty255/mgo, allowing the use of "omitalways" tags.
 
+Fields tagged json_api:"-" are internal-only: SerializeForAPI leaves them out, and DeserializeFromAPI
+never sets them.
+
 */
 
 package gomodel
@@ -21,7 +26,10 @@ package gomodel
 import (
 
   // Import builtin packages.
+  "context"
   "encoding/json"
+  "errors"
+  "reflect"
   "time"
 
   // Import 3rd party packages.
@@ -32,7 +40,6 @@ import (
 
   // Import internal packages.
   "github.com/badpetbot/gocommon/net"
-  "github.com/badpetbot/gocommon/validation"
 )
 
 // ModelTemplateClientName is the name of the MgoDriver to use for ModelTemplate.
@@ -44,11 +51,29 @@ const ModelTemplateDBName = "badpetbot"
 // ModelTemplateColName is the name of the collection to use for ModelTemplate.
 const ModelTemplateColName = "model_templates"
 
+// ModelTemplateModelVersion is the current schema version of ModelTemplate documents, stored in their ModelVersion.
+const ModelTemplateModelVersion = 1
+
 // ModelTemplateCol gets a collection reference for ModelTemplate.
 func ModelTemplateCol() *mgo.Collection {
   return net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName)
 }
 
+// Register ModelTemplate, so tooling can find it. Uncomment this in your copy.
+// func init() {
+//   RegisterModel("ModelTemplate", ModelTemplateCol, func() interface{} { return new(ModelTemplate) })
+// }
+
+// EnsureModelTemplateIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureModelTemplateIndices() error {
+
+  // Ensure indices here, e.g.:
+  // if err := col.EnsureIndex(mgo.Index{Key: []string{"related_template_id"}, Background: true}); err != nil {
+  //   return err
+  // }
+  return nil
+}
+
 // INDICES:
 // { _id: 1 }
 
@@ -58,6 +83,9 @@ type ModelTemplate struct {
   ID                  bson.ObjectId   `bson:"_id"                           json:"_id"                  validate:"required"`
   CreatedAt           time.Time       `bson:"created_at"                    json:"created_at"           validate:"required"`
   UpdatedAt           time.Time       `bson:"updated_at"                    json:"updated_at"           validate:"required"`
+  DeletedAt           *time.Time      `bson:"deleted_at"                    json:"deleted_at"           validate:"-"`
+  Version             int             `bson:"version"                       json:"version"              validate:"-"`
+  ModelVersion        int             `bson:"model_version"                 json:"model_version"        validate:"-"`
   FieldWithDefault    int             `bson:"field_with_default"            json:"field_with_default"   validate:"gt=2,lt=10"`
 
   // Relationship IDs. Referencing another document's ID causes this document to "belong to" that document. A document can
@@ -75,58 +103,276 @@ type ModelTemplate struct {
 
 // Create persists the document in the database. It can optionally run validations if present and
 // prevent model persistence if they do not pass.
-func (this *ModelTemplate) Create() error {
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *ModelTemplate) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ModelTemplate.Create", ModelTemplateDBName, ModelTemplateColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ModelTemplate", "Create", time.Now(), &err)
+  defer logSlowQuery("ModelTemplate", "Create", nil)()
 
   // Ensure ID, timestamps, and tokens.
   this.ID = bson.NewObjectId()
-  now := time.Now()
+  now := clockNow()
   this.CreatedAt = now
   this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ModelTemplateModelVersion
 
   // Ensure defaults.
   this.FieldWithDefault = 7
 
   // Run validations and return if they fail.
-  if err := this.Validate(); err != nil {
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the ModelTemplate.
-  return net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).Insert(this)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("ModelTemplate", "Create", "_id", this.ID.Hex(), err)
 }
 
 // Update updates the document in the database. Important note, this function does NOT prepend
-// the provided updates with "$set" or any other operator.
-func (this *ModelTemplate) Update(updates bson.M) error {
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *ModelTemplate) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *ModelTemplate) UpdateWithVersion(updates bson.M, expectedVersion int) error {
 
-  // Update updated-at timestamp.
-  this.UpdatedAt = time.Now()
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *ModelTemplate) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ModelTemplate.Update", ModelTemplateDBName, ModelTemplateColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ModelTemplate", "Update", time.Now(), &err)
+  defer logSlowQuery("ModelTemplate", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
   _, setting := updates["$set"]
   if !setting {
     updates["$set"] = bson.M{}
   }
   updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
 
-  if err := this.Validate(); err != nil {
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the updates.
-  return net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).UpdateId(this.ID, updates)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheModelTemplate(net.RedisGetClient(ModelTemplateClientName), this.cacheKeys())
+  }
+  return wrapDBError("ModelTemplate", "Update", "_id", this.ID.Hex(), err)
 }
 
 // Delete permanently removes the document from the database.
-func (this *ModelTemplate) Delete() error {
+func (this *ModelTemplate) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "ModelTemplate.Delete", ModelTemplateDBName, ModelTemplateColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ModelTemplate", "Delete", time.Now(), &err)
+  defer logSlowQuery("ModelTemplate", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the ModelTemplate.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheModelTemplate(net.RedisGetClient(ModelTemplateClientName), this.cacheKeys())
+  }
+  return wrapDBError("ModelTemplate", "Delete", "_id", this.ID.Hex(), err)
+}
 
-  // Delete the Link.
-  return net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).RemoveId(this.ID)
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *ModelTemplate) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *ModelTemplate) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *ModelTemplate) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("ModelTemplate", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("ModelTemplate", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheModelTemplate(net.RedisGetClient(ModelTemplateClientName), this.cacheKeys())
+  return nil
 }
 
 // Validate runs validations against the model's fields.
-func (this *ModelTemplate) Validate() error {
+func (this *ModelTemplate) Validate(ctx context.Context) error {
 
   // Implement validation rules here.
-  return validation.NewValidator().Struct(this)
+  return wrapValidationError("ModelTemplate", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *ModelTemplate) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("ModelTemplate", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("ModelTemplate", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *ModelTemplate) Clone() *ModelTemplate {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*ModelTemplate)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *ModelTemplate) Diff(other *ModelTemplate) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *ModelTemplate) DiffDeep(other *ModelTemplate) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, using dotted keys
+// for embedded documents and slice indices, e.g. "related_template.field_with_default" or
+// "related_template_ids.0".
+func (this *ModelTemplate) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *ModelTemplate) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *ModelTemplate) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *ModelTemplate) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *ModelTemplate) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *ModelTemplate) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the ModelTemplate is uninitialized, having no ID, e.g. after a partially failed
+// deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *ModelTemplate) IsZero() bool {
+  return this.ID == ""
+}
+
+// Age returns how long ago the ModelTemplate was created.
+func (this *ModelTemplate) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the ModelTemplate was last updated.
+func (this *ModelTemplate) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the ModelTemplate hasn't been updated for longer than the threshold.
+func (this *ModelTemplate) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
 }
 
 // Cache functions.
@@ -134,60 +380,275 @@ func (this *ModelTemplate) Validate() error {
 // CacheGetModelTemplate attempts to find a ModelTemplate by the key and value specified in cache before looking
 // in the database and setting cache if found. If "negCache" is true, will check for neg-cache
 // first, and also set neg-cache if the document wasn't found in the database either.
-func CacheGetModelTemplate(key, value string, negCache bool) (*ModelTemplate, error) {
+func CacheGetModelTemplate(key, value string, negCache bool) (found *ModelTemplate, err error) {
 
   client := net.RedisGetClient(ModelTemplateClientName)
   cacheKey := ModelTemplateClientName+":"+ModelTemplateDBName+":"+ModelTemplateColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "ModelTemplate.CacheGet", ModelTemplateDBName, ModelTemplateColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ModelTemplate", "CacheGet", time.Now(), &err)
 
   // Return not-found early if neg-cache exists.
   if negCache {
-    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil {
-      return nil, err
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ModelTemplate", "CacheGet", key, value, err)
     } else if result != "" {
-      return nil, mgo.ErrNotFound
+      recordCacheResult("ModelTemplate", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ModelTemplate", Key: key, Value: value}
     }
   }
 
   // Return what's in cache if it's found.
-  if result, err := client.Get(cacheKey).Result(); err != nil {
-    return nil, err
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ModelTemplate", "CacheGet", key, value, err)
   } else if result != "" {
+    recordCacheResult("ModelTemplate", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
     server := new(ModelTemplate)
-    err = json.Unmarshal([]byte(result), server)
-    return server, err
+    if err := json.Unmarshal([]byte(result), server); err != nil {
+      return nil, wrapDBError("ModelTemplate", "CacheGet", key, value, err)
+    }
+    return server, nil
   }
 
   // Get what's in the database.
+  recordCacheResult("ModelTemplate", key, "miss")
   server := new(ModelTemplate)
-  err := net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).Find(bson.M{
-    key: value,
-  }).One(server)
+  err = net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(server)
 
   // If it wasn't found and negCache is true, fill neg cache.
   if err == mgo.ErrNotFound && negCache {
     go fillNegCacheModelTemplate(client, cacheKey)
 
   // Else if there's no error, fill cache.
-  } else if err != nil {
+  } else if err == nil {
     go fillCacheModelTemplate(client, cacheKey, server)
   }
-  return server, err
+  if err != nil {
+    return nil, wrapDBError("ModelTemplate", "CacheGet", key, value, err)
+  }
+  return server, nil
 }
 
 func fillCacheModelTemplate(client *redis.Client, key string, value *ModelTemplate) {
+  _, span := startCacheSpan(context.Background(), "ModelTemplate.fillCache", ModelTemplateDBName, ModelTemplateColName, key)
   serialized, err := json.Marshal(value)
   if err != nil {
     log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ModelTemplate")
+    endSpan(span, err)
+    return
   }
-  if err := client.Set(key, string(serialized), CacheTTL).Err(); err != nil {
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
     log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ModelTemplate")
   }
+  endSpan(span, err)
 }
 
 func fillNegCacheModelTemplate(client *redis.Client, key string) {
-  if err := client.Set("neg:"+key, "neg", NegCacheTTL).Err(); err != nil {
+  _, span := startCacheSpan(context.Background(), "ModelTemplate.fillNegCache", ModelTemplateDBName, ModelTemplateColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
     log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ModelTemplate")
   }
+  endSpan(span, err)
+}
+
+func invalidateCacheModelTemplate(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for ModelTemplate")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetModelTemplate.
+func (this *ModelTemplate) cacheKeys() []string {
+  prefix := ModelTemplateClientName+":"+ModelTemplateDBName+":"+ModelTemplateColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateModelTemplates runs the aggregation pipeline on the ModelTemplate collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateModelTemplates(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ModelTemplate", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ModelTemplate", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateModelTemplateOne runs the aggregation pipeline on the ModelTemplate collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateModelTemplateOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ModelTemplate", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ModelTemplate", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyModelTemplate atomically applies the update to the first ModelTemplate matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyModelTemplate(selector, update bson.M, returnNew bool) (_ *ModelTemplate, err error) {
+
+  defer observeOperation("ModelTemplate", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(ModelTemplate)
+  _, err = ModelTemplateCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("ModelTemplate", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheModelTemplate(net.RedisGetClient(ModelTemplateClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindModelTemplates finds all ModelTemplates matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindModelTemplates(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*ModelTemplate, err error) {
+
+  defer observeOperation("ModelTemplate", "Find", time.Now(), &err)
+  defer logSlowQuery("ModelTemplate", "Find", filter)()
+
+  results := []*ModelTemplate{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ModelTemplate", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindModelTemplatesWithDeleted finds all ModelTemplates matching the filter, including soft-deleted ones.
+func FindModelTemplatesWithDeleted(filter bson.M) (_ []*ModelTemplate, err error) {
+
+  defer observeOperation("ModelTemplate", "Find", time.Now(), &err)
+
+  results := []*ModelTemplate{}
+  if err := net.MgoCol(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("ModelTemplate", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountModelTemplates counts the ModelTemplates matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountModelTemplates(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("ModelTemplate", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("ModelTemplate", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ModelTemplateExists reports whether any ModelTemplate matches the filter, excluding soft-deleted ones.
+func ModelTemplateExists(filter bson.M) (bool, error) {
+
+  count, err := CountModelTemplates(filter)
+  return count > 0, err
+}
+
+// DistinctModelTemplateFields finds the distinct values of the field among the ModelTemplates matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctModelTemplateFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("ModelTemplate", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("ModelTemplate", "Distinct", "", "", err)
+  }
+  return values, nil
 }
 
-// Misc functions.
\ No newline at end of file
+// DistinctModelTemplateStringFields is DistinctModelTemplateFields for string fields, of which the template has
+// none until they're added to it. It fails if any of the values isn't a string.
+func DistinctModelTemplateStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctModelTemplateFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactModelTemplateCollection runs the compact command against the ModelTemplate collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactModelTemplateCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName)
+}
+
+// ModelTemplateCollectionStats returns the ModelTemplate collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ModelTemplateCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName)
+}
+
+// ExplainModelTemplateQuery returns MongoDB's explain output for finding the filter in the ModelTemplate collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainModelTemplateQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, filter)
+}
+
+// UpdateManyModelTemplates applies the updates to every ModelTemplate matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateModelTemplates for that. The ModelTemplate cache is flushed.
+func UpdateManyModelTemplates(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, filter, updates)
+}
+
+// BulkUpdateModelTemplates is UpdateManyModelTemplates, but validates each ModelTemplate as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateModelTemplates(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, filter, updates, func() validatable { return new(ModelTemplate) })
+}
+
+// DeleteManyModelTemplates soft-deletes every ModelTemplate matching the filter in a single query, returning how many
+// were deleted. The ModelTemplate cache is evicted.
+func DeleteManyModelTemplates(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "ModelTemplate", ModelTemplateClientName, ModelTemplateDBName, ModelTemplateColName, filter)
+}
\ No newline at end of file
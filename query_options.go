@@ -0,0 +1,118 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// QueryOption adjusts how a single operation runs, e.g. WithReadPreference. Operations accepting them
+// take them as their last, variadic parameters.
+type QueryOption func(*queryOptions)
+
+// queryOptions are the settings made by QueryOptions.
+type queryOptions struct {
+  // mode is the read preference, or nil for the session's.
+  mode *mgo.Mode
+  // safe is the write concern, or nil for the session's.
+  safe *mgo.Safe
+  // maxTime is how long MongoDB lets a query run, or 0 for Config.DefaultMaxQueryTime.
+  maxTime time.Duration
+}
+
+// WithReadPreference runs the operation with the read preference, e.g. mgo.SecondaryPreferred to take
+// read-heavy queries off the primary. Without it, the session's mode, the primary, is used.
+func WithReadPreference(mode mgo.Mode) QueryOption {
+  return func(options *queryOptions) {
+    options.mode = &mode
+  }
+}
+
+// WithWriteConcern runs the operation with the write concern, e.g. &mgo.Safe{WMode: "majority", J: true}
+// for writes which must be durable, or &mgo.Safe{W: 1} for frequent writes which must be fast. Without
+// it, the session's write concern is used.
+func WithWriteConcern(safe *mgo.Safe) QueryOption {
+  return func(options *queryOptions) {
+    options.safe = safe
+  }
+}
+
+// WithMaxTime makes MongoDB abort the query if it runs for longer than d, in which case an error matching
+// ErrQueryTimeout is returned. Without it, Config.DefaultMaxQueryTime applies.
+func WithMaxTime(d time.Duration) QueryOption {
+  return func(options *queryOptions) {
+    options.maxTime = d
+  }
+}
+
+// applyQueryOptions collects the settings made by the options.
+func applyQueryOptions(opts []QueryOption) queryOptions {
+
+  options := queryOptions{}
+  for _, opt := range opts {
+    opt(&options)
+  }
+  return options
+}
+
+// changesSession reports whether the options change the session's settings.
+func (this queryOptions) changesSession() bool {
+  return this.mode != nil || this.safe != nil
+}
+
+// applyToSession changes the session's settings according to the options.
+func (this queryOptions) applyToSession(session *mgo.Session) {
+
+  if this.mode != nil {
+    session.SetMode(*this.mode, true)
+  }
+  if this.safe != nil {
+    session.SetSafe(this.safe)
+  }
+}
+
+// maxQueryTime returns how long MongoDB lets a query run.
+func (this queryOptions) maxQueryTime() time.Duration {
+
+  if this.maxTime > 0 {
+    return this.maxTime
+  }
+  return getConfig().DefaultMaxQueryTime
+}
+
+// optionsCol gets a collection reference which runs operations according to the options. If they change
+// the session's settings, the collection is on a clone of it, which the returned func closes. Otherwise
+// the func does nothing.
+func optionsCol(client, database, collection string, options queryOptions) (*mgo.Collection, func()) {
+
+  if !options.changesSession() {
+    return net.MgoCol(client, database, collection), func() {}
+  }
+  session := net.MgoGetSession(client).Clone()
+  options.applyToSession(session)
+  return session.DB(database).C(collection), session.Close
+}
+
+// optionsQuery finds the filter in the collection according to the options. The returned func must be
+// called once the query is done with.
+func optionsQuery(client, database, collection string, filter interface{}, opts []QueryOption) (*mgo.Query, func()) {
+
+  options := applyQueryOptions(opts)
+  col, done := optionsCol(client, database, collection, options)
+  return col.Find(filter).SetMaxTime(options.maxQueryTime()), done
+}
+
+// optionsPipe runs the aggregation pipeline on the collection according to the options. The returned func
+// must be called once the pipe is done with.
+func optionsPipe(client, database, collection string, pipeline interface{}, opts []QueryOption) (*mgo.Pipe, func()) {
+
+  options := applyQueryOptions(opts)
+  col, done := optionsCol(client, database, collection, options)
+  return col.Pipe(pipeline).SetMaxTime(options.maxQueryTime()), done
+}
@@ -0,0 +1,692 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// ReactionRoleClientName is the name of the MgoDriver to use for ReactionRole.
+const ReactionRoleClientName = "main"
+
+// ReactionRoleDBName is the name of the database to use for ReactionRole.
+const ReactionRoleDBName = "badpetbot"
+
+// ReactionRoleColName is the name of the collection to use for ReactionRole.
+const ReactionRoleColName = "reaction_roles"
+
+// ReactionRoleModelVersion is the current schema version of ReactionRole documents, stored in their ModelVersion.
+const ReactionRoleModelVersion = 1
+
+// ReactionRoleCol gets a collection reference for ReactionRole.
+func ReactionRoleCol() *mgo.Collection {
+  return net.MgoCol(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName)
+}
+
+// Register ReactionRole, so tooling can find it.
+func init() {
+  RegisterModel("ReactionRole", ReactionRoleCol, func() interface{} { return new(ReactionRole) })
+}
+
+// EnsureReactionRoleIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureReactionRoleIndices() error {
+
+  col := ReactionRoleCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_message_id", "emoji_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("ReactionRole", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_message_id: 1, emoji_id: 1 } unique
+
+// ReactionRole maps an emoji reaction on a message to the Discord role it grants.
+type ReactionRole struct {
+  // ID is a BSON ID generated in Create.
+  ID               bson.ObjectId   `bson:"_id"                 json:"_id"                 validate:"required"`
+  DiscordServerID  string          `bson:"discord_server_id"   json:"discord_server_id"   validate:"required,discord_id"`
+  DiscordChannelID string          `bson:"discord_channel_id"  json:"discord_channel_id"  validate:"required,discord_id"`
+  DiscordMessageID string          `bson:"discord_message_id"  json:"discord_message_id"  validate:"required,discord_id"`
+  EmojiID          string          `bson:"emoji_id"            json:"emoji_id"            validate:"required"`
+  RoleID           string          `bson:"role_id"             json:"role_id"             validate:"required,discord_id"`
+  CreatedAt        time.Time       `bson:"created_at"          json:"created_at"          validate:"required"`
+  UpdatedAt        time.Time       `bson:"updated_at"          json:"updated_at"          validate:"required"`
+  DeletedAt        *time.Time      `bson:"deleted_at"          json:"deleted_at"          validate:"-"`
+  Version          int             `bson:"version"             json:"version"             validate:"-"`
+  ModelVersion     int             `bson:"model_version"       json:"model_version"       validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *ReactionRole) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ReactionRole.Create", ReactionRoleDBName, ReactionRoleColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ReactionRole", "Create", time.Now(), &err)
+  defer logSlowQuery("ReactionRole", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ReactionRoleModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the ReactionRole.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict the message's cached list and any neg-cache for the emoji, so the new role shows up.
+  if err == nil {
+    go invalidateCacheReactionRole(net.RedisGetClient(ReactionRoleClientName), []string{
+      "neg:"+reactionRoleCacheKey(this.DiscordMessageID, this.EmojiID),
+      reactionRoleMessageCacheKey(this.DiscordMessageID),
+    })
+  }
+  return wrapDBError("ReactionRole", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *ReactionRole) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *ReactionRole) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *ReactionRole) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ReactionRole.Update", ReactionRoleDBName, ReactionRoleColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ReactionRole", "Update", time.Now(), &err)
+  defer logSlowQuery("ReactionRole", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheReactionRole(net.RedisGetClient(ReactionRoleClientName), this.cacheKeys())
+  }
+  return wrapDBError("ReactionRole", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *ReactionRole) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "ReactionRole.Delete", ReactionRoleDBName, ReactionRoleColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ReactionRole", "Delete", time.Now(), &err)
+  defer logSlowQuery("ReactionRole", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the ReactionRole.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheReactionRole(net.RedisGetClient(ReactionRoleClientName), this.cacheKeys())
+  }
+  return wrapDBError("ReactionRole", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *ReactionRole) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *ReactionRole) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *ReactionRole) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("ReactionRole", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("ReactionRole", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheReactionRole(net.RedisGetClient(ReactionRoleClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *ReactionRole) Validate(ctx context.Context) error {
+
+  return wrapValidationError("ReactionRole", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *ReactionRole) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("ReactionRole", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("ReactionRole", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *ReactionRole) Clone() *ReactionRole {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*ReactionRole)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *ReactionRole) Diff(other *ReactionRole) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *ReactionRole) DiffDeep(other *ReactionRole) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "emoji_id".
+func (this *ReactionRole) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *ReactionRole) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *ReactionRole) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *ReactionRole) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *ReactionRole) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *ReactionRole) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the ReactionRole is uninitialized, having neither an ID nor a DiscordMessageID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *ReactionRole) IsZero() bool {
+  return this.ID == "" && this.DiscordMessageID == ""
+}
+
+// Age returns how long ago the ReactionRole was created.
+func (this *ReactionRole) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the ReactionRole was last updated.
+func (this *ReactionRole) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the ReactionRole hasn't been updated for longer than the threshold.
+func (this *ReactionRole) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetReactionRole attempts to find the ReactionRole for the emoji on the message in cache before
+// looking in the database and setting cache if found. ReactionRoles are cached by the composite key
+// discord_message_id:emoji_id. If "negCache" is true, will check for neg-cache first, and also set
+// neg-cache if the document wasn't found in the database either.
+func CacheGetReactionRole(messageID, emojiID string, negCache bool) (found *ReactionRole, err error) {
+
+  client := net.RedisGetClient(ReactionRoleClientName)
+  cacheKey := reactionRoleCacheKey(messageID, emojiID)
+  _, span := startCacheSpan(context.Background(), "ReactionRole.CacheGet", ReactionRoleDBName, ReactionRoleColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ReactionRole", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ReactionRole", "CacheGet", "emoji_id", emojiID, err)
+    } else if result != "" {
+      recordCacheResult("ReactionRole", "discord_message_id:emoji_id", "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ReactionRole", Key: "emoji_id", Value: emojiID}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ReactionRole", "CacheGet", "emoji_id", emojiID, err)
+  } else if result != "" {
+    recordCacheResult("ReactionRole", "discord_message_id:emoji_id", "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    role := new(ReactionRole)
+    if err := json.Unmarshal([]byte(result), role); err != nil {
+      return nil, wrapDBError("ReactionRole", "CacheGet", "emoji_id", emojiID, err)
+    }
+    return role, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("ReactionRole", "discord_message_id:emoji_id", "miss")
+  role := new(ReactionRole)
+  err = net.MgoCol(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName).Find(notDeleted(bson.M{
+    "discord_message_id": messageID,
+    "emoji_id":           emojiID,
+  })).One(role)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheReactionRole(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheReactionRole(client, cacheKey, role)
+  }
+  if err != nil {
+    return nil, wrapDBError("ReactionRole", "CacheGet", "emoji_id", emojiID, err)
+  }
+  return role, nil
+}
+
+// reactionRoleCacheKey builds the composite cache key for the ReactionRole for the emoji on the message.
+func reactionRoleCacheKey(messageID, emojiID string) string {
+  return ReactionRoleClientName+":"+ReactionRoleDBName+":"+ReactionRoleColName+":discord_message_id:emoji_id:"+messageID+":"+emojiID
+}
+
+// reactionRoleMessageCacheKey builds the message-scoped cache key FindByMessage caches its list under.
+func reactionRoleMessageCacheKey(messageID string) string {
+  return ReactionRoleClientName+":"+ReactionRoleDBName+":"+ReactionRoleColName+":discord_message_id:"+messageID
+}
+
+func fillCacheReactionRole(client *redis.Client, key string, value *ReactionRole) {
+  _, span := startCacheSpan(context.Background(), "ReactionRole.fillCache", ReactionRoleDBName, ReactionRoleColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ReactionRole")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ReactionRole")
+  }
+  endSpan(span, err)
+}
+
+func fillCacheReactionRoles(client *redis.Client, key string, values []*ReactionRole) {
+  _, span := startCacheSpan(context.Background(), "ReactionRole.fillCache", ReactionRoleDBName, ReactionRoleColName, key)
+  serialized, err := json.Marshal(values)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ReactionRoles")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ReactionRoles")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheReactionRole(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "ReactionRole.fillNegCache", ReactionRoleDBName, ReactionRoleColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ReactionRole")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheReactionRole(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for ReactionRole")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under, including the message-scoped
+// list cached by FindByMessage.
+func (this *ReactionRole) cacheKeys() []string {
+  return []string{
+    reactionRoleCacheKey(this.DiscordMessageID, this.EmojiID),
+    reactionRoleMessageCacheKey(this.DiscordMessageID),
+  }
+}
+
+// Misc functions.
+
+// AggregateReactionRoles runs the aggregation pipeline on the ReactionRole collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateReactionRoles(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ReactionRole", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ReactionRole", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateReactionRoleOne runs the aggregation pipeline on the ReactionRole collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateReactionRoleOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ReactionRole", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ReactionRole", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyReactionRole atomically applies the update to the first ReactionRole matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyReactionRole(selector, update bson.M, returnNew bool) (_ *ReactionRole, err error) {
+
+  defer observeOperation("ReactionRole", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(ReactionRole)
+  _, err = ReactionRoleCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("ReactionRole", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheReactionRole(net.RedisGetClient(ReactionRoleClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindReactionRoles finds all ReactionRoles matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindReactionRoles(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*ReactionRole, err error) {
+
+  defer observeOperation("ReactionRole", "Find", time.Now(), &err)
+  defer logSlowQuery("ReactionRole", "Find", filter)()
+
+  results := []*ReactionRole{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ReactionRole", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindReactionRolesWithDeleted finds all ReactionRoles matching the filter, including soft-deleted ones.
+func FindReactionRolesWithDeleted(filter bson.M) (_ []*ReactionRole, err error) {
+
+  defer observeOperation("ReactionRole", "Find", time.Now(), &err)
+
+  results := []*ReactionRole{}
+  if err := net.MgoCol(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("ReactionRole", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountReactionRoles counts the ReactionRoles matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountReactionRoles(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("ReactionRole", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("ReactionRole", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ReactionRoleExists reports whether any ReactionRole matches the filter, excluding soft-deleted ones.
+func ReactionRoleExists(filter bson.M) (bool, error) {
+
+  count, err := CountReactionRoles(filter)
+  return count > 0, err
+}
+
+// DistinctReactionRoleFields finds the distinct values of the field among the ReactionRoles matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctReactionRoleFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("ReactionRole", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("ReactionRole", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctReactionRoleStringFields is DistinctReactionRoleFields for string fields, like emoji_id. It fails if
+// any of the values isn't a string.
+func DistinctReactionRoleStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctReactionRoleFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactReactionRoleCollection runs the compact command against the ReactionRole collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactReactionRoleCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName)
+}
+
+// ReactionRoleCollectionStats returns the ReactionRole collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ReactionRoleCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName)
+}
+
+// ExplainReactionRoleQuery returns MongoDB's explain output for finding the filter in the ReactionRole collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainReactionRoleQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, filter)
+}
+
+// UpdateManyReactionRoles applies the updates to every ReactionRole matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateReactionRoles for that. The ReactionRole cache is flushed.
+func UpdateManyReactionRoles(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, filter, updates)
+}
+
+// BulkUpdateReactionRoles is UpdateManyReactionRoles, but validates each ReactionRole as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateReactionRoles(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, filter, updates, func() validatable { return new(ReactionRole) })
+}
+
+// DeleteManyReactionRoles soft-deletes every ReactionRole matching the filter in a single query, returning how many
+// were deleted. The ReactionRole cache is evicted.
+func DeleteManyReactionRoles(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "ReactionRole", ReactionRoleClientName, ReactionRoleDBName, ReactionRoleColName, filter)
+}
+
+// FindByMessageAndEmoji finds the ReactionRole for the emoji on the message, using the cache.
+func FindByMessageAndEmoji(messageID, emojiID string) (*ReactionRole, error) {
+
+  return CacheGetReactionRole(messageID, emojiID, true)
+}
+
+// FindByMessage finds every ReactionRole on the message, using the cache. The whole list is cached
+// under a message-scoped key, which is evicted whenever one of the message's ReactionRoles changes.
+func FindByMessage(messageID string) (found []*ReactionRole, err error) {
+
+  client := net.RedisGetClient(ReactionRoleClientName)
+  cacheKey := reactionRoleMessageCacheKey(messageID)
+  _, span := startCacheSpan(context.Background(), "ReactionRole.FindByMessage", ReactionRoleDBName, ReactionRoleColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ReactionRole", "FindByMessage", time.Now(), &err)
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ReactionRole", "FindByMessage", "discord_message_id", messageID, err)
+  } else if result != "" {
+    recordCacheResult("ReactionRole", "discord_message_id", "hit")
+    roles := []*ReactionRole{}
+    if err := json.Unmarshal([]byte(result), &roles); err != nil {
+      return nil, wrapDBError("ReactionRole", "FindByMessage", "discord_message_id", messageID, err)
+    }
+    return roles, nil
+  }
+
+  // Get what's in the database and cache it.
+  recordCacheResult("ReactionRole", "discord_message_id", "miss")
+  roles, err := FindReactionRoles(context.Background(), bson.M{"discord_message_id": messageID}, "", 0)
+  if err != nil {
+    return nil, err
+  }
+  go fillCacheReactionRoles(client, cacheKey, roles)
+  return roles, nil
+}
@@ -0,0 +1,76 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "fmt"
+  "sort"
+  "sync"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+)
+
+// registeredModel is a model recorded with RegisterModel.
+type registeredModel struct {
+  col  func() *mgo.Collection
+  zero func() interface{}
+}
+
+var modelRegistry = map[string]registeredModel{}
+var modelRegistryMu sync.RWMutex
+
+// RegisterModel records a model under its name, with a function getting its collection and one making a
+// pointer to a new zero value of it, so tooling can work with every model without listing them. Every
+// model registers itself in an init function. Registering a name again replaces it.
+func RegisterModel(name string, col func() *mgo.Collection, zero func() interface{}) {
+
+  modelRegistryMu.Lock()
+  defer modelRegistryMu.Unlock()
+  modelRegistry[name] = registeredModel{col, zero}
+}
+
+// AllModelNames returns the names of every registered model, sorted.
+func AllModelNames() []string {
+
+  modelRegistryMu.RLock()
+  defer modelRegistryMu.RUnlock()
+  names := make([]string, 0, len(modelRegistry))
+  for name := range modelRegistry {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// ModelCollection gets a collection reference for the registered model.
+func ModelCollection(name string) (*mgo.Collection, error) {
+
+  model, err := registeredModelNamed(name)
+  if err != nil {
+    return nil, err
+  }
+  return model.col(), nil
+}
+
+// NewModel returns a pointer to a new zero value of the registered model, e.g. *Server for "Server".
+func NewModel(name string) (interface{}, error) {
+
+  model, err := registeredModelNamed(name)
+  if err != nil {
+    return nil, err
+  }
+  return model.zero(), nil
+}
+
+// registeredModelNamed finds the registered model.
+func registeredModelNamed(name string) (registeredModel, error) {
+
+  modelRegistryMu.RLock()
+  defer modelRegistryMu.RUnlock()
+  model, ok := modelRegistry[name]
+  if !ok {
+    return registeredModel{}, fmt.Errorf("unknown model %q", name)
+  }
+  return model, nil
+}
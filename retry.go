@@ -0,0 +1,80 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "io"
+  "math/rand"
+  "net"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/rs/zerolog/log"
+)
+
+// RetryConfig defines how transient MongoDB errors are retried.
+type RetryConfig struct {
+  MaxAttempts int           `json:"max_attempts"`
+  BaseDelay   time.Duration `json:"base_delay"`
+}
+
+// DefaultRetryMaxAttempts is the default number of attempts made for an operation.
+const DefaultRetryMaxAttempts = 3
+
+// DefaultRetryBaseDelay is the default delay before the first retry, doubled for each one after.
+const DefaultRetryBaseDelay = 100*time.Millisecond
+
+// withRetry runs op up to maxAttempts times, retrying only errors which look transient. The delay before
+// each retry doubles from base, with jitter so concurrent callers don't retry in lockstep. It stops
+// waiting and returns the last error if ctx is done during a delay.
+func withRetry(ctx context.Context, op func() error, maxAttempts int, base time.Duration) error {
+
+  if maxAttempts < 1 {
+    maxAttempts = 1
+  }
+
+  var err error
+  for attempt := 1; attempt <= maxAttempts; attempt++ {
+    if err = op(); err == nil || !isTransient(err) {
+      return err
+    }
+    if attempt == maxAttempts {
+      break
+    }
+
+    // Wait between half and all of the backoff before trying again.
+    backoff := base << uint(attempt-1)
+    delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+    log.Warn().AnErr("withRetry", err).Int("attempt", attempt).Msgf("Retrying transient database error in %v", delay)
+    timer := time.NewTimer(delay)
+    select {
+    case <-ctx.Done():
+      timer.Stop()
+      return err
+    case <-timer.C:
+    }
+  }
+
+  log.Warn().AnErr("withRetry", err).Int("attempts", maxAttempts).Msgf("Giving up on transient database error")
+  return err
+}
+
+// isTransient reports whether err looks like a temporary failure worth retrying. Context errors never
+// are, even though context.DeadlineExceeded is a timeout, since retrying can't succeed once the
+// context is done.
+func isTransient(err error) bool {
+
+  if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+    return false
+  }
+  if err == io.EOF || err == mgo.ErrCursor {
+    return true
+  }
+  if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+    return true
+  }
+  return false
+}
@@ -0,0 +1,675 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// RoleAssignmentClientName is the name of the MgoDriver to use for RoleAssignment.
+const RoleAssignmentClientName = "main"
+
+// RoleAssignmentDBName is the name of the database to use for RoleAssignment.
+const RoleAssignmentDBName = "badpetbot"
+
+// RoleAssignmentColName is the name of the collection to use for RoleAssignment.
+const RoleAssignmentColName = "role_assignments"
+
+// RoleAssignmentModelVersion is the current schema version of RoleAssignment documents, stored in their ModelVersion.
+const RoleAssignmentModelVersion = 1
+
+// RoleAssignmentCol gets a collection reference for RoleAssignment.
+func RoleAssignmentCol() *mgo.Collection {
+  return net.MgoCol(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName)
+}
+
+// Register RoleAssignment, so tooling can find it.
+func init() {
+  RegisterModel("RoleAssignment", RoleAssignmentCol, func() interface{} { return new(RoleAssignment) })
+}
+
+// EnsureRoleAssignmentIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureRoleAssignmentIndices() error {
+
+  col := RoleAssignmentCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id", "assigned_to_discord_id", "assigned_at"}, Background: true}); err != nil {
+    return wrapDBError("RoleAssignment", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_server_id: 1, assigned_to_discord_id: 1, assigned_at: 1 }
+
+// RoleAssignment records a Discord role being assigned to a member, by whom, and whether and by whom it
+// was later revoked.
+type RoleAssignment struct {
+  // ID is a BSON ID generated in Create.
+  ID                  bson.ObjectId   `bson:"_id"                      json:"_id"                      validate:"required"`
+  DiscordServerID     string          `bson:"discord_server_id"        json:"discord_server_id"        validate:"required,discord_id"`
+  DiscordRoleID       string          `bson:"discord_role_id"          json:"discord_role_id"          validate:"required,discord_id"`
+  AssignedToDiscordID string          `bson:"assigned_to_discord_id"   json:"assigned_to_discord_id"   validate:"required,discord_id"`
+  AssignedByDiscordID string          `bson:"assigned_by_discord_id"   json:"assigned_by_discord_id"   validate:"required,discord_id"`
+  AssignedAt          time.Time       `bson:"assigned_at"              json:"assigned_at"              validate:"required"`
+  // RemovedAt and RemovedByDiscordID are set by Revoke. Nil means the role is still assigned.
+  RemovedAt           *time.Time      `bson:"removed_at"               json:"removed_at"               validate:"-"`
+  RemovedByDiscordID  *string         `bson:"removed_by_discord_id"    json:"removed_by_discord_id"    validate:"omitempty,discord_id"`
+  CreatedAt           time.Time       `bson:"created_at"               json:"created_at"               validate:"required"`
+  UpdatedAt           time.Time       `bson:"updated_at"               json:"updated_at"               validate:"required"`
+  DeletedAt           *time.Time      `bson:"deleted_at"               json:"deleted_at"               validate:"-"`
+  Version             int             `bson:"version"                  json:"version"                  validate:"-"`
+  ModelVersion        int             `bson:"model_version"            json:"model_version"            validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *RoleAssignment) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "RoleAssignment.Create", RoleAssignmentDBName, RoleAssignmentColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("RoleAssignment", "Create", time.Now(), &err)
+  defer logSlowQuery("RoleAssignment", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = RoleAssignmentModelVersion
+
+  // Ensure defaults.
+  if this.AssignedAt.IsZero() {
+    this.AssignedAt = now
+  }
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the RoleAssignment.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("RoleAssignment", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *RoleAssignment) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *RoleAssignment) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *RoleAssignment) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "RoleAssignment.Update", RoleAssignmentDBName, RoleAssignmentColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("RoleAssignment", "Update", time.Now(), &err)
+  defer logSlowQuery("RoleAssignment", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheRoleAssignment(net.RedisGetClient(RoleAssignmentClientName), this.cacheKeys())
+  }
+  return wrapDBError("RoleAssignment", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *RoleAssignment) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "RoleAssignment.Delete", RoleAssignmentDBName, RoleAssignmentColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("RoleAssignment", "Delete", time.Now(), &err)
+  defer logSlowQuery("RoleAssignment", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the RoleAssignment.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheRoleAssignment(net.RedisGetClient(RoleAssignmentClientName), this.cacheKeys())
+  }
+  return wrapDBError("RoleAssignment", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// Revoke marks the role as removed now by the given actor. The assignment is kept for history. Returns
+// ErrAlreadyRevoked if it was already revoked, whether in memory or by someone else in the meantime.
+func (this *RoleAssignment) Revoke(actorID string) error {
+
+  if actorID == "" {
+    return errors.New("can't revoke a role assignment without an actor ID")
+  }
+  if this.RemovedAt != nil {
+    return ErrAlreadyRevoked
+  }
+
+  // Set the fields in memory first, so the actor is validated, and clear them if the update fails. The
+  // update only applies while the stored assignment isn't revoked.
+  now := clockNow()
+  this.RemovedAt, this.RemovedByDiscordID = &now, &actorID
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "removed_at": nil}, bson.M{
+    "$set": bson.M{"removed_at": now, "removed_by_discord_id": actorID},
+  })
+  if err != nil {
+    this.RemovedAt, this.RemovedByDiscordID = nil, nil
+  }
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrAlreadyRevoked
+  }
+  return err
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *RoleAssignment) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *RoleAssignment) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *RoleAssignment) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("RoleAssignment", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("RoleAssignment", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheRoleAssignment(net.RedisGetClient(RoleAssignmentClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *RoleAssignment) Validate(ctx context.Context) error {
+
+  return wrapValidationError("RoleAssignment", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *RoleAssignment) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("RoleAssignment", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("RoleAssignment", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *RoleAssignment) Clone() *RoleAssignment {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*RoleAssignment)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *RoleAssignment) Diff(other *RoleAssignment) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *RoleAssignment) DiffDeep(other *RoleAssignment) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g.
+// "assigned_to_discord_id". Until the role is revoked, "removed_at" and "removed_by_discord_id" are absent.
+func (this *RoleAssignment) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *RoleAssignment) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *RoleAssignment) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *RoleAssignment) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *RoleAssignment) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *RoleAssignment) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the RoleAssignment is uninitialized, having neither an ID nor a DiscordRoleID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *RoleAssignment) IsZero() bool {
+  return this.ID == "" && this.DiscordRoleID == ""
+}
+
+// Age returns how long ago the RoleAssignment was created.
+func (this *RoleAssignment) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the RoleAssignment was last updated.
+func (this *RoleAssignment) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the RoleAssignment hasn't been updated for longer than the threshold.
+func (this *RoleAssignment) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetRoleAssignment attempts to find a RoleAssignment by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetRoleAssignment(key, value string, negCache bool) (found *RoleAssignment, err error) {
+
+  client := net.RedisGetClient(RoleAssignmentClientName)
+  cacheKey := RoleAssignmentClientName+":"+RoleAssignmentDBName+":"+RoleAssignmentColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "RoleAssignment.CacheGet", RoleAssignmentDBName, RoleAssignmentColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("RoleAssignment", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("RoleAssignment", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("RoleAssignment", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "RoleAssignment", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("RoleAssignment", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("RoleAssignment", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    roleAssignment := new(RoleAssignment)
+    if err := json.Unmarshal([]byte(result), roleAssignment); err != nil {
+      return nil, wrapDBError("RoleAssignment", "CacheGet", key, value, err)
+    }
+    return roleAssignment, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("RoleAssignment", key, "miss")
+  roleAssignment := new(RoleAssignment)
+  err = net.MgoCol(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(roleAssignment)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheRoleAssignment(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheRoleAssignment(client, cacheKey, roleAssignment)
+  }
+  if err != nil {
+    return nil, wrapDBError("RoleAssignment", "CacheGet", key, value, err)
+  }
+  return roleAssignment, nil
+}
+
+func fillCacheRoleAssignment(client *redis.Client, key string, value *RoleAssignment) {
+  _, span := startCacheSpan(context.Background(), "RoleAssignment.fillCache", RoleAssignmentDBName, RoleAssignmentColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for RoleAssignment")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for RoleAssignment")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheRoleAssignment(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "RoleAssignment.fillNegCache", RoleAssignmentDBName, RoleAssignmentColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for RoleAssignment")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheRoleAssignment(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for RoleAssignment")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetRoleAssignment.
+func (this *RoleAssignment) cacheKeys() []string {
+  prefix := RoleAssignmentClientName+":"+RoleAssignmentDBName+":"+RoleAssignmentColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateRoleAssignments runs the aggregation pipeline on the RoleAssignment collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateRoleAssignments(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("RoleAssignment", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, pipeline, opts)
+  defer done()
+  return wrapDBError("RoleAssignment", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateRoleAssignmentOne runs the aggregation pipeline on the RoleAssignment collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateRoleAssignmentOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("RoleAssignment", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, pipeline, opts)
+  defer done()
+  return wrapDBError("RoleAssignment", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyRoleAssignment atomically applies the update to the first RoleAssignment matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyRoleAssignment(selector, update bson.M, returnNew bool) (_ *RoleAssignment, err error) {
+
+  defer observeOperation("RoleAssignment", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(RoleAssignment)
+  _, err = RoleAssignmentCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("RoleAssignment", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheRoleAssignment(net.RedisGetClient(RoleAssignmentClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindRoleAssignments finds all RoleAssignments matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindRoleAssignments(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*RoleAssignment, err error) {
+
+  defer observeOperation("RoleAssignment", "Find", time.Now(), &err)
+  defer logSlowQuery("RoleAssignment", "Find", filter)()
+
+  results := []*RoleAssignment{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("RoleAssignment", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindRoleAssignmentsWithDeleted finds all RoleAssignments matching the filter, including soft-deleted ones.
+func FindRoleAssignmentsWithDeleted(filter bson.M) (_ []*RoleAssignment, err error) {
+
+  defer observeOperation("RoleAssignment", "Find", time.Now(), &err)
+
+  results := []*RoleAssignment{}
+  if err := net.MgoCol(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("RoleAssignment", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountRoleAssignments counts the RoleAssignments matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountRoleAssignments(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("RoleAssignment", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("RoleAssignment", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// RoleAssignmentExists reports whether any RoleAssignment matches the filter, excluding soft-deleted ones.
+func RoleAssignmentExists(filter bson.M) (bool, error) {
+
+  count, err := CountRoleAssignments(filter)
+  return count > 0, err
+}
+
+// DistinctRoleAssignmentFields finds the distinct values of the field among the RoleAssignments matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctRoleAssignmentFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("RoleAssignment", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("RoleAssignment", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctRoleAssignmentStringFields is DistinctRoleAssignmentFields for string fields, like discord_role_id. It fails if
+// any of the values isn't a string.
+func DistinctRoleAssignmentStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctRoleAssignmentFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactRoleAssignmentCollection runs the compact command against the RoleAssignment collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactRoleAssignmentCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName)
+}
+
+// RoleAssignmentCollectionStats returns the RoleAssignment collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func RoleAssignmentCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName)
+}
+
+// ExplainRoleAssignmentQuery returns MongoDB's explain output for finding the filter in the RoleAssignment collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainRoleAssignmentQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, filter)
+}
+
+// UpdateManyRoleAssignments applies the updates to every RoleAssignment matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateRoleAssignments for that. The RoleAssignment cache is flushed.
+func UpdateManyRoleAssignments(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, filter, updates)
+}
+
+// BulkUpdateRoleAssignments is UpdateManyRoleAssignments, but validates each RoleAssignment as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateRoleAssignments(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, filter, updates, func() validatable { return new(RoleAssignment) })
+}
+
+// DeleteManyRoleAssignments soft-deletes every RoleAssignment matching the filter in a single query, returning how many
+// were deleted. The RoleAssignment cache is evicted.
+func DeleteManyRoleAssignments(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "RoleAssignment", RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, filter)
+}
+
+// FindActiveRoles finds the member's role assignments in the server which haven't been revoked.
+func FindActiveRoles(serverID, memberID string) ([]*RoleAssignment, error) {
+
+  return FindRoleAssignments(context.Background(), bson.M{
+    "discord_server_id":      serverID,
+    "assigned_to_discord_id": memberID,
+    "removed_at":             nil,
+  }, "assigned_at", 0)
+}
+
+// FindAssignmentHistory finds all of the member's role assignments in the server, including revoked
+// ones, oldest first.
+func FindAssignmentHistory(serverID, memberID string) ([]*RoleAssignment, error) {
+
+  return FindRoleAssignments(context.Background(), bson.M{
+    "discord_server_id":      serverID,
+    "assigned_to_discord_id": memberID,
+  }, "assigned_at", 0)
+}
@@ -0,0 +1,69 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "fmt"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// MaxSampleSize is the most documents SampleDocuments and SampleServer will return, so a typo can't
+// pull a whole collection.
+const MaxSampleSize = 1000
+
+// samplePipeline picks n random documents, excluding soft-deleted ones.
+func samplePipeline(n int) ([]bson.M, error) {
+
+  if n < 1 || n > MaxSampleSize {
+    return nil, fmt.Errorf("can't sample %d documents, must be between 1 and %d", n, MaxSampleSize)
+  }
+  return []bson.M{
+    {"$match": bson.M{"deleted_at": nil}},
+    {"$sample": bson.M{"size": n}},
+  }, nil
+}
+
+// SampleDocuments picks n random ServerMembers, excluding soft-deleted ones, e.g. for load testing.
+// n can be at most MaxSampleSize. This does not touch the cache.
+func SampleDocuments(ctx context.Context, n int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Sample", time.Now(), &err)
+
+  pipeline, err := samplePipeline(n)
+  if err != nil {
+    return nil, err
+  }
+  results := []*ServerMember{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Pipe(pipeline).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "Sample", "", "", err)
+  }
+  return results, nil
+}
+
+// SampleServer picks n random Servers, excluding soft-deleted ones, e.g. for load testing. n can be
+// at most MaxSampleSize. This does not touch the cache.
+func SampleServer(ctx context.Context, n int) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "Sample", time.Now(), &err)
+
+  pipeline, err := samplePipeline(n)
+  if err != nil {
+    return nil, err
+  }
+  results := []*Server{}
+  err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+    return col.Pipe(pipeline).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Server", "Sample", "", "", err)
+  }
+  return results, nil
+}
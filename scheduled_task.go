@@ -0,0 +1,664 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// ScheduledTaskClientName is the name of the MgoDriver to use for ScheduledTask.
+const ScheduledTaskClientName = "main"
+
+// ScheduledTaskDBName is the name of the database to use for ScheduledTask.
+const ScheduledTaskDBName = "badpetbot"
+
+// ScheduledTaskColName is the name of the collection to use for ScheduledTask.
+const ScheduledTaskColName = "scheduled_tasks"
+
+// ScheduledTaskModelVersion is the current schema version of ScheduledTask documents, stored in their ModelVersion.
+const ScheduledTaskModelVersion = 1
+
+// Task types, used for ScheduledTask.TaskType.
+const (
+  TaskTypeRemoveRole  = "remove_role"
+  TaskTypeUnban       = "unban"
+  TaskTypeSendMessage = "send_message"
+)
+
+// ScheduledTaskCol gets a collection reference for ScheduledTask.
+func ScheduledTaskCol() *mgo.Collection {
+  return net.MgoCol(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName)
+}
+
+// Register ScheduledTask, so tooling can find it.
+func init() {
+  RegisterModel("ScheduledTask", ScheduledTaskCol, func() interface{} { return new(ScheduledTask) })
+}
+
+// EnsureScheduledTaskIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureScheduledTaskIndices() error {
+
+  col := ScheduledTaskCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"run_at", "completed_at"}, Background: true}); err != nil {
+    return wrapDBError("ScheduledTask", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { run_at: 1, completed_at: 1 }
+
+// ScheduledTask is a deferred action the bot runs at RunAt, persisted so it survives restarts. A task
+// is pending until it's marked complete or failed.
+type ScheduledTask struct {
+  // ID is a BSON ID generated in Create.
+  ID                  bson.ObjectId   `bson:"_id"                     json:"_id"                     validate:"required"`
+  DiscordServerID     string          `bson:"discord_server_id"       json:"discord_server_id"       validate:"required,discord_id"`
+  TargetDiscordUserID string          `bson:"target_discord_user_id"  json:"target_discord_user_id"  validate:"required,discord_id"`
+  TaskType            string          `bson:"task_type"               json:"task_type"               validate:"oneof=remove_role unban send_message"`
+  Payload             bson.M          `bson:"payload"                 json:"payload"                 validate:"-"`
+  RunAt               time.Time       `bson:"run_at"                  json:"run_at"                  validate:"required"`
+  CompletedAt         *time.Time      `bson:"completed_at"            json:"completed_at"            validate:"-"`
+  FailedAt            *time.Time      `bson:"failed_at"               json:"failed_at"               validate:"-"`
+  FailReason          *string         `bson:"fail_reason"             json:"fail_reason"             validate:"-"`
+  CreatedAt           time.Time       `bson:"created_at"              json:"created_at"              validate:"required"`
+  UpdatedAt           time.Time       `bson:"updated_at"              json:"updated_at"              validate:"required"`
+  DeletedAt           *time.Time      `bson:"deleted_at"              json:"deleted_at"              validate:"-"`
+  Version             int             `bson:"version"                 json:"version"                 validate:"-"`
+  ModelVersion        int             `bson:"model_version"           json:"model_version"           validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *ScheduledTask) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ScheduledTask.Create", ScheduledTaskDBName, ScheduledTaskColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ScheduledTask", "Create", time.Now(), &err)
+  defer logSlowQuery("ScheduledTask", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ScheduledTaskModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the ScheduledTask.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("ScheduledTask", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *ScheduledTask) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *ScheduledTask) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *ScheduledTask) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ScheduledTask.Update", ScheduledTaskDBName, ScheduledTaskColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ScheduledTask", "Update", time.Now(), &err)
+  defer logSlowQuery("ScheduledTask", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheScheduledTask(net.RedisGetClient(ScheduledTaskClientName), this.cacheKeys())
+  }
+  return wrapDBError("ScheduledTask", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *ScheduledTask) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "ScheduledTask.Delete", ScheduledTaskDBName, ScheduledTaskColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ScheduledTask", "Delete", time.Now(), &err)
+  defer logSlowQuery("ScheduledTask", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the ScheduledTask.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheScheduledTask(net.RedisGetClient(ScheduledTaskClientName), this.cacheKeys())
+  }
+  return wrapDBError("ScheduledTask", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// MarkComplete marks the task as completed now, so it's no longer pending.
+func (this *ScheduledTask) MarkComplete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"completed_at": now}}); err != nil {
+    return err
+  }
+  this.CompletedAt = &now
+  return nil
+}
+
+// MarkFailed marks the task as failed now for the given reason, so it's no longer pending.
+func (this *ScheduledTask) MarkFailed(reason string) error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"failed_at": now, "fail_reason": reason}}); err != nil {
+    return err
+  }
+  this.FailedAt = &now
+  this.FailReason = &reason
+  return nil
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *ScheduledTask) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *ScheduledTask) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *ScheduledTask) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("ScheduledTask", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("ScheduledTask", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheScheduledTask(net.RedisGetClient(ScheduledTaskClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *ScheduledTask) Validate(ctx context.Context) error {
+
+  return wrapValidationError("ScheduledTask", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *ScheduledTask) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("ScheduledTask", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("ScheduledTask", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *ScheduledTask) Clone() *ScheduledTask {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*ScheduledTask)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *ScheduledTask) Diff(other *ScheduledTask) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *ScheduledTask) DiffDeep(other *ScheduledTask) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, using dotted keys
+// for the payload's fields, e.g. "payload.<key>" for each key in Payload.
+func (this *ScheduledTask) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *ScheduledTask) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *ScheduledTask) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *ScheduledTask) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *ScheduledTask) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *ScheduledTask) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the ScheduledTask is uninitialized, having neither an ID nor a TargetDiscordUserID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *ScheduledTask) IsZero() bool {
+  return this.ID == "" && this.TargetDiscordUserID == ""
+}
+
+// Age returns how long ago the ScheduledTask was created.
+func (this *ScheduledTask) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the ScheduledTask was last updated.
+func (this *ScheduledTask) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the ScheduledTask hasn't been updated for longer than the threshold.
+func (this *ScheduledTask) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetScheduledTask attempts to find a ScheduledTask by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetScheduledTask(key, value string, negCache bool) (found *ScheduledTask, err error) {
+
+  client := net.RedisGetClient(ScheduledTaskClientName)
+  cacheKey := ScheduledTaskClientName+":"+ScheduledTaskDBName+":"+ScheduledTaskColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "ScheduledTask.CacheGet", ScheduledTaskDBName, ScheduledTaskColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ScheduledTask", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ScheduledTask", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("ScheduledTask", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ScheduledTask", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ScheduledTask", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("ScheduledTask", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    scheduledTask := new(ScheduledTask)
+    if err := json.Unmarshal([]byte(result), scheduledTask); err != nil {
+      return nil, wrapDBError("ScheduledTask", "CacheGet", key, value, err)
+    }
+    return scheduledTask, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("ScheduledTask", key, "miss")
+  scheduledTask := new(ScheduledTask)
+  err = net.MgoCol(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(scheduledTask)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheScheduledTask(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheScheduledTask(client, cacheKey, scheduledTask)
+  }
+  if err != nil {
+    return nil, wrapDBError("ScheduledTask", "CacheGet", key, value, err)
+  }
+  return scheduledTask, nil
+}
+
+func fillCacheScheduledTask(client *redis.Client, key string, value *ScheduledTask) {
+  _, span := startCacheSpan(context.Background(), "ScheduledTask.fillCache", ScheduledTaskDBName, ScheduledTaskColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ScheduledTask")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ScheduledTask")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheScheduledTask(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "ScheduledTask.fillNegCache", ScheduledTaskDBName, ScheduledTaskColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ScheduledTask")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheScheduledTask(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for ScheduledTask")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetScheduledTask.
+func (this *ScheduledTask) cacheKeys() []string {
+  prefix := ScheduledTaskClientName+":"+ScheduledTaskDBName+":"+ScheduledTaskColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateScheduledTasks runs the aggregation pipeline on the ScheduledTask collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateScheduledTasks(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ScheduledTask", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ScheduledTask", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateScheduledTaskOne runs the aggregation pipeline on the ScheduledTask collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateScheduledTaskOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ScheduledTask", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ScheduledTask", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyScheduledTask atomically applies the update to the first ScheduledTask matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyScheduledTask(selector, update bson.M, returnNew bool) (_ *ScheduledTask, err error) {
+
+  defer observeOperation("ScheduledTask", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(ScheduledTask)
+  _, err = ScheduledTaskCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("ScheduledTask", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheScheduledTask(net.RedisGetClient(ScheduledTaskClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindScheduledTasks finds all ScheduledTasks matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindScheduledTasks(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*ScheduledTask, err error) {
+
+  defer observeOperation("ScheduledTask", "Find", time.Now(), &err)
+  defer logSlowQuery("ScheduledTask", "Find", filter)()
+
+  results := []*ScheduledTask{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ScheduledTask", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindScheduledTasksWithDeleted finds all ScheduledTasks matching the filter, including soft-deleted ones.
+func FindScheduledTasksWithDeleted(filter bson.M) (_ []*ScheduledTask, err error) {
+
+  defer observeOperation("ScheduledTask", "Find", time.Now(), &err)
+
+  results := []*ScheduledTask{}
+  if err := net.MgoCol(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("ScheduledTask", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountScheduledTasks counts the ScheduledTasks matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountScheduledTasks(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("ScheduledTask", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("ScheduledTask", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ScheduledTaskExists reports whether any ScheduledTask matches the filter, excluding soft-deleted ones.
+func ScheduledTaskExists(filter bson.M) (bool, error) {
+
+  count, err := CountScheduledTasks(filter)
+  return count > 0, err
+}
+
+// DistinctScheduledTaskFields finds the distinct values of the field among the ScheduledTasks matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctScheduledTaskFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("ScheduledTask", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("ScheduledTask", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctScheduledTaskStringFields is DistinctScheduledTaskFields for string fields, like task_type. It fails if
+// any of the values isn't a string.
+func DistinctScheduledTaskStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctScheduledTaskFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactScheduledTaskCollection runs the compact command against the ScheduledTask collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactScheduledTaskCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName)
+}
+
+// ScheduledTaskCollectionStats returns the ScheduledTask collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ScheduledTaskCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName)
+}
+
+// ExplainScheduledTaskQuery returns MongoDB's explain output for finding the filter in the ScheduledTask collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainScheduledTaskQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, filter)
+}
+
+// UpdateManyScheduledTasks applies the updates to every ScheduledTask matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateScheduledTasks for that. The ScheduledTask cache is flushed.
+func UpdateManyScheduledTasks(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, filter, updates)
+}
+
+// BulkUpdateScheduledTasks is UpdateManyScheduledTasks, but validates each ScheduledTask as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateScheduledTasks(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, filter, updates, func() validatable { return new(ScheduledTask) })
+}
+
+// DeleteManyScheduledTasks soft-deletes every ScheduledTask matching the filter in a single query, returning how many
+// were deleted. The ScheduledTask cache is evicted.
+func DeleteManyScheduledTasks(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "ScheduledTask", ScheduledTaskClientName, ScheduledTaskDBName, ScheduledTaskColName, filter)
+}
+
+// FindPendingTasks finds tasks due to run at or before the given time which haven't completed or
+// failed, soonest first. A limit of 0 means no limit.
+func FindPendingTasks(before time.Time, limit int) ([]*ScheduledTask, error) {
+
+  return FindScheduledTasks(context.Background(), bson.M{
+    "run_at":       bson.M{"$lte": before},
+    "completed_at": nil,
+    "failed_at":    nil,
+  }, "run_at", limit)
+}
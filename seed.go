@@ -0,0 +1,93 @@
+//go:build test
+// +build test
+
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "hash/fnv"
+  "math/rand"
+  "strconv"
+  "time"
+)
+
+// seedSnowflakes generates Discord snowflakes for seeded documents. Each snowflake it returns is unique
+// within the generator.
+type seedSnowflakes struct {
+  random *rand.Rand
+  seen   map[string]bool
+}
+
+// newSeedSnowflakes creates a snowflake generator.
+func newSeedSnowflakes() *seedSnowflakes {
+  return &seedSnowflakes{random: rand.New(rand.NewSource(time.Now().UnixNano())), seen: map[string]bool{}}
+}
+
+// next returns a random 17 digit snowflake not returned before.
+func (this *seedSnowflakes) next() string {
+
+  for {
+    id := strconv.FormatInt(1e16+this.random.Int63n(9e16), 10)
+    if !this.seen[id] {
+      this.seen[id] = true
+      return id
+    }
+  }
+}
+
+// combine returns an 18 digit snowflake derived from both IDs, or "" if it was returned before.
+func (this *seedSnowflakes) combine(serverID, userID string) string {
+
+  hash := fnv.New64a()
+  hash.Write([]byte(serverID + ":" + userID))
+  id := strconv.FormatUint(1e17+hash.Sum64()%9e17, 10)
+  if this.seen[id] {
+    return ""
+  }
+  this.seen[id] = true
+  return id
+}
+
+// SeedServerMember creates count ServerMembers in the Discord server, with random, valid, and unique
+// Discord user IDs, and member IDs derived from the server and user IDs. If a Create fails, the members
+// created so far are returned with the error. Only built with the test build tag.
+func SeedServerMember(serverID string, count int) ([]*ServerMember, error) {
+
+  snowflakes := newSeedSnowflakes()
+  members := make([]*ServerMember, 0, count)
+  for len(members) < count {
+    userID := snowflakes.next()
+    memberID := snowflakes.combine(serverID, userID)
+    if memberID == "" {
+      continue
+    }
+    member := &ServerMember{
+      DiscordUserID:   EncryptedString(userID),
+      DiscordServerID: serverID,
+      DiscordMemberID: memberID,
+    }
+    if err := member.Create(context.Background()); err != nil {
+      return members, err
+    }
+    members = append(members, member)
+  }
+  return members, nil
+}
+
+// SeedServer creates count Servers with random, valid, and unique Discord IDs. If a Create fails, the
+// servers created so far are returned with the error. Only built with the test build tag.
+func SeedServer(count int) ([]*Server, error) {
+
+  snowflakes := newSeedSnowflakes()
+  servers := make([]*Server, 0, count)
+  for len(servers) < count {
+    server := &Server{DiscordID: snowflakes.next()}
+    if err := server.Create(context.Background()); err != nil {
+      return servers, err
+    }
+    servers = append(servers, server)
+  }
+  return servers, nil
+}
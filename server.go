@@ -3,7 +3,12 @@ package gomodel
 import (
 
 	// Import builtin packages.
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	// Import 3rd party packages.
@@ -14,7 +19,6 @@ import (
 
 	// Import internal packages.
 	"github.com/badpetbot/gocommon/net"
-	"github.com/badpetbot/gocommon/validation"
 )
 
 // ServerClientName is the name of the MgoDriver to use for Server.
@@ -26,142 +30,961 @@ const ServerDBName = "badpetbot"
 // ServerColName is the name of the collection to use for Server.
 const ServerColName = "servers"
 
-// CacheTTL is the time in seconds documents can remain in cache.
-const CacheTTL = 120*time.Second
-
-// NegCacheTTL is the time in seconds neg-cache can remain in cache.
-const NegCacheTTL = 60*time.Second
+// ServerModelVersion is the current schema version of Server documents, stored in their ModelVersion.
+const ServerModelVersion = 1
 
 // ServerCol gets a collection reference for Server.
 func ServerCol() *mgo.Collection {
   return net.MgoCol(ServerClientName, ServerDBName, ServerColName)
 }
 
+// Register Server, so tooling can find it.
+func init() {
+  RegisterModel("Server", ServerCol, func() interface{} { return new(Server) })
+}
+
+// ServerStore gets the store for Server: the one injected with SetModelStore if there is one, or else
+// the database.
+func ServerStore() ModelStore {
+  return modelStore(context.Background(), ServerClientName, ServerDBName, ServerColName, nil)
+}
+
+// serverStore is ServerStore, with the database store running its operations with ctx and the options.
+func serverStore(ctx context.Context, opts ...QueryOption) ModelStore {
+  return modelStore(ctx, ServerClientName, ServerDBName, ServerColName, opts)
+}
+
+// EnsureServerIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureServerIndices() error {
+
+  col := ServerCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("Server", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
 // INDICES:
 // { _id: 1 }
-// { discord_id: 1 }
+// { discord_id: 1 } unique
 
 // Server is a single Discord "guild" (colloquially known as a server).
 type Server struct {
   // ID is a BSON ID generated in Create.
-  ID        bson.ObjectId   `bson:"_id"         json:"_id"        validate:"required"`
-  DiscordID string          `bson:"discord_id"  json:"discord_id" validate:"required"`
-  CreatedAt time.Time       `bson:"created_at"  json:"created_at" validate:"required"`
-  UpdatedAt time.Time       `bson:"updated_at"  json:"updated_at" validate:"required"`
+  ID           bson.ObjectId   `bson:"_id"            json:"_id"            validate:"required"`
+  DiscordID    string          `bson:"discord_id"     json:"discord_id"     validate:"required,discord_id"`
+  CreatedAt    time.Time       `bson:"created_at"     json:"created_at"     validate:"required"`
+  UpdatedAt    time.Time       `bson:"updated_at"     json:"updated_at"     validate:"required"`
+  DeletedAt    *time.Time      `bson:"deleted_at"     json:"deleted_at"     validate:"-"`
+  Version      int             `bson:"version"        json:"version"        validate:"-"`
+  ModelVersion int             `bson:"model_version"  json:"model_version"  validate:"-"`
+}
+
+// NewServerWithDefaults builds a Server for the Discord ID which passes Validate as-is. Its ID and
+// timestamps are filled in too, but Create replaces them.
+func NewServerWithDefaults(discordID string) *Server {
+
+  now := clockNow()
+  return &Server{
+    ID:           bson.NewObjectId(),
+    DiscordID:    discordID,
+    CreatedAt:    now,
+    UpdatedAt:    now,
+    Version:      1,
+    ModelVersion: ServerModelVersion,
+  }
 }
 
 // Create persists the document in the database. It can optionally run validations if present and
 // prevent model persistence if they do not pass.
-func (this *Server) Create() error {
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *Server) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Server.Create", ServerDBName, ServerColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Server", "Create", time.Now(), &err)
+  defer logSlowQuery("Server", "Create", nil)()
 
   // Ensure ID, timestamps, and tokens.
   this.ID = bson.NewObjectId()
-  now := time.Now()
+  now := clockNow()
   this.CreatedAt = now
   this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ServerModelVersion
 
   // Ensure defaults.
 
-  // Run validations and return if they fail.
-  if err := this.Validate(); err != nil {
+  // Run hooks, then validations, and return if either fails.
+  if err := this.runHooks(HookBeforeCreate); err != nil {
+    return err
+  }
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the Server.
-  return net.MgoCol(ServerClientName, ServerDBName, ServerColName).Insert(this)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return serverStore(ctx, opts...).Insert(this)
+  }, retry.MaxAttempts, retry.BaseDelay)
+  if err != nil {
+    return wrapDBError("Server", "Create", "_id", this.ID.Hex(), err)
+  }
+  return this.runHooks(HookAfterCreate)
 }
 
 // Update updates the document in the database. Important note, this function does NOT prepend
-// the provided updates with "$set" or any other operator.
-func (this *Server) Update(updates bson.M) error {
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *Server) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *Server) UpdateWithVersion(updates bson.M, expectedVersion int) error {
 
-  // Update updated-at timestamp.
-  this.UpdatedAt = time.Now()
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *Server) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Server.Update", ServerDBName, ServerColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Server", "Update", time.Now(), &err)
+  defer logSlowQuery("Server", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
   _, setting := updates["$set"]
   if !setting {
     updates["$set"] = bson.M{}
   }
   updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
 
-  if err := this.Validate(); err != nil {
+  if err := this.runHooks(HookBeforeUpdate); err != nil {
+    return err
+  }
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the updates.
-  return net.MgoCol(ServerClientName, ServerDBName, ServerColName).UpdateId(this.ID, updates)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return storeUpdate(serverStore(ctx, opts...), selector, updates)
+  }, retry.MaxAttempts, retry.BaseDelay)
+  if err != nil {
+    return wrapDBError("Server", "Update", "_id", this.ID.Hex(), err)
+  }
+
+  // Note the new version and evict stale cache entries.
+  this.Version++
+  if cacheEnabled(ServerColName) {
+    go invalidateCacheServer(net.RedisGetClient(ServerClientName), this.cacheKeys())
+  }
+  return this.runHooks(HookAfterUpdate)
+}
+
+// Upsert inserts the document if no Server with the same DiscordID exists, or updates the existing
+// one. ID and CreatedAt are only persisted on insert; UpdatedAt is always refreshed.
+func (this *Server) Upsert() (err error) {
+
+  defer observeOperation("Server", "Upsert", time.Now(), &err)
+
+  // Ensure ID and timestamps. The ID and created-at are only used if this turns out to be an insert.
+  now := clockNow()
+  if !this.ID.Valid() {
+    this.ID = bson.NewObjectId()
+  }
+  if this.CreatedAt.IsZero() {
+    this.CreatedAt = now
+  }
+  this.UpdatedAt = now
+  this.ModelVersion = ServerModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(context.Background()); err != nil {
+    return err
+  }
+
+  // Everything but the ID and created-at is always set, and the version is incremented.
+  set, err := bsonFields(this, "_id", "created_at", "version")
+  if err != nil {
+    return wrapDBError("Server", "Upsert", "", "", err)
+  }
+
+  // Persist the Server.
+  col := net.MgoCol(ServerClientName, ServerDBName, ServerColName)
+  selector := bson.M{"discord_id": this.DiscordID}
+  updates := bson.M{
+    "$setOnInsert": bson.M{"_id": this.ID, "created_at": this.CreatedAt},
+    "$set":         set,
+    "$inc":         bson.M{"version": 1},
+  }
+  info, err := col.Upsert(selector, updates)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. Retrying
+  // updates the winner's document instead.
+  if mgo.IsDup(err) {
+    info, err = col.Upsert(selector, updates)
+  }
+  if err != nil {
+    return wrapDBError("Server", "Upsert", "", "", err)
+  }
+
+  // If it was a fresh insert, take the upserted ID. Otherwise load the existing ID and created-at. Either
+  // way, evict stale cache entries.
+  if id, ok := info.UpsertedId.(bson.ObjectId); ok {
+    this.ID = id
+    this.Version = 1
+    if cacheEnabled(ServerColName) {
+      go invalidateCacheServer(net.RedisGetClient(ServerClientName), this.cacheKeys())
+    }
+    return nil
+  }
+  existing := new(Server)
+  if err := col.Find(selector).Select(bson.M{"_id": 1, "created_at": 1, "version": 1}).One(existing); err != nil {
+    return wrapDBError("Server", "Upsert", "", "", err)
+  }
+  this.ID = existing.ID
+  this.CreatedAt = existing.CreatedAt
+  this.Version = existing.Version
+  if cacheEnabled(ServerColName) {
+    go invalidateCacheServer(net.RedisGetClient(ServerClientName), this.cacheKeys())
+  }
+  return nil
 }
 
 // Delete permanently removes the document from the database.
-func (this *Server) Delete() error {
+func (this *Server) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "Server.Delete", ServerDBName, ServerColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Server", "Delete", time.Now(), &err)
+  defer logSlowQuery("Server", "Delete", bson.M{"_id": this.ID})()
+
+  if err := this.runHooks(HookBeforeDelete); err != nil {
+    return err
+  }
 
-  // Delete the Link.
-  return net.MgoCol(ServerClientName, ServerDBName, ServerColName).RemoveId(this.ID)
+  // Delete the Server.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return serverStore(ctx).Remove(this.ID)
+  }, retry.MaxAttempts, retry.BaseDelay)
+  if err != nil {
+    return wrapDBError("Server", "Delete", "_id", this.ID.Hex(), err)
+  }
+
+  // Evict stale cache entries.
+  if cacheEnabled(ServerColName) {
+    go invalidateCacheServer(net.RedisGetClient(ServerClientName), this.cacheKeys())
+  }
+  return this.runHooks(HookAfterDelete)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *Server) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *Server) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *Server) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("Server", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("Server", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheServer(net.RedisGetClient(ServerClientName), this.cacheKeys())
+  return nil
 }
 
 // Validate runs validations against the model's fields.
-func (this *Server) Validate() error {
+func (this *Server) Validate(ctx context.Context) error {
 
   // Implement validation rules here.
-  return validation.NewValidator().Struct(this)
+  return wrapValidationError("Server", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *Server) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("Server", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("Server", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *Server) Clone() *Server {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*Server)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *Server) Diff(other *Server) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *Server) DiffDeep(other *Server) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "discord_id".
+func (this *Server) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *Server) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *Server) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *Server) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *Server) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *Server) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the Server is uninitialized, having neither an ID nor a DiscordID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *Server) IsZero() bool {
+  return this.ID == "" && this.DiscordID == ""
+}
+
+// Age returns how long ago the Server was created.
+func (this *Server) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the Server was last updated.
+func (this *Server) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the Server hasn't been updated for longer than the threshold.
+func (this *Server) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
 }
 
 // CacheGetServer attempts to find a Server by the key and value specified in cache before looking
 // in the database and setting cache if found. If "negCache" is true, will check for neg-cache
 // first, and also set neg-cache if the document wasn't found in the database either.
-func CacheGetServer(key, value string, negCache bool) (*Server, error) {
+func CacheGetServer(key, value string, negCache bool) (found *Server, err error) {
+
+  // Skip the cache while a store is injected.
+  filter := notDeleted(bson.M{key: bsonQueryValue(key, value)})
+  if !cacheEnabled(ServerColName) {
+    server := new(Server)
+    if err := ServerStore().FindOne(filter, server); err != nil {
+      return nil, wrapDBError("Server", "CacheGet", key, value, err)
+    }
+    return server, nil
+  }
 
   client := net.RedisGetClient(ServerClientName)
   cacheKey := ServerClientName+":"+ServerDBName+":"+ServerColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "Server.CacheGet", ServerDBName, ServerColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Server", "CacheGet", time.Now(), &err)
 
   // Return not-found early if neg-cache exists.
   if negCache {
-    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil {
-      return nil, err
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("Server", "CacheGet", key, value, err)
     } else if result != "" {
-      return nil, mgo.ErrNotFound
+      recordCacheResult("Server", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "Server", Key: key, Value: value}
     }
   }
 
   // Return what's in cache if it's found.
-  if result, err := client.Get(cacheKey).Result(); err != nil {
-    return nil, err
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Server", "CacheGet", key, value, err)
   } else if result != "" {
+    recordCacheResult("Server", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().cacheTTL(getConfig().ServerCacheTTL))
     server := new(Server)
-    err = json.Unmarshal([]byte(result), server)
-    return server, err
+    if err := json.Unmarshal([]byte(result), server); err != nil {
+      return nil, wrapDBError("Server", "CacheGet", key, value, err)
+    }
+    return server, nil
   }
 
   // Get what's in the database.
+  recordCacheResult("Server", key, "miss")
   server := new(Server)
-  err := net.MgoCol(ServerClientName, ServerDBName, ServerColName).Find(bson.M{
-    key: value,
-  }).One(server)
+  err = ServerStore().FindOne(filter, server)
 
   // If it wasn't found and negCache is true, fill neg cache.
   if err == mgo.ErrNotFound && negCache {
     go fillNegCacheServer(client, cacheKey)
 
   // Else if there's no error, fill cache.
-  } else if err != nil {
+  } else if err == nil {
     go fillCacheServer(client, cacheKey, server)
   }
-  return server, err
+  if err != nil {
+    return nil, wrapDBError("Server", "CacheGet", key, value, err)
+  }
+  return server, nil
+}
+
+// CacheGetServersByIDs is the cache-backed form of FindServersByIDs. Cache is checked for every ID in a
+// single pipelined round-trip, and all misses are then fetched from the database in a single query and
+// cached. Results are in the order of the IDs, skipping IDs without a Server.
+func CacheGetServersByIDs(ctx context.Context, ids []bson.ObjectId) (_ []*Server, err error) {
+
+  ctx, span := startSpan(ctx, "Server.CacheGetByIDs", ServerDBName, ServerColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Server", "CacheGetByIDs", time.Now(), &err)
+
+  if len(ids) == 0 {
+    return []*Server{}, nil
+  }
+
+  // Queue up a Get for every ID's cache key.
+  client := net.RedisGetClient(ServerClientName)
+  prefix := ServerClientName+":"+ServerDBName+":"+ServerColName+":_id:"
+  pipe := client.Pipeline()
+  gets := make([]*redis.StringCmd, len(ids))
+  for i, id := range ids {
+    gets[i] = pipe.Get(prefix+id.Hex())
+  }
+  if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Server", "CacheGetByIDs", "", "", err)
+  }
+
+  // Take what's in cache, collecting the rest as misses.
+  found := []*Server{}
+  misses := []bson.ObjectId{}
+  for i, id := range ids {
+    result, err := gets[i].Result()
+    if err != nil && err != redis.Nil {
+      return nil, wrapDBError("Server", "CacheGetByIDs", "", "", err)
+    } else if result != "" {
+      recordCacheResult("Server", "_id", "hit")
+      go refreshCacheTTL(client, prefix+id.Hex(), getConfig().cacheTTL(getConfig().ServerCacheTTL))
+      server := new(Server)
+      if err := json.Unmarshal([]byte(result), server); err != nil {
+        return nil, wrapDBError("Server", "CacheGetByIDs", "", "", err)
+      }
+      found = append(found, server)
+      continue
+    }
+    recordCacheResult("Server", "_id", "miss")
+    misses = append(misses, id)
+  }
+
+  // Get all the misses from the database in one query, and cache them.
+  if len(misses) > 0 {
+    fetched := []*Server{}
+    err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+      return col.Find(notDeleted(bson.M{"_id": bson.M{"$in": misses}})).All(&fetched)
+    })
+    if err != nil {
+      return nil, wrapDBError("Server", "CacheGetByIDs", "", "", err)
+    }
+    for _, server := range fetched {
+      go fillCacheServer(client, prefix+server.ID.Hex(), server)
+    }
+    found = append(found, fetched...)
+  }
+  return orderServersByIDs(ids, found), nil
 }
 
 func fillCacheServer(client *redis.Client, key string, value *Server) {
+  _, span := startCacheSpan(context.Background(), "Server.fillCache", ServerDBName, ServerColName, key)
   serialized, err := json.Marshal(value)
   if err != nil {
     log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for Server")
+    endSpan(span, err)
+    return
   }
-  if err := client.Set(key, string(serialized), CacheTTL).Err(); err != nil {
+  cfg := getConfig()
+  err = client.Set(key, string(serialized), cfg.cacheTTL(cfg.ServerCacheTTL)).Err()
+  if err != nil {
     log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for Server")
   }
+  endSpan(span, err)
 }
 
 func fillNegCacheServer(client *redis.Client, key string) {
-  if err := client.Set("neg:"+key, "neg", NegCacheTTL).Err(); err != nil {
+  _, span := startCacheSpan(context.Background(), "Server.fillNegCache", ServerDBName, ServerColName, "neg:"+key)
+  cfg := getConfig()
+  err := client.Set("neg:"+key, "neg", cfg.negCacheTTL(cfg.ServerNegCacheTTL)).Err()
+  if err != nil {
     log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for Server")
   }
+  endSpan(span, err)
+}
+
+func invalidateCacheServer(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for Server")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetServer.
+func (this *Server) cacheKeys() []string {
+  prefix := ServerClientName+":"+ServerDBName+":"+ServerColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+    prefix+"discord_id:"+this.DiscordID,
+  }
+}
+
+// Hook functions.
+
+// serverHooks are the hooks registered with RegisterServerHook, by event, in registration order.
+var serverHooks = map[string][]func(*Server) error{}
+var serverHooksMutex sync.RWMutex
+
+// RegisterServerHook registers fn to run on every Server at the event, one of the Hook* events. Hooks
+// for the same event run in registration order, stopping at the first error. An error from a before_*
+// hook aborts the operation, and one from an after_* hook is returned after the operation succeeded.
+func RegisterServerHook(event string, fn func(*Server) error) error {
+
+  if !hookEvents[event] {
+    return fmt.Errorf("can't register Server hook for unknown event %q", event)
+  }
+  serverHooksMutex.Lock()
+  defer serverHooksMutex.Unlock()
+  serverHooks[event] = append(serverHooks[event], fn)
+  return nil
+}
+
+// runHooks runs the hooks registered for the event on the document, stopping at the first error.
+func (this *Server) runHooks(event string) error {
+
+  serverHooksMutex.RLock()
+  hooks := serverHooks[event]
+  serverHooksMutex.RUnlock()
+  for _, hook := range hooks {
+    if err := hook(this); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Change stream functions.
+
+// ServerChangeEvent describes a change to a Server seen by WatchServer.
+type ServerChangeEvent struct {
+  // OperationType is "insert", "update", or "delete".
+  OperationType     string        `bson:"operationType"`
+  DocumentKey       bson.ObjectId `bson:"-"`
+  // FullDocument is the Server as of the change, for inserts and updates.
+  FullDocument      *Server       `bson:"fullDocument"`
+  // UpdateDescription lists the updated and removed fields, for updates.
+  UpdateDescription bson.M        `bson:"updateDescription"`
+}
+
+// WatchServer calls handler with every insert, update, and delete on the servers collection until ctx
+// is done, when it returns nil. Handlers are called one at a time, in order. The stream's position is
+// persisted to Redis, so a restarted watcher continues from where the last one left off, and the
+// stream is reopened if it fails.
+func WatchServer(ctx context.Context, handler func(*ServerChangeEvent)) error {
+
+  pipeline := []bson.M{
+    {"$match": bson.M{"operationType": bson.M{"$in": []string{"insert", "update", "delete"}}}},
+  }
+  return watchCollection(ctx, nil, "Server", ServerClientName, ServerDBName, ServerColName, pipeline, func(raw bson.Raw) error {
+    event := new(ServerChangeEvent)
+    if err := raw.Unmarshal(event); err != nil {
+      return err
+    }
+    key := struct {
+      DocumentKey struct {
+        ID bson.ObjectId `bson:"_id"`
+      } `bson:"documentKey"`
+    }{}
+    if err := raw.Unmarshal(&key); err != nil {
+      return err
+    }
+    event.DocumentKey = key.DocumentKey.ID
+    handler(event)
+    return nil
+  })
+}
+
+// Misc functions.
+
+// AggregateServers runs the aggregation pipeline on the Server collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServers(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Server", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerClientName, ServerDBName, ServerColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Server", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateServerOne runs the aggregation pipeline on the Server collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServerOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Server", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerClientName, ServerDBName, ServerColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Server", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyServer atomically applies the update to the first Server matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyServer(selector, update bson.M, returnNew bool) (_ *Server, err error) {
+
+  defer observeOperation("Server", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(Server)
+  _, err = ServerCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("Server", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheServer(net.RedisGetClient(ServerClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindServersByIDs finds the Servers with the IDs, excluding soft-deleted ones, in the order of the IDs.
+// IDs without a Server are skipped. This does not touch the cache; see CacheGetServersByIDs for that.
+func FindServersByIDs(ctx context.Context, ids []bson.ObjectId) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "FindByIDs", time.Now(), &err)
+
+  found := []*Server{}
+  if len(ids) > 0 {
+    err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+      return col.Find(notDeleted(bson.M{"_id": bson.M{"$in": ids}})).All(&found)
+    })
+    if err != nil {
+      return nil, wrapDBError("Server", "FindByIDs", "", "", err)
+    }
+  }
+  return orderServersByIDs(ids, found), nil
+}
+
+// orderServersByIDs orders the Servers by the IDs, skipping IDs without a Server.
+func orderServersByIDs(ids []bson.ObjectId, servers []*Server) []*Server {
+
+  byID := make(map[bson.ObjectId]*Server, len(servers))
+  for _, server := range servers {
+    byID[server.ID] = server
+  }
+  results := make([]*Server, 0, len(ids))
+  for _, id := range ids {
+    if server, ok := byID[id]; ok {
+      results = append(results, server)
+    }
+  }
+  return results
+}
+
+// FindServerByDiscordID finds the Server with the Discord ID, excluding soft-deleted ones. Returns a
+// ModelNotFoundError if there is none. This does not touch the cache; see CacheGetServer for that.
+func FindServerByDiscordID(ctx context.Context, discordID string) (_ *Server, err error) {
+
+  defer observeOperation("Server", "FindOne", time.Now(), &err)
+
+  found := new(Server)
+  err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(bson.M{"discord_id": discordID})).One(found)
+  })
+  if err != nil {
+    return nil, wrapDBError("Server", "FindOne", "discord_id", discordID, err)
+  }
+  return found, nil
+}
+
+// FindServers finds all Servers matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindServers(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "Find", time.Now(), &err)
+  defer logSlowQuery("Server", "Find", filter)()
+
+  results := []*Server{}
+  if err := storeFind(serverStore(ctx, opts...), notDeleted(filter), sort, limit, &results); err != nil {
+    return nil, wrapDBError("Server", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindServersWithDeleted finds all Servers matching the filter, including soft-deleted ones.
+func FindServersWithDeleted(filter bson.M) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "Find", time.Now(), &err)
+
+  results := []*Server{}
+  if err := net.MgoCol(ServerClientName, ServerDBName, ServerColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("Server", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// RecentlyUpdatedServers finds the most recently updated Servers, newest first, excluding soft-deleted
+// ones. A limit of 0 means no limit. This does not touch the cache, since recency changes with every
+// update.
+func RecentlyUpdatedServers(ctx context.Context, limit int) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "RecentlyUpdated", time.Now(), &err)
+
+  results := []*Server{}
+  err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(bson.M{})).Sort("-updated_at").Limit(limit).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Server", "RecentlyUpdated", "", "", err)
+  }
+  return results, nil
 }
 
-// Misc functions.
\ No newline at end of file
+// FindOrCreateServer finds the Server with the given DiscordID, creating it if it doesn't exist yet. The
+// returned bool is true if the document was freshly inserted. Creation is an upsert with $setOnInsert,
+// backed by the unique index on discord_id, so concurrent callers can't insert duplicates. A
+// soft-deleted Server is returned as-is.
+func FindOrCreateServer(discordID string) (_ *Server, _ bool, err error) {
+
+  defer observeOperation("Server", "FindOrCreate", time.Now(), &err)
+
+  col := net.MgoCol(ServerClientName, ServerDBName, ServerColName)
+  selector := bson.M{"discord_id": discordID}
+
+  // Return the existing document if there is one.
+  existing := new(Server)
+  if err := col.Find(selector).One(existing); err == nil {
+    return existing, false, nil
+  } else if err != mgo.ErrNotFound {
+    return nil, false, wrapDBError("Server", "FindOrCreate", "", "", err)
+  }
+
+  // Build the new document the same way Create would.
+  now := clockNow()
+  doc := &Server{
+    ID:           bson.NewObjectId(),
+    DiscordID:    discordID,
+    CreatedAt:    now,
+    UpdatedAt:    now,
+    Version:      1,
+    ModelVersion: ServerModelVersion,
+  }
+  if err := doc.Validate(context.Background()); err != nil {
+    return nil, false, err
+  }
+
+  // Insert it only if nobody else did in the meantime.
+  fields, err := bsonFields(doc)
+  if err != nil {
+    return nil, false, wrapDBError("Server", "FindOrCreate", "", "", err)
+  }
+  result := new(Server)
+  info, err := col.Find(selector).Apply(mgo.Change{
+    Update:    bson.M{"$setOnInsert": fields},
+    Upsert:    true,
+    ReturnNew: true,
+  }, result)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. The
+  // losers find the winner's document.
+  if mgo.IsDup(err) {
+    if err := col.Find(selector).One(result); err != nil {
+      return nil, false, wrapDBError("Server", "FindOrCreate", "", "", err)
+    }
+    return result, false, nil
+  }
+  if err != nil {
+    return nil, false, wrapDBError("Server", "FindOrCreate", "", "", err)
+  }
+  return result, info.UpsertedId != nil, nil
+}
+
+// CountServers counts the Servers matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountServers(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("Server", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ServerClientName, ServerDBName, ServerColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("Server", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ServerExists reports whether any Server matches the filter, excluding soft-deleted ones.
+func ServerExists(filter bson.M) (bool, error) {
+
+  count, err := CountServers(filter)
+  return count > 0, err
+}
+
+// DistinctServerFields finds the distinct values of the field among the Servers matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctServerFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("Server", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ServerClientName, ServerDBName, ServerColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("Server", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctServerStringFields is DistinctServerFields for string fields, like discord_id. It fails if
+// any of the values isn't a string.
+func DistinctServerStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctServerFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactServerCollection runs the compact command against the Server collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactServerCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "Server", ServerClientName, ServerDBName, ServerColName)
+}
+
+// ServerCollectionStats returns the Server collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ServerCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "Server", ServerClientName, ServerDBName, ServerColName)
+}
+
+// ExplainServerQuery returns MongoDB's explain output for finding the filter in the Server collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainServerQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "Server", ServerClientName, ServerDBName, ServerColName, filter)
+}
+
+// UpdateManyServers applies the updates to every Server matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateServers for that. The Server cache is flushed.
+func UpdateManyServers(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "Server", ServerClientName, ServerDBName, ServerColName, filter, updates)
+}
+
+// BulkUpdateServers is UpdateManyServers, but validates each Server as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateServers(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "Server", ServerClientName, ServerDBName, ServerColName, filter, updates, func() validatable { return new(Server) })
+}
+
+// DeleteManyServers soft-deletes every Server matching the filter in a single query, returning how many
+// were deleted. The Server cache is evicted.
+func DeleteManyServers(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "Server", ServerClientName, ServerDBName, ServerColName, filter)
+}
\ No newline at end of file
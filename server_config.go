@@ -0,0 +1,690 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// ServerConfigClientName is the name of the MgoDriver to use for ServerConfig.
+const ServerConfigClientName = "main"
+
+// ServerConfigDBName is the name of the database to use for ServerConfig.
+const ServerConfigDBName = "badpetbot"
+
+// ServerConfigColName is the name of the collection to use for ServerConfig.
+const ServerConfigColName = "server_configs"
+
+// ServerConfigModelVersion is the current schema version of ServerConfig documents, stored in their ModelVersion.
+const ServerConfigModelVersion = 1
+
+// DefaultServerConfigPrefix is the command prefix a ServerConfig gets if none is set.
+const DefaultServerConfigPrefix = "!"
+
+// ServerConfigCol gets a collection reference for ServerConfig.
+func ServerConfigCol() *mgo.Collection {
+  return net.MgoCol(ServerConfigClientName, ServerConfigDBName, ServerConfigColName)
+}
+
+// Register ServerConfig, so tooling can find it.
+func init() {
+  RegisterModel("ServerConfig", ServerConfigCol, func() interface{} { return new(ServerConfig) })
+}
+
+// EnsureServerConfigIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureServerConfigIndices() error {
+
+  col := ServerConfigCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"server_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("ServerConfig", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { server_id: 1 } unique
+
+// ServerConfig holds a Server's bot settings. Each Server has at most one.
+type ServerConfig struct {
+  // ID is a BSON ID generated in Create.
+  ID                   bson.ObjectId   `bson:"_id"                      json:"_id"                      validate:"required"`
+  ServerID             bson.ObjectId   `bson:"server_id"                json:"server_id"                validate:"required"`
+  Prefix               string          `bson:"prefix"                   json:"prefix"                   validate:"required"`
+  EnabledModules       []string        `bson:"enabled_modules"          json:"enabled_modules"          validate:"-"`
+  ModRoleID            *string         `bson:"mod_role_id"              json:"mod_role_id"              validate:"omitempty,discord_id"`
+  OwnerRoleID          *string         `bson:"owner_role_id"            json:"owner_role_id"            validate:"omitempty,discord_id"`
+  LogChannelID         *string         `bson:"log_channel_id"           json:"log_channel_id"           validate:"omitempty,discord_id"`
+  // MaxWarningsBeforeBan is the total warning weight at which a member is banned. 0 disables it.
+  MaxWarningsBeforeBan int             `bson:"max_warnings_before_ban"  json:"max_warnings_before_ban"  validate:"gte=0"`
+  CreatedAt            time.Time       `bson:"created_at"               json:"created_at"               validate:"required"`
+  UpdatedAt            time.Time       `bson:"updated_at"               json:"updated_at"               validate:"required"`
+  DeletedAt            *time.Time      `bson:"deleted_at"               json:"deleted_at"               validate:"-"`
+  Version              int             `bson:"version"                  json:"version"                  validate:"-"`
+  ModelVersion         int             `bson:"model_version"            json:"model_version"            validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *ServerConfig) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerConfig.Create", ServerConfigDBName, ServerConfigColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerConfig", "Create", time.Now(), &err)
+  defer logSlowQuery("ServerConfig", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ServerConfigModelVersion
+
+  // Ensure defaults.
+  if this.Prefix == "" {
+    this.Prefix = DefaultServerConfigPrefix
+  }
+  if this.EnabledModules == nil {
+    this.EnabledModules = []string{}
+  }
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the ServerConfig.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("ServerConfig", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *ServerConfig) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *ServerConfig) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *ServerConfig) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerConfig.Update", ServerConfigDBName, ServerConfigColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerConfig", "Update", time.Now(), &err)
+  defer logSlowQuery("ServerConfig", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheServerConfig(net.RedisGetClient(ServerConfigClientName), this.cacheKeys())
+  }
+  return wrapDBError("ServerConfig", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *ServerConfig) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerConfig.Delete", ServerConfigDBName, ServerConfigColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerConfig", "Delete", time.Now(), &err)
+  defer logSlowQuery("ServerConfig", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the ServerConfig.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheServerConfig(net.RedisGetClient(ServerConfigClientName), this.cacheKeys())
+  }
+  return wrapDBError("ServerConfig", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *ServerConfig) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *ServerConfig) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *ServerConfig) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("ServerConfig", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("ServerConfig", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheServerConfig(net.RedisGetClient(ServerConfigClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *ServerConfig) Validate(ctx context.Context) error {
+
+  return wrapValidationError("ServerConfig", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *ServerConfig) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("ServerConfig", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("ServerConfig", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *ServerConfig) Clone() *ServerConfig {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*ServerConfig)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *ServerConfig) Diff(other *ServerConfig) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *ServerConfig) DiffDeep(other *ServerConfig) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, using dotted keys
+// for slice indices, e.g. "enabled_modules.0".
+func (this *ServerConfig) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *ServerConfig) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *ServerConfig) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *ServerConfig) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *ServerConfig) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *ServerConfig) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the ServerConfig is uninitialized, having neither an ID nor a ServerID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *ServerConfig) IsZero() bool {
+  return this.ID == "" && this.ServerID == ""
+}
+
+// Age returns how long ago the ServerConfig was created.
+func (this *ServerConfig) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the ServerConfig was last updated.
+func (this *ServerConfig) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the ServerConfig hasn't been updated for longer than the threshold.
+func (this *ServerConfig) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetServerConfig attempts to find a ServerConfig by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetServerConfig(key, value string, negCache bool) (found *ServerConfig, err error) {
+
+  client := net.RedisGetClient(ServerConfigClientName)
+  cacheKey := ServerConfigClientName+":"+ServerConfigDBName+":"+ServerConfigColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "ServerConfig.CacheGet", ServerConfigDBName, ServerConfigColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerConfig", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ServerConfig", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("ServerConfig", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ServerConfig", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerConfig", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("ServerConfig", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    serverConfig := new(ServerConfig)
+    if err := json.Unmarshal([]byte(result), serverConfig); err != nil {
+      return nil, wrapDBError("ServerConfig", "CacheGet", key, value, err)
+    }
+    return serverConfig, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("ServerConfig", key, "miss")
+  serverConfig := new(ServerConfig)
+  err = net.MgoCol(ServerConfigClientName, ServerConfigDBName, ServerConfigColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(serverConfig)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheServerConfig(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheServerConfig(client, cacheKey, serverConfig)
+  }
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "CacheGet", key, value, err)
+  }
+  return serverConfig, nil
+}
+
+func fillCacheServerConfig(client *redis.Client, key string, value *ServerConfig) {
+  _, span := startCacheSpan(context.Background(), "ServerConfig.fillCache", ServerConfigDBName, ServerConfigColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ServerConfig")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ServerConfig")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheServerConfig(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "ServerConfig.fillNegCache", ServerConfigDBName, ServerConfigColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ServerConfig")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheServerConfig(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for ServerConfig")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetServerConfig.
+func (this *ServerConfig) cacheKeys() []string {
+  prefix := ServerConfigClientName+":"+ServerConfigDBName+":"+ServerConfigColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+    prefix+"server_id:"+this.ServerID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateServerConfigs runs the aggregation pipeline on the ServerConfig collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServerConfigs(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ServerConfig", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerConfigClientName, ServerConfigDBName, ServerConfigColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ServerConfig", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateServerConfigOne runs the aggregation pipeline on the ServerConfig collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServerConfigOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ServerConfig", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerConfigClientName, ServerConfigDBName, ServerConfigColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ServerConfig", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyServerConfig atomically applies the update to the first ServerConfig matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyServerConfig(selector, update bson.M, returnNew bool) (_ *ServerConfig, err error) {
+
+  defer observeOperation("ServerConfig", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(ServerConfig)
+  _, err = ServerConfigCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheServerConfig(net.RedisGetClient(ServerConfigClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindServerConfigs finds all ServerConfigs matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindServerConfigs(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*ServerConfig, err error) {
+
+  defer observeOperation("ServerConfig", "Find", time.Now(), &err)
+  defer logSlowQuery("ServerConfig", "Find", filter)()
+
+  results := []*ServerConfig{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindServerConfigsWithDeleted finds all ServerConfigs matching the filter, including soft-deleted ones.
+func FindServerConfigsWithDeleted(filter bson.M) (_ []*ServerConfig, err error) {
+
+  defer observeOperation("ServerConfig", "Find", time.Now(), &err)
+
+  results := []*ServerConfig{}
+  if err := net.MgoCol(ServerConfigClientName, ServerConfigDBName, ServerConfigColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("ServerConfig", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountServerConfigs counts the ServerConfigs matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountServerConfigs(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("ServerConfig", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ServerConfigClientName, ServerConfigDBName, ServerConfigColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("ServerConfig", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ServerConfigExists reports whether any ServerConfig matches the filter, excluding soft-deleted ones.
+func ServerConfigExists(filter bson.M) (bool, error) {
+
+  count, err := CountServerConfigs(filter)
+  return count > 0, err
+}
+
+// DistinctServerConfigFields finds the distinct values of the field among the ServerConfigs matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctServerConfigFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("ServerConfig", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ServerConfigClientName, ServerConfigDBName, ServerConfigColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctServerConfigStringFields is DistinctServerConfigFields for string fields, like prefix. It fails if
+// any of the values isn't a string.
+func DistinctServerConfigStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctServerConfigFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactServerConfigCollection runs the compact command against the ServerConfig collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactServerConfigCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName)
+}
+
+// ServerConfigCollectionStats returns the ServerConfig collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ServerConfigCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName)
+}
+
+// ExplainServerConfigQuery returns MongoDB's explain output for finding the filter in the ServerConfig collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainServerConfigQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName, filter)
+}
+
+// UpdateManyServerConfigs applies the updates to every ServerConfig matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateServerConfigs for that. The ServerConfig cache is flushed.
+func UpdateManyServerConfigs(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName, filter, updates)
+}
+
+// BulkUpdateServerConfigs is UpdateManyServerConfigs, but validates each ServerConfig as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateServerConfigs(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName, filter, updates, func() validatable { return new(ServerConfig) })
+}
+
+// DeleteManyServerConfigs soft-deletes every ServerConfig matching the filter in a single query, returning how many
+// were deleted. The ServerConfig cache is evicted.
+func DeleteManyServerConfigs(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "ServerConfig", ServerConfigClientName, ServerConfigDBName, ServerConfigColName, filter)
+}
+
+// FindOrCreateServerConfig returns the Server's config, creating a default one first if it has none.
+// Concurrent callers for the same Server all get the same document.
+func FindOrCreateServerConfig(serverID bson.ObjectId) (_ *ServerConfig, err error) {
+
+  defer observeOperation("ServerConfig", "FindOrCreate", time.Now(), &err)
+
+  col := ServerConfigCol()
+  selector := bson.M{"server_id": serverID}
+
+  // Return the existing document if there is one.
+  existing := new(ServerConfig)
+  if err := col.Find(selector).One(existing); err == nil {
+    return existing, nil
+  } else if err != mgo.ErrNotFound {
+    return nil, wrapDBError("ServerConfig", "FindOrCreate", "server_id", serverID.Hex(), err)
+  }
+
+  // Build the default document the same way Create would.
+  now := clockNow()
+  doc := &ServerConfig{
+    ID:             bson.NewObjectId(),
+    ServerID:       serverID,
+    Prefix:         DefaultServerConfigPrefix,
+    EnabledModules: []string{},
+    CreatedAt:      now,
+    UpdatedAt:      now,
+    Version:        1,
+    ModelVersion:   ServerConfigModelVersion,
+  }
+  if err := doc.Validate(context.Background()); err != nil {
+    return nil, err
+  }
+
+  // Insert it only if nobody else did in the meantime.
+  fields, err := bsonFields(doc)
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "FindOrCreate", "server_id", serverID.Hex(), err)
+  }
+  result := new(ServerConfig)
+  _, err = col.Find(selector).Apply(mgo.Change{
+    Update:    bson.M{"$setOnInsert": fields},
+    Upsert:    true,
+    ReturnNew: true,
+  }, result)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. The
+  // losers find the winner's document.
+  if mgo.IsDup(err) {
+    err = col.Find(selector).One(result)
+  }
+  if err != nil {
+    return nil, wrapDBError("ServerConfig", "FindOrCreate", "server_id", serverID.Hex(), err)
+  }
+  return result, nil
+}
@@ -3,7 +3,13 @@ package gomodel
 import (
 
   // Import builtin packages.
+  "context"
   "encoding/json"
+  "errors"
+  "fmt"
+  "reflect"
+  "sort"
+  "sync"
   "time"
 
   // Import 3rd party packages.
@@ -14,7 +20,6 @@ import (
 
   // Import internal packages.
   "github.com/badpetbot/gocommon/net"
-  "github.com/badpetbot/gocommon/validation"
 )
 
 // ServerMemberClientName is the name of the MgoDriver to use for ServerMember.
@@ -26,31 +31,69 @@ const ServerMemberDBName = "badpetbot"
 // ServerMemberColName is the name of the collection to use for ServerMember.
 const ServerMemberColName = "server_members"
 
+// ServerMemberModelVersion is the current schema version of ServerMember documents, stored in their ModelVersion.
+const ServerMemberModelVersion = 1
+
 // ServerMemberCol gets a collection reference for ServerMember.
 func ServerMemberCol() *mgo.Collection {
   return net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName)
 }
 
+// Register ServerMember, so tooling can find it.
+func init() {
+  RegisterModel("ServerMember", ServerMemberCol, func() interface{} { return new(ServerMember) })
+}
+
+// ServerMemberStore gets the store for ServerMember: the one injected with SetModelStore if there is one, or else
+// the database.
+func ServerMemberStore() ModelStore {
+  return modelStore(context.Background(), ServerMemberClientName, ServerMemberDBName, ServerMemberColName, nil)
+}
+
+// serverMemberStore is ServerMemberStore, with the database store running its operations with ctx and the options.
+func serverMemberStore(ctx context.Context, opts ...QueryOption) ModelStore {
+  return modelStore(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, opts)
+}
+
+// EnsureServerMemberIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureServerMemberIndices() error {
+
+  col := ServerMemberCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_user_id"}, Background: true}); err != nil {
+    return wrapDBError("ServerMember", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id"}, Background: true}); err != nil {
+    return wrapDBError("ServerMember", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_member_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("ServerMember", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
 // INDICES:
 // { _id: 1 }
 // { discord_user_id: 1 }
 // { discord_server_id: 1 }
-// { discord_member_id: 1 }
+// { discord_member_id: 1 } unique
 
 // ServerMember is a single Discord "guild member". ServerMembers can belong to the same Discord "user" account,
 // but for the purposes of BadPetBot, are considered separate users except for bans.
 type ServerMember struct {
   // ID is a BSON ID generated in Create.
   ID                  bson.ObjectId   `bson:"_id"                   json:"_id"                    validate:"required"`
-  DiscordUserID       string          `bson:"discord_user_id"       json:"discord_user_id"        validate:"required"`
-  DiscordServerID     string          `bson:"discord_server_id"     json:"discord_server_id"      validate:"required"`
-  DiscordMemberID     string          `bson:"discord_member_id"     json:"discord_member_id"      validate:"required"`
+  DiscordUserID       EncryptedString `bson:"discord_user_id"       json:"discord_user_id"        validate:"required,discord_id"`
+  DiscordServerID     string          `bson:"discord_server_id"     json:"discord_server_id"      validate:"required,discord_id"`
+  DiscordMemberID     string          `bson:"discord_member_id"     json:"discord_member_id"      validate:"required,discord_id"`
   CreatedAt           time.Time       `bson:"created_at"            json:"created_at"             validate:"required"`
   UpdatedAt           time.Time       `bson:"updated_at"            json:"updated_at"             validate:"required"`
+  DeletedAt           *time.Time      `bson:"deleted_at"            json:"deleted_at"             validate:"-"`
+  Version             int             `bson:"version"               json:"version"                validate:"-"`
+  ModelVersion        int             `bson:"model_version"         json:"model_version"          validate:"-"`
 
   // Ownership relationships
-  OwnerDiscordID      string          `bson:"owner_discord_id"      json:"owner_discord_id"       validate:"-"`
-  SecOwnerDiscordIDs  []string        `bson:"sec_owner_discord_ids" json:"sec_owner_discord_ids"  validate:"-"`
+  OwnerDiscordID      string          `bson:"owner_discord_id"      json:"owner_discord_id"       validate:"omitempty,discord_id"`
+  SecOwnerDiscordIDs  []string        `bson:"sec_owner_discord_ids" json:"sec_owner_discord_ids"  validate:"dive,discord_id"`
 
   // Embeddables
 
@@ -58,118 +101,1891 @@ type ServerMember struct {
   SecOwners           []ServerMember  `bson:"sec_owners,omitalways" json:"sec_owners"             validate:"-"`
 }
 
+// NewServerMemberWithDefaults builds a ServerMember for the Discord IDs which passes Validate as-is, with
+// no secondary owners. Its ID and timestamps are filled in too, but Create replaces them.
+func NewServerMemberWithDefaults(userID, serverID, memberID string) *ServerMember {
+
+  now := clockNow()
+  return &ServerMember{
+    ID:                 bson.NewObjectId(),
+    DiscordUserID:      EncryptedString(userID),
+    DiscordServerID:    serverID,
+    DiscordMemberID:    memberID,
+    CreatedAt:          now,
+    UpdatedAt:          now,
+    Version:            1,
+    ModelVersion:       ServerMemberModelVersion,
+    SecOwnerDiscordIDs: []string{},
+  }
+}
+
 // Create persists the document in the database. It can optionally run validations if present and
 // prevent model persistence if they do not pass.
-func (this *ServerMember) Create() error {
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *ServerMember) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerMember.Create", ServerMemberDBName, ServerMemberColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "Create", time.Now(), &err)
+  defer logSlowQuery("ServerMember", "Create", nil)()
 
   // Ensure ID, timestamps, and tokens.
   this.ID = bson.NewObjectId()
-  now := time.Now()
+  now := clockNow()
   this.CreatedAt = now
   this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = ServerMemberModelVersion
 
   // Ensure defaults.
 
   // Run validations and return if they fail.
-  if err := this.Validate(); err != nil {
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the ServerMember.
-  return net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName).Insert(this)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return serverMemberStore(ctx, opts...).Insert(this)
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict the stale member counts.
+  if err == nil && cacheEnabled(ServerMemberColName) {
+    bustGroupByServerCache()
+  }
+  return wrapDBError("ServerMember", "Create", "_id", this.ID.Hex(), err)
+}
+
+// CreateIdempotent is Create for requests which may be re-submitted, like retried event handlers. The
+// first call for an idempotency key reserves the key in Redis, creates the document, and remembers it
+// for Config.IdempotencyTTL. Later calls for the same key load the remembered document into the receiver
+// instead, without touching the database, or return ErrIdempotencyKeyInProgress while the first call is
+// still creating it. If creating fails, the key is released so the request can be retried.
+func (this *ServerMember) CreateIdempotent(ctx context.Context, idempotencyKey string) (err error) {
+
+  defer observeOperation("ServerMember", "CreateIdempotent", time.Now(), &err)
+
+  if idempotencyKey == "" {
+    return errors.New("can't create idempotently with an empty idempotency key")
+  }
+
+  // Reserve the key, so concurrent calls with it don't create the document too.
+  key := "idem:"+idempotencyKey
+  ttl := getConfig().IdempotencyTTL
+  client := net.RedisGetClient(ServerMemberClientName)
+  reserved, err := client.WithContext(ctx).SetNX(key, idempotencyReserved, ttl).Result()
+  if err != nil {
+    return wrapDBError("ServerMember", "CreateIdempotent", "idempotency_key", idempotencyKey, err)
+  }
+
+  // If someone else holds the key, load the document they created. The key can be released between
+  // reserving and loading it, which is reported the same as still being in progress.
+  if !reserved {
+    result, err := client.WithContext(ctx).Get(key).Result()
+    if err == redis.Nil || result == idempotencyReserved {
+      return ErrIdempotencyKeyInProgress
+    } else if err != nil {
+      return wrapDBError("ServerMember", "CreateIdempotent", "idempotency_key", idempotencyKey, err)
+    }
+    return wrapDBError("ServerMember", "CreateIdempotent", "idempotency_key", idempotencyKey, json.Unmarshal([]byte(result), this))
+  }
+
+  // Create the document, releasing the key if that fails. Releasing and remembering don't use ctx, since
+  // whether the document was created is already settled.
+  if err := this.Create(ctx); err != nil {
+    if err := client.Del(key).Err(); err != nil {
+      log.Warn().AnErr("createIdempotent", err).Msgf("Error releasing idempotency key for ServerMember")
+    }
+    return err
+  }
+
+  // The document exists by now, so failing to remember it is logged rather than returned. Until the key
+  // expires, later calls then get ErrIdempotencyKeyInProgress rather than creating a duplicate.
+  serialized, err := json.Marshal(this)
+  if err == nil {
+    err = client.Set(key, string(serialized), ttl).Err()
+  }
+  if err != nil {
+    log.Warn().AnErr("createIdempotent", err).Msgf("Error remembering idempotency key for ServerMember")
+  }
+  return nil
 }
 
+// idempotencyReserved is what CreateIdempotent stores under an idempotency key while creating its
+// document. Remembered documents are JSON objects, so they can't be mistaken for it.
+const idempotencyReserved = "reserved"
+
 // Update updates the document in the database. Important note, this function does NOT prepend
-// the provided updates with "$set" or any other operator.
-func (this *ServerMember) Update(updates bson.M) error {
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *ServerMember) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *ServerMember) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *ServerMember) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
 
-  // Update updated-at timestamp.
-  this.UpdatedAt = time.Now()
+  ctx, span := startSpan(ctx, "ServerMember.Update", ServerMemberDBName, ServerMemberColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "Update", time.Now(), &err)
+  defer logSlowQuery("ServerMember", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
   _, setting := updates["$set"]
   if !setting {
     updates["$set"] = bson.M{}
   }
   updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
 
-  if err := this.Validate(); err != nil {
+  if err := this.Validate(ctx); err != nil {
     return err
   }
 
   // Persist the updates.
-  return net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName).UpdateId(this.ID, updates)
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return storeUpdate(serverMemberStore(ctx, opts...), selector, updates)
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    if cacheEnabled(ServerMemberColName) {
+      go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+    }
+  }
+  return wrapDBError("ServerMember", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Upsert inserts the document if no ServerMember with the same DiscordMemberID exists, or updates the
+// existing one. ID and CreatedAt are only persisted on insert; UpdatedAt is always refreshed.
+func (this *ServerMember) Upsert() (err error) {
+
+  defer observeOperation("ServerMember", "Upsert", time.Now(), &err)
+
+  // Ensure ID and timestamps. The ID and created-at are only used if this turns out to be an insert.
+  now := clockNow()
+  if !this.ID.Valid() {
+    this.ID = bson.NewObjectId()
+  }
+  if this.CreatedAt.IsZero() {
+    this.CreatedAt = now
+  }
+  this.UpdatedAt = now
+  this.ModelVersion = ServerMemberModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(context.Background()); err != nil {
+    return err
+  }
+
+  // Everything but the ID and created-at is always set, and the version is incremented.
+  set, err := bsonFields(this, "_id", "created_at", "version")
+  if err != nil {
+    return wrapDBError("ServerMember", "Upsert", "", "", err)
+  }
+
+  // Persist the ServerMember, getting the stored document back.
+  store := serverMemberStore(context.Background())
+  selector := bson.M{"discord_member_id": this.DiscordMemberID}
+  change := mgo.Change{
+    Update:    bson.M{
+      "$setOnInsert": bson.M{"_id": this.ID, "created_at": this.CreatedAt},
+      "$set":         set,
+      "$inc":         bson.M{"version": 1},
+    },
+    Upsert:    true,
+    ReturnNew: true,
+  }
+  stored := new(ServerMember)
+  upserted, err := storeFindAndModify(store, selector, change, stored)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. Retrying
+  // updates the winner's document instead.
+  if mgo.IsDup(err) {
+    upserted, err = storeFindAndModify(store, selector, change, stored)
+  }
+  if err != nil {
+    return wrapDBError("ServerMember", "Upsert", "", "", err)
+  }
+
+  // Take the stored ID, created-at, and version, which are the existing document's unless it was a fresh
+  // insert. Either way, evict stale cache entries.
+  this.ID = stored.ID
+  this.CreatedAt = stored.CreatedAt
+  this.Version = stored.Version
+  if upserted {
+    bustGroupByServerCache()
+  }
+  if cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+  }
+  return nil
 }
 
 // Delete permanently removes the document from the database.
-func (this *ServerMember) Delete() error {
+func (this *ServerMember) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerMember.Delete", ServerMemberDBName, ServerMemberColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "Delete", time.Now(), &err)
+  defer logSlowQuery("ServerMember", "Delete", bson.M{"_id": this.ID})()
 
-  // Delete the Link.
-  return net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName).RemoveId(this.ID)
+  // Delete the ServerMember.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return serverMemberStore(ctx).Remove(this.ID)
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil && cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+    bustGroupByServerCache()
+  }
+  return wrapDBError("ServerMember", "Delete", "_id", this.ID.Hex(), err)
 }
 
-// Validate runs validations against the model's fields.
-func (this *ServerMember) Validate() error {
+// serverMemberCounterFields lists the numeric fields Increment is allowed to touch. Add counter fields
+// here as they're added to the model.
+var serverMemberCounterFields = map[string]bool{}
+
+// Increment atomically adds delta to the named numeric field without a read-modify-write cycle. Only
+// fields listed in serverMemberCounterFields can be incremented.
+func (this *ServerMember) Increment(field string, delta int) (err error) {
+
+  defer observeOperation("ServerMember", "Increment", time.Now(), &err)
+
+  if !serverMemberCounterFields[field] {
+    return fmt.Errorf("can't increment unknown ServerMember field %q", field)
+  }
+
+  // Persist the increment.
+  now := clockNow()
+  err = serverMemberStore(context.Background()).Update(this.ID, bson.M{
+    "$inc": bson.M{field: delta, "version": 1},
+    "$set": bson.M{"updated_at": now},
+  })
 
-  // Implement validation rules here.
-  return validation.NewValidator().Struct(this)
+  // Note the new timestamp and version, and evict stale cache entries.
+  if err == nil {
+    this.UpdatedAt = now
+    this.Version++
+    if cacheEnabled(ServerMemberColName) {
+      go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+    }
+  }
+  return wrapDBError("ServerMember", "Increment", "_id", this.ID.Hex(), err)
 }
 
-// CacheGetServerMember attempts to find a ServerMember by the key and value specified in cache before looking
-// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
-// first, and also set neg-cache if the document wasn't found in the database either.
-func CacheGetServerMember(key, value string, negCache bool) (*ServerMember, error) {
+// CompareAndSwap atomically sets the field to replacement, but only if it's currently expected, as in
+// setting owner_discord_id only while it's empty. It reports whether the swap happened; false means the
+// stored value had changed, and isn't an error. After a swap, the receiver is refreshed from the stored
+// document, leaving embeddables unloaded.
+func (this *ServerMember) CompareAndSwap(ctx context.Context, field string, expected, replacement interface{}) (swapped bool, err error) {
 
-  client := net.RedisGetClient(ServerMemberClientName)
-  cacheKey := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"+key+":"+value
+  defer observeOperation("ServerMember", "CompareAndSwap", time.Now(), &err)
 
-  // Return not-found early if neg-cache exists.
-  if negCache {
-    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil {
-      return nil, err
-    } else if result != "" {
-      return nil, mgo.ErrNotFound
+  if field == "" || field == "_id" {
+    return false, fmt.Errorf("can't compare and swap ServerMember field %q", field)
+  }
+
+  // Persist the swap if the field still has the expected value, getting the new document back.
+  result := new(ServerMember)
+  _, err = storeFindAndModify(serverMemberStore(ctx), bson.M{"_id": this.ID, field: expected}, mgo.Change{
+    Update:    bson.M{
+      "$set": bson.M{field: replacement, "updated_at": clockNow()},
+      "$inc": bson.M{"version": 1},
+    },
+    ReturnNew: true,
+  }, result)
+  if err == mgo.ErrNotFound {
+    return false, nil
+  } else if err != nil {
+    return false, wrapDBError("ServerMember", "CompareAndSwap", "_id", this.ID.Hex(), err)
+  }
+
+  // Refresh the receiver and evict stale cache entries, under both the old and new keys in case the
+  // field is one the document is cached by.
+  keys := this.cacheKeys()
+  *this = *result
+  if cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), append(keys, this.cacheKeys()...))
+  }
+  return true, nil
+}
+
+// AddSecOwner adds discordID to SecOwnerDiscordIDs if it isn't already there, using $addToSet.
+func (this *ServerMember) AddSecOwner(discordID string) error {
+  return this.modifySecOwners("AddSecOwner", "$addToSet", discordID)
+}
+
+// RemoveSecOwner removes every occurrence of discordID from SecOwnerDiscordIDs, using $pull.
+func (this *ServerMember) RemoveSecOwner(discordID string) error {
+  return this.modifySecOwners("RemoveSecOwner", "$pull", discordID)
+}
+
+// AppendSecOwner appends discordID to SecOwnerDiscordIDs without deduplicating, using $push.
+func (this *ServerMember) AppendSecOwner(discordID string) error {
+  return this.modifySecOwners("AppendSecOwner", "$push", discordID)
+}
+
+// HasSecOwner reports whether discordID is in the in-memory SecOwnerDiscordIDs. It does not touch the
+// database.
+func (this *ServerMember) HasSecOwner(discordID string) bool {
+
+  for _, id := range this.SecOwnerDiscordIDs {
+    if id == discordID {
+      return true
     }
   }
+  return false
+}
 
-  // Return what's in cache if it's found.
-  if result, err := client.Get(cacheKey).Result(); err != nil {
-    return nil, err
-  } else if result != "" {
-    server := new(ServerMember)
-    err = json.Unmarshal([]byte(result), server)
-    return server, err
+// modifySecOwners applies the array operator with discordID to SecOwnerDiscordIDs, then refreshes the
+// in-memory slice, timestamp, and version from the stored document.
+func (this *ServerMember) modifySecOwners(operation, operator, discordID string) (err error) {
+
+  defer observeOperation("ServerMember", operation, time.Now(), &err)
+
+  // Persist the change, getting the new document back.
+  now := clockNow()
+  result := new(ServerMember)
+  _, err = storeFindAndModify(serverMemberStore(context.Background()), bson.M{"_id": this.ID}, mgo.Change{
+    Update:    bson.M{
+      operator: bson.M{"sec_owner_discord_ids": discordID},
+      "$set":   bson.M{"updated_at": now},
+      "$inc":   bson.M{"version": 1},
+    },
+    ReturnNew: true,
+  }, result)
+
+  // Refresh the in-memory fields and evict stale cache entries.
+  if err == nil {
+    this.SecOwnerDiscordIDs = result.SecOwnerDiscordIDs
+    this.UpdatedAt = result.UpdatedAt
+    this.Version = result.Version
+    if cacheEnabled(ServerMemberColName) {
+      go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+    }
   }
+  return wrapDBError("ServerMember", operation, "_id", this.ID.Hex(), err)
+}
 
-  // Get what's in the database.
-  server := new(ServerMember)
-  err := net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName).Find(bson.M{
-    key: value,
-  }).One(server)
+// TransferOwnership makes newOwnerID the owner, keeping the previous owner, if there was one, as a
+// secondary owner. Both changes are persisted in a single update. The transfer is recorded in the audit
+// log, with the previous owner as the actor, or the new one if there was none. Returns ErrSameOwner if
+// newOwnerID is already the owner.
+func (this *ServerMember) TransferOwnership(ctx context.Context, newOwnerID string) error {
 
-  // If it wasn't found and negCache is true, fill neg cache.
-  if err == mgo.ErrNotFound && negCache {
-    go fillNegCacheServerMember(client, cacheKey)
+  if newOwnerID == "" {
+    return errors.New("can't transfer ownership to an empty owner ID")
+  }
+  if newOwnerID == this.OwnerDiscordID {
+    return ErrSameOwner
+  }
 
-  // Else if there's no error, fill cache.
-  } else if err != nil {
-    go fillCacheServerMember(client, cacheKey, server)
+  // Change the owner in memory first, so the new one is validated, and restore it if the update fails.
+  oldOwnerID, oldSecOwnerIDs := this.OwnerDiscordID, this.SecOwnerDiscordIDs
+  before := this.ownership()
+  updates := bson.M{"$set": bson.M{"owner_discord_id": newOwnerID}}
+  this.OwnerDiscordID = newOwnerID
+  if oldOwnerID != "" && !this.HasSecOwner(oldOwnerID) {
+    updates["$addToSet"] = bson.M{"sec_owner_discord_ids": oldOwnerID}
+    this.SecOwnerDiscordIDs = append(append([]string{}, oldSecOwnerIDs...), oldOwnerID)
+  }
+  if err := this.Update(ctx, updates); err != nil {
+    this.OwnerDiscordID, this.SecOwnerDiscordIDs = oldOwnerID, oldSecOwnerIDs
+    return err
+  }
+
+  actorID := oldOwnerID
+  if actorID == "" {
+    actorID = newOwnerID
   }
-  return server, err
+  this.recordOwnershipChange(actorID, before)
+  return nil
 }
 
-func fillCacheServerMember(client *redis.Client, key string, value *ServerMember) {
-  serialized, err := json.Marshal(value)
+// ReleaseOwnership clears the owner, leaving secondary owners as they are. The release is recorded in the
+// audit log, with the previous owner as the actor. It's a no-op if there is no owner.
+func (this *ServerMember) ReleaseOwnership(ctx context.Context) error {
+
+  if this.OwnerDiscordID == "" {
+    return nil
+  }
+
+  oldOwnerID := this.OwnerDiscordID
+  before := this.ownership()
+  this.OwnerDiscordID = ""
+  if err := this.Update(ctx, bson.M{"$unset": bson.M{"owner_discord_id": ""}}); err != nil {
+    this.OwnerDiscordID = oldOwnerID
+    return err
+  }
+  this.recordOwnershipChange(oldOwnerID, before)
+  return nil
+}
+
+// ReleaseAllOwnership clears the owner of every ServerMember owned by ownerID, returning how many were
+// released. Unlike ReleaseOwnership, the releases aren't recorded in the audit log.
+func ReleaseAllOwnership(ownerID string) (released int, err error) {
+
+  defer observeOperation("ServerMember", "ReleaseAllOwnership", time.Now(), &err)
+
+  if ownerID == "" {
+    return 0, errors.New("can't release ownership of an empty owner ID")
+  }
+
+  // Find the members first, so their cache entries can be evicted.
+  store := serverMemberStore(context.Background())
+  selector := bson.M{"owner_discord_id": ownerID}
+  members := []*ServerMember{}
+  if err = store.FindMany(selector, &members); err != nil {
+    return 0, wrapDBError("ServerMember", "ReleaseAllOwnership", "owner_discord_id", ownerID, err)
+  }
+  if len(members) == 0 {
+    return 0, nil
+  }
+  keys := []string{}
+  for _, member := range members {
+    keys = append(keys, member.cacheKeys()...)
+  }
+
+  // Release them and evict stale cache entries.
+  released, err = storeUpdateAll(store, selector, bson.M{
+    "$unset": bson.M{"owner_discord_id": ""},
+    "$set":   bson.M{"updated_at": clockNow()},
+    "$inc":   bson.M{"version": 1},
+  })
   if err != nil {
-    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ServerMember")
+    return 0, wrapDBError("ServerMember", "ReleaseAllOwnership", "owner_discord_id", ownerID, err)
   }
-  if err := client.Set(key, string(serialized), CacheTTL).Err(); err != nil {
-    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ServerMember")
+  if cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), keys)
   }
+  return released, nil
 }
 
-func fillNegCacheServerMember(client *redis.Client, key string) {
-  if err := client.Set("neg:"+key, "neg", NegCacheTTL).Err(); err != nil {
-    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ServerMember")
+// ownership returns the ownership fields as stored, for audit log entries.
+func (this *ServerMember) ownership() bson.M {
+
+  return bson.M{
+    "owner_discord_id":      this.OwnerDiscordID,
+    "sec_owner_discord_ids": append([]string{}, this.SecOwnerDiscordIDs...),
+  }
+}
+
+// recordOwnershipChange records a change to the ownership fields in the audit log. The change is
+// already persisted, so a failure to record it is only logged.
+func (this *ServerMember) recordOwnershipChange(actorID string, before bson.M) {
+
+  err := RecordAuditEntry(&AuditLogEntry{
+    ActorDiscordID:  actorID,
+    TargetModel:     "ServerMember",
+    TargetID:        this.ID,
+    Operation:       "update",
+    Before:          before,
+    After:           this.ownership(),
+    DiscordServerID: this.DiscordServerID,
+  })
+  if err != nil {
+    log.Warn().AnErr("recordAuditEntry", err).Msgf("Error recording ownership change of ServerMember %s", this.ID.Hex())
+  }
+}
+
+// serverMemberLookup describes how to populate one of ServerMember's embeddables with a $lookup stage.
+type serverMemberLookup struct {
+  // lookup is the $lookup stage's spec, without "as", which is always the field name.
+  lookup bson.M
+  // assign sets the embeddable from the looked up documents.
+  assign func(this *ServerMember, found []ServerMember)
+}
+
+// serverMemberLookups maps each of ServerMember's embeddables, by bson field name, to how it's
+// populated. Registering a new embeddable here makes it available to Populate.
+var serverMemberLookups = map[string]serverMemberLookup{
+  "owner": {
+    lookup: bson.M{
+      "from":     ServerMemberColName,
+      "let":      bson.M{"owner": "$owner_discord_id"},
+      "pipeline": []bson.M{
+        {"$match": bson.M{"$expr": bson.M{"$eq": []string{"$discord_member_id", "$$owner"}}, "deleted_at": nil}},
+        {"$limit": 1},
+      },
+    },
+    assign: func(this *ServerMember, found []ServerMember) {
+      this.Owner = nil
+      if len(found) > 0 {
+        this.Owner = &found[0]
+      }
+    },
+  },
+  "sec_owners": {
+    lookup: bson.M{
+      "from":     ServerMemberColName,
+      "let":      bson.M{"sec_owners": bson.M{"$ifNull": []interface{}{"$sec_owner_discord_ids", []string{}}}},
+      "pipeline": []bson.M{
+        {"$match": bson.M{"$expr": bson.M{"$in": []string{"$discord_member_id", "$$sec_owners"}}, "deleted_at": nil}},
+      },
+    },
+    assign: func(this *ServerMember, found []ServerMember) {
+      this.SecOwners = found
+    },
+  },
+}
+
+// EagerLoadOwner populates Owner with the ServerMember whose DiscordMemberID is OwnerDiscordID, in a
+// single aggregation. It's a no-op if OwnerDiscordID is empty, and leaves Owner nil if the owner
+// doesn't exist.
+func (this *ServerMember) EagerLoadOwner(ctx context.Context) error {
+
+  if this.OwnerDiscordID == "" {
+    return nil
+  }
+  return this.populate(ctx, "EagerLoadOwner", []string{"owner"})
+}
+
+// EagerLoadSecOwners populates SecOwners with the ServerMembers whose DiscordMemberIDs are in
+// SecOwnerDiscordIDs, in a single aggregation. It's a no-op if SecOwnerDiscordIDs is empty.
+func (this *ServerMember) EagerLoadSecOwners(ctx context.Context) error {
+
+  if len(this.SecOwnerDiscordIDs) == 0 {
+    return nil
+  }
+  return this.populate(ctx, "EagerLoadSecOwners", []string{"sec_owners"})
+}
+
+// Populate fills the named embeddables (e.g. "owner", "sec_owners") in a single aggregation. With no
+// fields, every embeddable in serverMemberLookups is populated.
+func (this *ServerMember) Populate(fields ...string) error {
+
+  if len(fields) == 0 {
+    for field := range serverMemberLookups {
+      fields = append(fields, field)
+    }
+    sort.Strings(fields)
+  }
+  return this.populate(context.Background(), "Populate", fields)
+}
+
+// populate runs one $lookup stage per field on this document alone, then assigns each field's results.
+func (this *ServerMember) populate(ctx context.Context, operation string, fields []string) (err error) {
+
+  defer observeOperation("ServerMember", operation, time.Now(), &err)
+
+  // Build the pipeline.
+  pipeline := []bson.M{{"$match": bson.M{"_id": this.ID}}}
+  project := bson.M{"_id": 0}
+  for _, field := range fields {
+    spec, ok := serverMemberLookups[field]
+    if !ok {
+      return fmt.Errorf("can't populate unknown ServerMember field %q", field)
+    }
+    lookup := bson.M{"as": field}
+    for key, value := range spec.lookup {
+      lookup[key] = value
+    }
+    pipeline = append(pipeline, bson.M{"$lookup": lookup})
+    project[field] = 1
+  }
+  pipeline = append(pipeline, bson.M{"$project": project})
+
+  // Run it and assign the results.
+  result := map[string][]ServerMember{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Pipe(pipeline).One(&result)
+  })
+  if err != nil {
+    return wrapDBError("ServerMember", operation, "_id", this.ID.Hex(), err)
+  }
+  for _, field := range fields {
+    serverMemberLookups[field].assign(this, result[field])
+  }
+  return nil
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *ServerMember) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  bustGroupByServerCache()
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *ServerMember) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  bustGroupByServerCache()
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *ServerMember) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("ServerMember", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = serverMemberStore(ctx).Update(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  if err != nil {
+    return wrapDBError("ServerMember", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  if cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), this.cacheKeys())
+  }
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *ServerMember) Validate(ctx context.Context) error {
+
+  // Run the before hooks, the tag validations, then the after hooks, stopping at the first failure.
+  if err := this.runValidateHooks(HookBeforeValidate); err != nil {
+    return err
+  }
+  if err := wrapValidationError("ServerMember", newValidator().StructCtx(ctx, this)); err != nil {
+    return err
+  }
+  return this.runValidateHooks(HookAfterValidate)
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *ServerMember) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("ServerMember", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("ServerMember", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *ServerMember) Clone() *ServerMember {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*ServerMember)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *ServerMember) Diff(other *ServerMember) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *ServerMember) DiffDeep(other *ServerMember) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, using dotted keys
+// for embedded documents and slice indices, e.g. "owner.discord_member_id" or "sec_owner_discord_ids.0".
+func (this *ServerMember) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *ServerMember) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *ServerMember) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *ServerMember) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
   }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *ServerMember) CSVHeaders() []string {
+
+  return csvHeaders(this)
 }
 
-// Misc functions.
\ No newline at end of file
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *ServerMember) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the ServerMember is uninitialized, having neither an ID nor a DiscordMemberID, e.g. after a
+// partially failed deserialization. Check for nil first: member == nil || member.IsZero().
+func (this *ServerMember) IsZero() bool {
+  return this.ID == "" && this.DiscordMemberID == ""
+}
+
+// Age returns how long ago the ServerMember was created.
+func (this *ServerMember) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the ServerMember was last updated.
+func (this *ServerMember) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the ServerMember hasn't been updated for longer than the threshold.
+func (this *ServerMember) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// CacheGetServerMember attempts to find a ServerMember by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetServerMember(key, value string, negCache bool) (found *ServerMember, err error) {
+
+  // Skip the cache while a store is injected.
+  filter := notDeleted(bson.M{key: serverMemberQueryValue(key, value)})
+  if !cacheEnabled(ServerMemberColName) {
+    member := new(ServerMember)
+    if err := ServerMemberStore().FindOne(filter, member); err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+    }
+    return member, nil
+  }
+
+  client := net.RedisGetClient(ServerMemberClientName)
+  cacheKey := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "ServerMember.CacheGet", ServerMemberDBName, ServerMemberColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("ServerMember", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ServerMember", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("ServerMember", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().cacheTTL(getConfig().ServerMemberCacheTTL))
+    server := new(ServerMember)
+    if err := json.Unmarshal([]byte(result), server); err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+    }
+    return server, nil
+  }
+
+  // Get what's in the database. Only the caller holding the lock fetches it, while others wait for the
+  // holder to fill the cache, and only fetch it themselves if it isn't filled in time.
+  recordCacheResult("ServerMember", key, "miss")
+  if token, locked := acquireCacheLock(client, cacheKey); locked {
+    defer releaseCacheLock(client, cacheKey, token)
+  } else if result, neg := waitForCacheFill(client, cacheKey, negCache); neg {
+    return nil, &ModelNotFoundError{Model: "ServerMember", Key: key, Value: value}
+  } else if result != "" {
+    server := new(ServerMember)
+    if err := json.Unmarshal([]byte(result), server); err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+    }
+    return server, nil
+  }
+  server := new(ServerMember)
+  err = ServerMemberStore().FindOne(filter, server)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheServerMember(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheServerMember(client, cacheKey, server)
+  }
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "CacheGet", key, value, err)
+  }
+  return server, nil
+}
+
+// CacheRefreshServerMember replaces the cached ServerMember for the key and value with a fresh copy from
+// the database, for when it's known to have changed outside of this package. If it no longer exists,
+// it's neg-cached instead, and a ModelNotFoundError is returned.
+func CacheRefreshServerMember(ctx context.Context, key, value string) (err error) {
+
+  ctx, span := startSpan(ctx, "ServerMember.CacheRefresh", ServerMemberDBName, ServerMemberColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "CacheRefresh", time.Now(), &err)
+
+  // Evict what's cached.
+  client := net.RedisGetClient(ServerMemberClientName)
+  cacheKey := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"+key+":"+value
+  if err := client.Del(cacheKey, "neg:"+cacheKey).Err(); err != nil {
+    return wrapDBError("ServerMember", "CacheRefresh", key, value, err)
+  }
+
+  // Fetch it again, and cache what was found.
+  member := new(ServerMember)
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(bson.M{key: serverMemberQueryValue(key, value)})).One(member)
+  })
+  if err == mgo.ErrNotFound {
+    fillNegCacheServerMember(client, cacheKey)
+  } else if err == nil {
+    fillCacheServerMember(client, cacheKey, member)
+  }
+  return wrapDBError("ServerMember", "CacheRefresh", key, value, err)
+}
+
+// CacheGetServerMemberByMemberAndServer attempts to find the ServerMember with the Discord member ID in
+// the server in cache before looking in the database and setting cache if found. Unlike looking up
+// discord_member_id alone, it can't return the member of another server. Members are cached by the
+// composite key discord_member_id:X:discord_server_id:Y. If "negCache" is true, will check for
+// neg-cache first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetServerMemberByMemberAndServer(memberID, serverID string, negCache bool) (found *ServerMember, err error) {
+
+  // Skip the cache while a store is injected.
+  filter := notDeleted(bson.M{"discord_member_id": memberID, "discord_server_id": serverID})
+  if !cacheEnabled(ServerMemberColName) {
+    member := new(ServerMember)
+    if err := ServerMemberStore().FindOne(filter, member); err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", "discord_member_id", memberID, err)
+    }
+    return member, nil
+  }
+
+  client := net.RedisGetClient(ServerMemberClientName)
+  cacheKey := serverMemberCompositeCacheKey(memberID, serverID)
+  _, span := startCacheSpan(context.Background(), "ServerMember.CacheGet", ServerMemberDBName, ServerMemberColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", "discord_member_id", memberID, err)
+    } else if result != "" {
+      recordCacheResult("ServerMember", "discord_member_id:discord_server_id", "neg_hit")
+      return nil, &ModelNotFoundError{Model: "ServerMember", Key: "discord_member_id", Value: memberID}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerMember", "CacheGet", "discord_member_id", memberID, err)
+  } else if result != "" {
+    recordCacheResult("ServerMember", "discord_member_id:discord_server_id", "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().cacheTTL(getConfig().ServerMemberCacheTTL))
+    member := new(ServerMember)
+    if err := json.Unmarshal([]byte(result), member); err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGet", "discord_member_id", memberID, err)
+    }
+    return member, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("ServerMember", "discord_member_id:discord_server_id", "miss")
+  member := new(ServerMember)
+  err = ServerMemberStore().FindOne(filter, member)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheServerMember(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheServerMember(client, cacheKey, member)
+  }
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "CacheGet", "discord_member_id", memberID, err)
+  }
+  return member, nil
+}
+
+// CacheGetManyServerMembers is the batch form of CacheGetServerMember. keys[i] and values[i] make up
+// each lookup, and result[i] is the ServerMember found for it, or nil if there was none. Cache is
+// checked for every lookup in a single pipelined round-trip, and all misses are then fetched from the
+// database in a single query and cached.
+func CacheGetManyServerMembers(keys []string, values []string, negCache bool) (results []*ServerMember, err error) {
+
+  _, span := startSpan(context.Background(), "ServerMember.CacheGetMany", ServerMemberDBName, ServerMemberColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("ServerMember", "CacheGetMany", time.Now(), &err)
+
+  if len(keys) != len(values) {
+    return nil, fmt.Errorf("mismatched keys and values: %d keys, %d values", len(keys), len(values))
+  }
+  results = make([]*ServerMember, len(keys))
+  if len(keys) == 0 {
+    return results, nil
+  }
+
+  client := net.RedisGetClient(ServerMemberClientName)
+  cacheKeys := make([]string, len(keys))
+  for i := range keys {
+    cacheKeys[i] = ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"+keys[i]+":"+values[i]
+  }
+
+  // Queue up a Get for every cache key, and its neg-cache key if needed.
+  pipe := client.Pipeline()
+  gets := make([]*redis.StringCmd, len(keys))
+  negGets := make([]*redis.StringCmd, len(keys))
+  for i, cacheKey := range cacheKeys {
+    gets[i] = pipe.Get(cacheKey)
+    if negCache {
+      negGets[i] = pipe.Get("neg:"+cacheKey)
+    }
+  }
+  if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+  }
+
+  // Take what's in cache, collecting the rest as misses grouped by key.
+  misses := []int{}
+  missValues := map[string][]interface{}{}
+  for i := range keys {
+    if negCache {
+      if result, err := negGets[i].Result(); err != nil && err != redis.Nil {
+        return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+      } else if result != "" {
+        recordCacheResult("ServerMember", keys[i], "neg_hit")
+        continue
+      }
+    }
+    result, err := gets[i].Result()
+    if err != nil && err != redis.Nil {
+      return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+    } else if result != "" {
+      recordCacheResult("ServerMember", keys[i], "hit")
+      go refreshCacheTTL(client, cacheKeys[i], getConfig().cacheTTL(getConfig().ServerMemberCacheTTL))
+      results[i] = new(ServerMember)
+      if err := json.Unmarshal([]byte(result), results[i]); err != nil {
+        return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+      }
+      continue
+    }
+    recordCacheResult("ServerMember", keys[i], "miss")
+    misses = append(misses, i)
+    if keys[i] == "discord_user_id" {
+      missValues[keys[i]] = append(missValues[keys[i]], EncryptedString(values[i]), values[i])
+    } else {
+      missValues[keys[i]] = append(missValues[keys[i]], bsonQueryValue(keys[i], values[i]))
+    }
+  }
+  if len(misses) == 0 {
+    return results, nil
+  }
+
+  // Get all the misses from the database in one query.
+  or := make([]bson.M, 0, len(missValues))
+  for key, vals := range missValues {
+    or = append(or, bson.M{key: bson.M{"$in": vals}})
+  }
+  found := []*ServerMember{}
+  err = net.MgoCol(ServerMemberClientName, ServerMemberDBName, ServerMemberColName).Find(notDeleted(bson.M{
+    "$or": or,
+  })).All(&found)
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+  }
+
+  // Match what was found back to the lookups that missed, filling cache or neg cache as we go.
+  for _, doc := range found {
+    fields, err := bsonFields(doc)
+    if err != nil {
+      return nil, wrapDBError("ServerMember", "CacheGetMany", "", "", err)
+    }
+    for _, i := range misses {
+      value := bsonValueString(fields[keys[i]])
+      if keys[i] == "discord_user_id" {
+        value = string(doc.DiscordUserID)
+      }
+      if results[i] == nil && value == values[i] {
+        results[i] = doc
+        go fillCacheServerMember(client, cacheKeys[i], doc)
+      }
+    }
+  }
+  if negCache {
+    for _, i := range misses {
+      if results[i] == nil {
+        go fillNegCacheServerMember(client, cacheKeys[i])
+      }
+    }
+  }
+  return results, nil
+}
+
+// serverMemberQueryValue is bsonQueryValue for ServerMember, which also matches DiscordUserID lookups
+// whether they're stored encrypted or not.
+func serverMemberQueryValue(key, value string) interface{} {
+
+  if key == "discord_user_id" {
+    return MatchEncrypted(value)
+  }
+  return bsonQueryValue(key, value)
+}
+
+// serverMemberCompositeCacheKey builds the composite cache key for the member in the server.
+func serverMemberCompositeCacheKey(memberID, serverID string) string {
+  return ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":discord_member_id:"+memberID+":discord_server_id:"+serverID
+}
+
+func fillCacheServerMember(client *redis.Client, key string, value *ServerMember) {
+  _, span := startCacheSpan(context.Background(), "ServerMember.fillCache", ServerMemberDBName, ServerMemberColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for ServerMember")
+    endSpan(span, err)
+    return
+  }
+  cfg := getConfig()
+  err = client.Set(key, string(serialized), cfg.cacheTTL(cfg.ServerMemberCacheTTL)).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for ServerMember")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheServerMember(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "ServerMember.fillNegCache", ServerMemberDBName, ServerMemberColName, "neg:"+key)
+  cfg := getConfig()
+  err := client.Set("neg:"+key, "neg", cfg.negCacheTTL(cfg.ServerMemberNegCacheTTL)).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for ServerMember")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheServerMember(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for ServerMember")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetServerMember and
+// CacheGetServerMemberByMemberAndServer.
+func (this *ServerMember) cacheKeys() []string {
+  prefix := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+    prefix+"discord_member_id:"+this.DiscordMemberID,
+    prefix+"discord_server_id:"+this.DiscordServerID,
+    prefix+"discord_user_id:"+string(this.DiscordUserID),
+    serverMemberCompositeCacheKey(this.DiscordMemberID, this.DiscordServerID),
+  }
+}
+
+// Hook functions.
+
+// serverMemberValidateHooks are the hooks registered with RegisterServerMemberValidateHook, by event,
+// in registration order.
+var serverMemberValidateHooks = map[string][]func(*ServerMember) error{}
+var serverMemberValidateHooksMutex sync.RWMutex
+
+// RegisterServerMemberValidateHook registers fn to run on every ServerMember being validated, at the
+// event, either HookBeforeValidate or HookAfterValidate. Hooks for the same event run in registration
+// order. After hooks only run if the tag validations pass.
+func RegisterServerMemberValidateHook(event string, fn func(*ServerMember) error) error {
+
+  if !validateHookEvents[event] {
+    return fmt.Errorf("can't register ServerMember validate hook for unknown event %q", event)
+  }
+  serverMemberValidateHooksMutex.Lock()
+  defer serverMemberValidateHooksMutex.Unlock()
+  serverMemberValidateHooks[event] = append(serverMemberValidateHooks[event], fn)
+  return nil
+}
+
+// runValidateHooks runs the validate hooks registered for the event on the document, stopping at the
+// first error.
+func (this *ServerMember) runValidateHooks(event string) error {
+
+  serverMemberValidateHooksMutex.RLock()
+  hooks := serverMemberValidateHooks[event]
+  serverMemberValidateHooksMutex.RUnlock()
+  for _, hook := range hooks {
+    if err := hook(this); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Change stream functions.
+
+// ServerMemberChangeEvent describes a change to a ServerMember seen by WatchServerMembers.
+type ServerMemberChangeEvent struct {
+  // OperationType is "insert", "update", "replace", or "delete".
+  OperationType     string        `bson:"operationType"`
+  DocumentKey       bson.ObjectId `bson:"-"`
+  // FullDocument is the ServerMember as of the change, for inserts and updates.
+  FullDocument      *ServerMember `bson:"fullDocument"`
+  // UpdateDescription lists the updated and removed fields, for updates.
+  UpdateDescription bson.M        `bson:"updateDescription"`
+}
+
+// serverMemberWatcher tracks the running WatchServerMembers stream.
+var serverMemberWatcher = new(watcher)
+
+// WatchServerMembers calls handler with every change on the server_members collection matched by the
+// pipeline, until ctx is done, when it returns nil. A nil pipeline matches every insert, update, and
+// delete, and ownership changes can be watched for with a pipeline like:
+//
+//   []bson.M{{"$match": bson.M{"updateDescription.updatedFields.owner_discord_id": bson.M{"$exists": true}}}}
+//
+// Handlers are called one at a time, in order. The stream's position is persisted to Redis after each
+// one, so a restarted watcher continues from where the last one left off, and the stream is reopened
+// with a backoff if it fails. Only one can run at a time, returning ErrWatcherRunning otherwise, and it
+// can be monitored with ChangeStreamHealth and stopped with StopWatch.
+func WatchServerMembers(ctx context.Context, pipeline []bson.M, handler func(*ServerMemberChangeEvent)) error {
+
+  ctx, stopped, err := serverMemberWatcher.start(ctx)
+  if err != nil {
+    return err
+  }
+  defer stopped()
+
+  if pipeline == nil {
+    pipeline = []bson.M{
+      {"$match": bson.M{"operationType": bson.M{"$in": []string{"insert", "update", "delete"}}}},
+    }
+  }
+  return watchCollection(ctx, serverMemberWatcher, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, pipeline, func(raw bson.Raw) error {
+    event := new(ServerMemberChangeEvent)
+    if err := raw.Unmarshal(event); err != nil {
+      return err
+    }
+    key := struct {
+      DocumentKey struct {
+        ID bson.ObjectId `bson:"_id"`
+      } `bson:"documentKey"`
+    }{}
+    if err := raw.Unmarshal(&key); err != nil {
+      return err
+    }
+    event.DocumentKey = key.DocumentKey.ID
+    handler(event)
+    return nil
+  })
+}
+
+// ChangeStreamHealth reports whether the WatchServerMembers stream is running and open, going by its
+// heartbeat, and when it last handled an event. The time is zero if it hasn't handled one since it
+// started.
+func ChangeStreamHealth() (alive bool, lastEvent time.Time) {
+
+  return serverMemberWatcher.health()
+}
+
+// StopWatch stops the WatchServerMembers stream and waits for it to return, or for ctx to be done. It's
+// a no-op if the stream isn't running.
+func StopWatch(ctx context.Context) error {
+
+  return serverMemberWatcher.stop(ctx)
+}
+
+// Misc functions.
+
+// AggregateServerMembers runs the aggregation pipeline on the ServerMember collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServerMembers(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ServerMember", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerMemberClientName, ServerMemberDBName, ServerMemberColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ServerMember", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateServerMemberOne runs the aggregation pipeline on the ServerMember collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateServerMemberOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("ServerMember", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(ServerMemberClientName, ServerMemberDBName, ServerMemberColName, pipeline, opts)
+  defer done()
+  return wrapDBError("ServerMember", "Aggregate", "", "", pipe.One(result))
+}
+
+// groupByServerCacheKey is where GroupByServer's member counts are cached.
+const groupByServerCacheKey = ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":group_by_server"
+
+// GroupByServer counts the ServerMembers in each server, by Discord server ID, excluding soft-deleted
+// ones. Counts are cached for GroupByServerCacheTTL, since they're expensive to compute.
+func GroupByServer(ctx context.Context) (counts map[string]int, err error) {
+
+  defer observeOperation("ServerMember", "GroupByServer", time.Now(), &err)
+
+  // Return what's in cache if it's found.
+  client := net.RedisGetClient(ServerMemberClientName)
+  if result, err := client.Get(groupByServerCacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerMember", "GroupByServer", "", "", err)
+  } else if result != "" {
+    recordCacheResult("ServerMember", "group_by_server", "hit")
+    counts = map[string]int{}
+    if err := json.Unmarshal([]byte(result), &counts); err != nil {
+      return nil, wrapDBError("ServerMember", "GroupByServer", "", "", err)
+    }
+    return counts, nil
+  }
+
+  // Count in the database and cache the counts briefly.
+  recordCacheResult("ServerMember", "group_by_server", "miss")
+  groups := []struct {
+    ServerID string `bson:"_id"`
+    Count    int    `bson:"count"`
+  }{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Pipe([]bson.M{
+      {"$match": bson.M{"deleted_at": nil}},
+      {"$group": bson.M{"_id": "$discord_server_id", "count": bson.M{"$sum": 1}}},
+    }).All(&groups)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "GroupByServer", "", "", err)
+  }
+  counts = make(map[string]int, len(groups))
+  for _, group := range groups {
+    counts[group.ServerID] = group.Count
+  }
+  go func() {
+    serialized, err := json.Marshal(counts)
+    if err == nil {
+      err = client.Set(groupByServerCacheKey, string(serialized), GroupByServerCacheTTL).Err()
+    }
+    if err != nil {
+      log.Warn().AnErr("fillCache", err).Msgf("Error filling group by server cache for ServerMember")
+    }
+  }()
+  return counts, nil
+}
+
+// BustGroupByServerCache evicts GroupByServer's cached counts. It's called whenever a ServerMember is
+// created or deleted.
+func BustGroupByServerCache() error {
+
+  return net.RedisGetClient(ServerMemberClientName).Del(groupByServerCacheKey).Err()
+}
+
+// bustGroupByServerCache runs BustGroupByServerCache in the background, logging its error. It's a no-op
+// while a store is injected, since nothing is cached then, which is checked before going to the background.
+func bustGroupByServerCache() {
+
+  if !cacheEnabled(ServerMemberColName) {
+    return
+  }
+  go func() {
+    if err := BustGroupByServerCache(); err != nil {
+      log.Warn().AnErr("bustCache", err).Msgf("Error busting group by server cache for ServerMember")
+    }
+  }()
+}
+
+// OwnerCount is how many ServerMembers an owner owns, as returned by TopOwners.
+type OwnerCount struct {
+  OwnerDiscordID string `json:"owner_discord_id"`
+  Count          int    `json:"count"`
+}
+
+// countByOwnerCacheKey is where CountByOwner's pet counts for the server are cached.
+func countByOwnerCacheKey(serverID string) string {
+  return ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":count_by_owner:"+serverID
+}
+
+// CountByOwner counts the server's owned ServerMembers by owner Discord ID, excluding soft-deleted ones.
+// Counts are cached for CountByOwnerCacheTTL rather than evicted on every ownership change, so they can
+// be briefly out of date.
+func CountByOwner(ctx context.Context, serverID string) (counts map[string]int, err error) {
+
+  defer observeOperation("ServerMember", "CountByOwner", time.Now(), &err)
+
+  // Return what's in cache if it's found.
+  key := countByOwnerCacheKey(serverID)
+  client := net.RedisGetClient(ServerMemberClientName)
+  if result, err := client.Get(key).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("ServerMember", "CountByOwner", "discord_server_id", serverID, err)
+  } else if result != "" {
+    recordCacheResult("ServerMember", "count_by_owner", "hit")
+    counts = map[string]int{}
+    if err := json.Unmarshal([]byte(result), &counts); err != nil {
+      return nil, wrapDBError("ServerMember", "CountByOwner", "discord_server_id", serverID, err)
+    }
+    return counts, nil
+  }
+
+  // Count in the database and cache the counts briefly.
+  recordCacheResult("ServerMember", "count_by_owner", "miss")
+  groups := []struct {
+    OwnerID string `bson:"_id"`
+    Count   int    `bson:"count"`
+  }{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Pipe([]bson.M{
+      {"$match": bson.M{"discord_server_id": serverID, "owner_discord_id": bson.M{"$exists": true, "$ne": ""}, "deleted_at": nil}},
+      {"$group": bson.M{"_id": "$owner_discord_id", "count": bson.M{"$sum": 1}}},
+    }).All(&groups)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "CountByOwner", "discord_server_id", serverID, err)
+  }
+  counts = make(map[string]int, len(groups))
+  for _, group := range groups {
+    counts[group.OwnerID] = group.Count
+  }
+  go func() {
+    serialized, err := json.Marshal(counts)
+    if err == nil {
+      err = client.Set(key, string(serialized), CountByOwnerCacheTTL).Err()
+    }
+    if err != nil {
+      log.Warn().AnErr("fillCache", err).Msgf("Error filling count by owner cache for ServerMember")
+    }
+  }()
+  return counts, nil
+}
+
+// TopOwners returns the server's owners with the most ServerMembers, most first, then by Discord ID. A
+// limit of 0 means no limit. It's built from CountByOwner, so it's cached the same way.
+func TopOwners(ctx context.Context, serverID string, limit int) ([]OwnerCount, error) {
+
+  counts, err := CountByOwner(ctx, serverID)
+  if err != nil {
+    return nil, err
+  }
+  top := make([]OwnerCount, 0, len(counts))
+  for ownerID, count := range counts {
+    top = append(top, OwnerCount{OwnerDiscordID: ownerID, Count: count})
+  }
+  sort.Slice(top, func(i, j int) bool {
+    if top[i].Count != top[j].Count {
+      return top[i].Count > top[j].Count
+    }
+    return top[i].OwnerDiscordID < top[j].OwnerDiscordID
+  })
+  if limit > 0 && len(top) > limit {
+    top = top[:limit]
+  }
+  return top, nil
+}
+
+// FindAndModifyServerMember atomically applies the update to the first ServerMember matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyServerMember(selector, update bson.M, returnNew bool) (_ *ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(ServerMember)
+  _, err = storeFindAndModify(serverMemberStore(context.Background()), selector, mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "FindAndModify", "", "", err)
+  }
+  if cacheEnabled(ServerMemberColName) {
+    go invalidateCacheServerMember(net.RedisGetClient(ServerMemberClientName), result.cacheKeys())
+  }
+  return result, nil
+}
+
+// FindServerMemberByMemberID finds the ServerMember with the Discord member ID, excluding soft-deleted ones. Returns a
+// ModelNotFoundError if there is none. This does not touch the cache; see CacheGetServerMember for that.
+func FindServerMemberByMemberID(ctx context.Context, memberID string) (_ *ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindOne", time.Now(), &err)
+
+  found := new(ServerMember)
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(bson.M{"discord_member_id": memberID})).One(found)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "FindOne", "discord_member_id", memberID, err)
+  }
+  return found, nil
+}
+
+// FindServerMembers finds all ServerMembers matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindServerMembers(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Find", time.Now(), &err)
+  defer logSlowQuery("ServerMember", "Find", filter)()
+
+  results := []*ServerMember{}
+  if err := storeFind(serverMemberStore(ctx, opts...), notDeleted(filter), sort, limit, &results); err != nil {
+    return nil, wrapDBError("ServerMember", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindServerMembersWithDeleted finds all ServerMembers matching the filter, including soft-deleted ones.
+func FindServerMembersWithDeleted(filter bson.M) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Find", time.Now(), &err)
+
+  results := []*ServerMember{}
+  if err := ServerMemberStore().FindMany(filter, &results); err != nil {
+    return nil, wrapDBError("ServerMember", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// BulkCreateServerMembers persists the documents in a single unordered bulk insert, setting their IDs
+// and timestamps the way Create does. Documents which fail validation are skipped, but the rest are
+// still inserted. Returns how many were inserted, and each document's error by index, which is nil if
+// it was inserted. Inserted documents are cached in the background.
+func BulkCreateServerMembers(ctx context.Context, docs []*ServerMember) (created int, errs []error) {
+
+  var err error
+  defer observeOperation("ServerMember", "BulkCreate", time.Now(), &err)
+  defer logSlowQuery("ServerMember", "BulkCreate", nil)()
+
+  // Ensure IDs, timestamps, and validations, keeping the index of each valid document.
+  errs = make([]error, len(docs))
+  now := clockNow()
+  valid := []interface{}{}
+  indices := []int{}
+  for i, doc := range docs {
+    if doc == nil {
+      errs[i] = errors.New("can't create nil ServerMember")
+      continue
+    }
+    doc.ID = bson.NewObjectId()
+    doc.CreatedAt = now
+    doc.UpdatedAt = now
+    doc.Version = 1
+    doc.ModelVersion = ServerMemberModelVersion
+    if errs[i] = doc.Validate(ctx); errs[i] == nil {
+      valid = append(valid, doc)
+      indices = append(indices, i)
+    }
+  }
+  if len(valid) == 0 {
+    return 0, errs
+  }
+
+  // Persist them in one go.
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    bulk := col.Bulk()
+    bulk.Unordered()
+    bulk.Insert(valid...)
+    _, err := bulk.Run()
+    return err
+  })
+
+  // Match failures back to their documents. Without a bulk error, either everything failed or nothing did.
+  bulkErr, ok := err.(*mgo.BulkError)
+  if err != nil && !ok {
+    for _, i := range indices {
+      errs[i] = wrapDBError("ServerMember", "BulkCreate", "", "", err)
+    }
+    return 0, errs
+  }
+  failed := map[int]bool{}
+  if ok {
+    for _, c := range bulkErr.Cases() {
+      if c.Index < 0 || c.Index >= len(indices) {
+        log.Warn().AnErr("bulkCreate", c.Err).Msgf("Error inserting an unknown ServerMember")
+        continue
+      }
+      failed[c.Index] = true
+      errs[indices[c.Index]] = wrapDBError("ServerMember", "BulkCreate", "_id", docs[indices[c.Index]].ID.Hex(), c.Err)
+    }
+  }
+
+  // Cache what was inserted.
+  client := net.RedisGetClient(ServerMemberClientName)
+  prefix := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"
+  for n, i := range indices {
+    if failed[n] {
+      continue
+    }
+    created++
+    doc := docs[i]
+    go func() {
+      fillCacheServerMember(client, prefix+"_id:"+doc.ID.Hex(), doc)
+      fillCacheServerMember(client, serverMemberCompositeCacheKey(doc.DiscordMemberID, doc.DiscordServerID), doc)
+    }()
+  }
+  if created > 0 {
+    bustGroupByServerCache()
+  }
+  return created, errs
+}
+
+// FindOrCreateServerMember finds the ServerMember with the given DiscordMemberID, creating it if it doesn't exist yet. The
+// returned bool is true if the document was freshly inserted. Creation is an upsert with $setOnInsert,
+// backed by the unique index on discord_member_id, so concurrent callers can't insert duplicates. A
+// soft-deleted ServerMember is returned as-is.
+func FindOrCreateServerMember(discordUserID, discordServerID, discordMemberID string) (_ *ServerMember, _ bool, err error) {
+
+  defer observeOperation("ServerMember", "FindOrCreate", time.Now(), &err)
+
+  store := ServerMemberStore()
+  selector := bson.M{"discord_member_id": discordMemberID}
+
+  // Return the existing document if there is one.
+  existing := new(ServerMember)
+  if err := store.FindOne(selector, existing); err == nil {
+    return existing, false, nil
+  } else if err != mgo.ErrNotFound {
+    return nil, false, wrapDBError("ServerMember", "FindOrCreate", "", "", err)
+  }
+
+  // Build the new document the same way Create would.
+  now := clockNow()
+  doc := &ServerMember{
+    ID:              bson.NewObjectId(),
+    DiscordUserID:   EncryptedString(discordUserID),
+    DiscordServerID: discordServerID,
+    DiscordMemberID: discordMemberID,
+    CreatedAt:       now,
+    UpdatedAt:       now,
+    Version:         1,
+    ModelVersion:    ServerMemberModelVersion,
+  }
+  if err := doc.Validate(context.Background()); err != nil {
+    return nil, false, err
+  }
+
+  // Insert it only if nobody else did in the meantime.
+  fields, err := bsonFields(doc)
+  if err != nil {
+    return nil, false, wrapDBError("ServerMember", "FindOrCreate", "", "", err)
+  }
+  result := new(ServerMember)
+  upserted, err := storeFindAndModify(store, selector, mgo.Change{
+    Update:    bson.M{"$setOnInsert": fields},
+    Upsert:    true,
+    ReturnNew: true,
+  }, result)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. The
+  // losers find the winner's document.
+  if mgo.IsDup(err) {
+    if err := store.FindOne(selector, result); err != nil {
+      return nil, false, wrapDBError("ServerMember", "FindOrCreate", "", "", err)
+    }
+    return result, false, nil
+  }
+  if err != nil {
+    return nil, false, wrapDBError("ServerMember", "FindOrCreate", "", "", err)
+  }
+  if upserted {
+    bustGroupByServerCache()
+  }
+  return result, upserted, nil
+}
+
+// FindServerMembersPage finds one page of ServerMembers matching the filter using skip/limit, excluding
+// soft-deleted ones. Pages start at 1. The total number of matching documents is returned alongside so
+// callers can compute the page count.
+func FindServerMembersPage(filter bson.M, sort string, page, pageSize int) (_ []*ServerMember, _ int, err error) {
+
+  defer observeOperation("ServerMember", "Find", time.Now(), &err)
+
+  if page < 1 {
+    page = 1
+  }
+  store := ServerMemberStore()
+  filter = notDeleted(filter)
+
+  // Count everything matching first.
+  total, err := storeCount(store, filter)
+  if err != nil {
+    return nil, 0, wrapDBError("ServerMember", "Find", "", "", err)
+  }
+
+  // Then get the requested page.
+  skip := 0
+  if pageSize > 0 {
+    skip = (page-1)*pageSize
+  }
+  results := []*ServerMember{}
+  if err := storeFindPage(store, filter, sort, skip, pageSize, &results); err != nil {
+    return nil, 0, wrapDBError("ServerMember", "Find", "", "", err)
+  }
+  return results, total, nil
+}
+
+// FindServerMembersAfter finds up to limit ServerMembers whose ID comes after afterID, sorted by ID
+// ascending, excluding soft-deleted ones. An empty afterID starts from the beginning. Pass the ID of
+// the last result as the next afterID to continue.
+func FindServerMembersAfter(afterID bson.ObjectId, limit int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Find", time.Now(), &err)
+
+  filter := bson.M{}
+  if afterID != "" {
+    filter["_id"] = bson.M{"$gt": afterID}
+  }
+  results := []*ServerMember{}
+  if err := storeFind(ServerMemberStore(), notDeleted(filter), "_id", limit, &results); err != nil {
+    return nil, wrapDBError("ServerMember", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountServerMembers counts the ServerMembers matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountServerMembers(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("ServerMember", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(ServerMemberClientName, ServerMemberDBName, ServerMemberColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("ServerMember", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// ServerMemberExists reports whether any ServerMember matches the filter, excluding soft-deleted ones.
+func ServerMemberExists(filter bson.M) (bool, error) {
+
+  count, err := CountServerMembers(filter)
+  return count > 0, err
+}
+
+// DistinctServerMemberFields finds the distinct values of the field among the ServerMembers matching the filter, excluding
+// soft-deleted ones. Encrypted fields are returned as stored. This does not touch the cache.
+func DistinctServerMemberFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("ServerMember", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctServerMemberStringFields is DistinctServerMemberFields for string fields, like discord_server_id. It fails if
+// any of the values isn't a string.
+func DistinctServerMemberStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctServerMemberFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactServerMemberCollection runs the compact command against the ServerMember collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactServerMemberCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName)
+}
+
+// ServerMemberCollectionStats returns the ServerMember collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func ServerMemberCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName)
+}
+
+// ExplainServerMemberQuery returns MongoDB's explain output for finding the filter in the ServerMember collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainServerMemberQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, filter)
+}
+
+// UpdateManyServerMembers applies the updates to every ServerMember matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateServerMembers for that. The ServerMember cache is flushed.
+func UpdateManyServerMembers(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, filter, updates)
+}
+
+// BulkUpdateServerMembers is UpdateManyServerMembers, but validates each ServerMember as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateServerMembers(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, filter, updates, func() validatable { return new(ServerMember) })
+}
+
+// DeleteManyServerMembers soft-deletes every ServerMember matching the filter in a single query, returning how many
+// were deleted. The ServerMember cache is evicted, including GroupByServer's counts.
+func DeleteManyServerMembers(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, filter)
+}
+
+// FindPetsByOwner finds the ServerMembers in the server owned by ownerDiscordID, excluding soft-deleted
+// ones. This does not touch the cache.
+func FindPetsByOwner(ctx context.Context, ownerDiscordID string, serverID string) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindPetsByOwner", time.Now(), &err)
+
+  filter := bson.M{"owner_discord_id": ownerDiscordID, "discord_server_id": serverID}
+  return findServerMembers(ctx, "FindPetsByOwner", filter, "", 0)
+}
+
+// FindAllPetsByOwner finds the ServerMembers owned by ownerDiscordID in every server, excluding
+// soft-deleted ones. This does not touch the cache.
+func FindAllPetsByOwner(ctx context.Context, ownerDiscordID string) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindAllPetsByOwner", time.Now(), &err)
+
+  return findServerMembers(ctx, "FindAllPetsByOwner", bson.M{"owner_discord_id": ownerDiscordID}, "", 0)
+}
+
+// unownedMembersFilter matches the server's ServerMembers without an owner, whether it was released or
+// never set.
+func unownedMembersFilter(serverID string) bson.M {
+
+  return bson.M{"discord_server_id": serverID, "owner_discord_id": bson.M{"$in": []interface{}{nil, ""}}}
+}
+
+// FindUnownedMembers finds the server's ServerMembers without an owner, newest first, excluding
+// soft-deleted ones. A limit of 0 means no limit. This does not touch the cache.
+func FindUnownedMembers(ctx context.Context, serverID string, limit int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindUnowned", time.Now(), &err)
+
+  return findServerMembers(ctx, "FindUnowned", unownedMembersFilter(serverID), "-created_at", limit)
+}
+
+// CountUnownedMembers counts the server's ServerMembers without an owner, excluding soft-deleted ones.
+// This does not touch the cache.
+func CountUnownedMembers(ctx context.Context, serverID string) (count int, err error) {
+
+  defer observeOperation("ServerMember", "CountUnowned", time.Now(), &err)
+
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    var err error
+    count, err = col.Find(notDeleted(unownedMembersFilter(serverID))).Count()
+    return err
+  })
+  if err != nil {
+    return 0, wrapDBError("ServerMember", "CountUnowned", "discord_server_id", serverID, err)
+  }
+  return count, nil
+}
+
+// FindMembersWithMostSecOwners finds the server's ServerMembers with the most secondary owners, most
+// first, excluding soft-deleted ones. A limit of 0 means no limit. The count is only computed for
+// sorting, and isn't part of the results. This does not touch the cache.
+func FindMembersWithMostSecOwners(ctx context.Context, serverID string, limit int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindMembersWithMostSecOwners", time.Now(), &err)
+
+  pipeline := []bson.M{
+    {"$match": bson.M{"discord_server_id": serverID, "deleted_at": nil}},
+    {"$addFields": bson.M{"sec_owner_count": bson.M{"$size": bson.M{"$ifNull": []interface{}{"$sec_owner_discord_ids", []string{}}}}}},
+    {"$sort": bson.M{"sec_owner_count": -1}},
+  }
+  if limit > 0 {
+    pipeline = append(pipeline, bson.M{"$limit": limit})
+  }
+  pipeline = append(pipeline, bson.M{"$project": bson.M{"sec_owner_count": 0}})
+
+  results := []*ServerMember{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Pipe(pipeline).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "FindMembersWithMostSecOwners", "discord_server_id", serverID, err)
+  }
+  return results, nil
+}
+
+// RecentlyCreatedMembers finds the server's most recently created ServerMembers, newest first,
+// excluding soft-deleted ones. A limit of 0 means no limit. This does not touch the cache, since recency
+// changes with every insert.
+func RecentlyCreatedMembers(ctx context.Context, serverID string, limit int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "RecentlyCreated", time.Now(), &err)
+
+  return findServerMembers(ctx, "RecentlyCreated", bson.M{"discord_server_id": serverID}, "-created_at", limit)
+}
+
+// findServerMembers finds the ServerMembers matching the filter, excluding soft-deleted ones, for the
+// operation. An empty sort leaves the default order, and a limit of 0 means no limit.
+func findServerMembers(ctx context.Context, operation string, filter bson.M, sort string, limit int) ([]*ServerMember, error) {
+
+  results := []*ServerMember{}
+  err := mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).Limit(limit)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", operation, "", "", err)
+  }
+  return results, nil
+}
\ No newline at end of file
@@ -0,0 +1,127 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// ServerMemberQuery builds a ServerMember query fluently, accumulating a filter and query options
+// until one of its terminal methods (Execute, ExecuteOne, or Count) fires it. Soft-deleted documents
+// are excluded.
+type ServerMemberQuery struct {
+  filter     bson.M
+  sort       []string
+  limit      int
+  skip       int
+  projection bson.M
+}
+
+// NewServerMemberQuery returns an empty ServerMemberQuery, which matches every ServerMember.
+func NewServerMemberQuery() *ServerMemberQuery {
+  return &ServerMemberQuery{filter: bson.M{}}
+}
+
+// WhereServer filters by Discord server ID.
+func (this *ServerMemberQuery) WhereServer(id string) *ServerMemberQuery {
+  this.filter["discord_server_id"] = id
+  return this
+}
+
+// WhereUser filters by Discord user ID.
+func (this *ServerMemberQuery) WhereUser(id string) *ServerMemberQuery {
+  this.filter["discord_user_id"] = MatchEncrypted(id)
+  return this
+}
+
+// WhereMember filters by Discord member ID.
+func (this *ServerMemberQuery) WhereMember(id string) *ServerMemberQuery {
+  this.filter["discord_member_id"] = id
+  return this
+}
+
+// Sort adds a sort on the given bson field. Calling it multiple times sorts by each field in order.
+func (this *ServerMemberQuery) Sort(field string, asc bool) *ServerMemberQuery {
+  if !asc {
+    field = "-" + field
+  }
+  this.sort = append(this.sort, field)
+  return this
+}
+
+// Limit caps the number of results. 0 means no limit.
+func (this *ServerMemberQuery) Limit(n int) *ServerMemberQuery {
+  this.limit = n
+  return this
+}
+
+// Skip skips the first n results.
+func (this *ServerMemberQuery) Skip(n int) *ServerMemberQuery {
+  this.skip = n
+  return this
+}
+
+// WithProjection only returns the fields selected by the projection, leaving the rest zero-valued.
+func (this *ServerMemberQuery) WithProjection(projection bson.M) *ServerMemberQuery {
+  this.projection = projection
+  return this
+}
+
+// Execute runs the query and returns every matching ServerMember.
+func (this *ServerMemberQuery) Execute() (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Query", time.Now(), &err)
+
+  results := []*ServerMember{}
+  if err = this.query().All(&results); err != nil {
+    return nil, wrapDBError("ServerMember", "Query", "", "", err)
+  }
+  return results, nil
+}
+
+// ExecuteOne runs the query and returns the first matching ServerMember, or a ModelNotFoundError.
+func (this *ServerMemberQuery) ExecuteOne() (_ *ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "Query", time.Now(), &err)
+
+  result := new(ServerMember)
+  if err = this.query().One(result); err != nil {
+    return nil, wrapDBError("ServerMember", "Query", "", "", err)
+  }
+  return result, nil
+}
+
+// Count runs the query and returns the number of matching ServerMembers. Limit and skip are applied.
+func (this *ServerMemberQuery) Count() (_ int, err error) {
+
+  defer observeOperation("ServerMember", "Query", time.Now(), &err)
+
+  count, err := this.query().Count()
+  if err != nil {
+    return 0, wrapDBError("ServerMember", "Query", "", "", err)
+  }
+  return count, nil
+}
+
+// query builds the mgo query from the accumulated filter and options.
+func (this *ServerMemberQuery) query() *mgo.Query {
+
+  query := ServerMemberCol().Find(notDeleted(this.filter))
+  if len(this.sort) > 0 {
+    query = query.Sort(this.sort...)
+  }
+  if this.skip > 0 {
+    query = query.Skip(this.skip)
+  }
+  if this.limit > 0 {
+    query = query.Limit(this.limit)
+  }
+  if this.projection != nil {
+    query = query.Select(this.projection)
+  }
+  return query
+}
@@ -0,0 +1,59 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "strings"
+  "sync"
+  "testing"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+func TestServerMemberUpdateWithVersionConcurrent(t *testing.T) {
+
+  injectServerMemberStore(t)
+  member := newTestServerMember("1")
+  if err := member.Create(context.Background()); err != nil {
+    t.Fatalf("Create: %v", err)
+  }
+
+  // Race two writers holding copies of the same version.
+  first, second := *member, *member
+  writers := []*ServerMember{&first, &second}
+  errs := make([]error, len(writers))
+  var wg sync.WaitGroup
+  for i, writer := range writers {
+    wg.Add(1)
+    go func(i int, writer *ServerMember) {
+      defer wg.Done()
+      writer.OwnerDiscordID = strings.Repeat(string(rune('2'+i)), 18)
+      errs[i] = writer.UpdateWithVersion(bson.M{"$set": bson.M{"owner_discord_id": writer.OwnerDiscordID}}, 1)
+    }(i, writer)
+  }
+  wg.Wait()
+
+  succeeded, conflicted := 0, 0
+  for i, err := range errs {
+    switch {
+    case err == nil:
+      succeeded++
+      if writers[i].Version != 2 {
+        t.Errorf("winner has version %d, want 2", writers[i].Version)
+      }
+    case errors.Is(err, ErrVersionConflict):
+      conflicted++
+      if !writers[i].UpdatedAt.Equal(member.UpdatedAt) || writers[i].Version != 1 {
+        t.Errorf("loser's UpdatedAt or Version changed although its update wasn't persisted")
+      }
+    default:
+      t.Errorf("UpdateWithVersion: %v", err)
+    }
+  }
+  if succeeded != 1 || conflicted != 1 {
+    t.Errorf("got %d successes and %d conflicts, want 1 of each", succeeded, conflicted)
+  }
+}
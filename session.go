@@ -0,0 +1,52 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// mgoDo runs op against the specified collection using a copy of the client's session bound to ctx.
+// The copy's socket timeout is capped at the context's deadline, so the database call aborts when the
+// deadline passes, and the context's error is returned then. mgoDo always waits for op to return, so a
+// nil error means the operation really was applied. A context which is cancelled without a deadline
+// can't interrupt an operation already in flight, so only stops operations which haven't started.
+func mgoDo(ctx context.Context, client, database, collection string, op func(col *mgo.Collection) error) error {
+  return mgoDoWithOptions(ctx, client, database, collection, nil, op)
+}
+
+// mgoDoWithOptions is mgoDo, with the session copy's settings adjusted by the options, such as
+// WithWriteConcern.
+func mgoDoWithOptions(ctx context.Context, client, database, collection string, opts []QueryOption, op func(col *mgo.Collection) error) error {
+
+  // Return early if the context is already done.
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+
+  // Copy the session so the timeout and options don't leak to other callers.
+  session := net.MgoGetSession(client).Copy()
+  defer session.Close()
+  if deadline, ok := ctx.Deadline(); ok {
+    timeout := time.Until(deadline)
+    if timeout <= 0 {
+      return context.DeadlineExceeded
+    }
+    session.SetSocketTimeout(timeout)
+  }
+  applyQueryOptions(opts).applyToSession(session)
+
+  // Run the operation, reporting a timeout caused by the deadline as the context's error.
+  err := op(session.DB(database).C(collection))
+  if err != nil && ctx.Err() != nil {
+    return ctx.Err()
+  }
+  return err
+}
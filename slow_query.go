@@ -0,0 +1,26 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/rs/zerolog/log"
+)
+
+// logSlowQuery starts timing a database operation, returning a func which logs a warning if the
+// operation took longer than Config.SlowQueryThreshold. It's meant to be deferred as
+// defer logSlowQuery(model, operation, filter)(). The filter is logged as JSON, and may be nil.
+func logSlowQuery(model, operation string, filter interface{}) func() {
+
+  start := time.Now()
+  return func() {
+    duration := time.Since(start)
+    if duration <= getConfig().SlowQueryThreshold {
+      return
+    }
+    log.Warn().Str("model", model).Str("operation", operation).Int64("duration_ms", duration.Milliseconds()).
+      Interface("filter", filter).Msgf("Slow %s %s", model, operation)
+  }
+}
@@ -0,0 +1,84 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+)
+
+// purgeStaleBatchSize is how many stale Servers PurgeStale deletes at a time.
+const purgeStaleBatchSize = 100
+
+// staleFilter matches documents which haven't been updated within maxAge.
+func staleFilter(maxAge time.Duration) bson.M {
+
+  return bson.M{"updated_at": bson.M{"$lt": clockNow().Add(-maxAge)}}
+}
+
+// FindStale finds the Servers which haven't been updated within maxAge, least recently updated first,
+// excluding soft-deleted ones. A limit of 0 means no limit.
+func FindStale(ctx context.Context, maxAge time.Duration, limit int) (_ []*Server, err error) {
+
+  defer observeOperation("Server", "FindStale", time.Now(), &err)
+
+  results := []*Server{}
+  err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(staleFilter(maxAge))).Sort("updated_at").Limit(limit).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Server", "FindStale", "", "", err)
+  }
+  return results, nil
+}
+
+// FindStaleServerMembers finds the ServerMembers which haven't been updated within maxAge, least
+// recently updated first, excluding soft-deleted ones. A limit of 0 means no limit.
+func FindStaleServerMembers(ctx context.Context, maxAge time.Duration, limit int) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindStale", time.Now(), &err)
+
+  results := []*ServerMember{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(staleFilter(maxAge))).Sort("updated_at").Limit(limit).All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("ServerMember", "FindStale", "", "", err)
+  }
+  return results, nil
+}
+
+// PurgeStale permanently deletes every Server which hasn't been updated within maxAge, soft-deleted or
+// not, along with its ServerMembers, in batches. Returns how many Servers were deleted. It stops at the
+// first failure, which is returned with the count so far.
+func PurgeStale(ctx context.Context, maxAge time.Duration) (purged int, err error) {
+
+  defer observeOperation("Server", "PurgeStale", time.Now(), &err)
+
+  // The cutoff is fixed up front, so servers going stale mid-purge are left for the next one.
+  filter := staleFilter(maxAge)
+  for {
+    batch := []*Server{}
+    err = mgoDo(ctx, ServerClientName, ServerDBName, ServerColName, func(col *mgo.Collection) error {
+      return col.Find(filter).Sort("updated_at").Limit(purgeStaleBatchSize).All(&batch)
+    })
+    if err != nil {
+      return purged, wrapDBError("Server", "PurgeStale", "", "", err)
+    }
+    if len(batch) == 0 {
+      log.Info().Int("purged", purged).Msg("Purged stale Servers")
+      return purged, nil
+    }
+    for _, server := range batch {
+      if err := server.DeleteCascade(ctx); err != nil {
+        return purged, err
+      }
+      purged++
+    }
+  }
+}
@@ -0,0 +1,860 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "fmt"
+  "reflect"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// ModelStore is the basic set of document operations on a collection. It's implemented by the database,
+// and by InMemoryModelStore so callers can be tested without MongoDB. Like mgo, FindOne, Update, and
+// Remove return mgo.ErrNotFound when there's no matching document.
+type ModelStore interface {
+  Insert(doc interface{}) error
+  // FindOne unmarshals the first document matching the filter into result.
+  FindOne(filter bson.M, result interface{}) error
+  // FindMany unmarshals every document matching the filter into result, which must be a pointer to a slice.
+  FindMany(filter bson.M, result interface{}) error
+  Update(id bson.ObjectId, update bson.M) error
+  Remove(id bson.ObjectId) error
+}
+
+// injectedStores are the stores set with SetModelStore, by collection name.
+var injectedStores sync.Map
+
+// SetModelStore injects the store for the collection, so its model's store accessor (e.g. ServerStore)
+// returns it instead of the database. This is meant for tests. A nil store removes the injected one.
+//
+// Server and ServerMember persist through their store in Create, Update and the methods built on it,
+// Delete, FindServers and FindServerMembers, and the CacheGet functions. ServerMember's atomic updates
+// (Upsert, Increment, CompareAndSwap, the secondary owner methods, MarkUpdatedNow, and
+// FindAndModifyServerMember), FindOrCreateServerMember, ReleaseAllOwnership, and the WithDeleted, Page,
+// and After finds do too. While a store is injected, those skip Redis entirely, so they can be tested
+// without MongoDB or Redis. Other functions, such as aggregations and bulk operations, still use the
+// database directly.
+func SetModelStore(collection string, store ModelStore) {
+
+  if store == nil {
+    injectedStores.Delete(collection)
+    return
+  }
+  injectedStores.Store(collection, store)
+}
+
+// modelStore returns the store injected for the collection, or one backed by the database which runs
+// its operations with ctx and the options.
+func modelStore(ctx context.Context, client, database, collection string, opts []QueryOption) ModelStore {
+
+  if store, ok := injectedStores.Load(collection); ok {
+    return store.(ModelStore)
+  }
+  return &mgoModelStore{client, database, collection, ctx, opts}
+}
+
+// cacheEnabled reports whether the collection's documents are cached in Redis, which they aren't while
+// a store is injected for it.
+func cacheEnabled(collection string) bool {
+
+  _, injected := injectedStores.Load(collection)
+  return !injected
+}
+
+// storeUpdate applies the update to the document matching the selector. The database and
+// InMemoryModelStore do so atomically. Other stores are checked for a match with FindOne first, and
+// then updated by _id.
+func storeUpdate(store ModelStore, selector, update bson.M) error {
+
+  if store, ok := store.(selectorUpdater); ok {
+    return store.updateWhere(selector, update)
+  }
+  matched := bson.M{}
+  if err := store.FindOne(selector, &matched); err != nil {
+    return err
+  }
+  id, _ := matched["_id"].(bson.ObjectId)
+  return store.Update(id, update)
+}
+
+// storeUpdateAll applies the update to every document matching the selector, returning how many were
+// updated. The database does so in one operation, and other stores update each match by _id.
+func storeUpdateAll(store ModelStore, selector, update bson.M) (int, error) {
+
+  if store, ok := store.(*mgoModelStore); ok {
+    var info *mgo.ChangeInfo
+    err := store.do(func(col *mgo.Collection) (err error) {
+      info, err = col.UpdateAll(selector, update)
+      return err
+    })
+    if err != nil {
+      return 0, err
+    }
+    return info.Updated, nil
+  }
+  matched := []bson.M{}
+  if err := store.FindMany(selector, &matched); err != nil {
+    return 0, err
+  }
+  updated := 0
+  for _, doc := range matched {
+    id, _ := doc["_id"].(bson.ObjectId)
+    if err := storeUpdate(store, bson.M{"$and": []interface{}{bson.M{"_id": id}, selector}}, update); err == mgo.ErrNotFound {
+      continue
+    } else if err != nil {
+      return updated, err
+    }
+    updated++
+  }
+  return updated, nil
+}
+
+// storeFindAndModify applies the change to the first document matching the selector, upserting one if
+// the change says so, and unmarshals the document into result as it was after the update if the change
+// returns the new document, or before it otherwise. It reports whether a document was upserted. The
+// database and InMemoryModelStore do so atomically, and other stores aren't supported.
+func storeFindAndModify(store ModelStore, selector bson.M, change mgo.Change, result interface{}) (bool, error) {
+
+  if store, ok := store.(findModifier); ok {
+    return store.findAndModify(selector, change, result)
+  }
+  return false, fmt.Errorf("%T doesn't support find-and-modify", store)
+}
+
+// storeCount returns how many documents match the filter.
+func storeCount(store ModelStore, filter bson.M) (int, error) {
+
+  if store, ok := store.(*mgoModelStore); ok {
+    count := 0
+    maxTime := applyQueryOptions(store.opts).maxQueryTime()
+    err := store.do(func(col *mgo.Collection) (err error) {
+      count, err = col.Find(filter).SetMaxTime(maxTime).Count()
+      return err
+    })
+    return count, err
+  }
+  matched := []bson.M{}
+  if err := store.FindMany(filter, &matched); err != nil {
+    return 0, err
+  }
+  return len(matched), nil
+}
+
+// storeFind unmarshals every document matching the filter into result, sorted by the field (prefixed
+// with "-" for descending order) unless it's empty, and limited unless the limit is 0. The database
+// sorts and limits in the query, honouring options like WithMaxTime, and other stores are sorted and
+// limited in memory.
+func storeFind(store ModelStore, filter bson.M, sort string, limit int, result interface{}) error {
+
+  return storeFindPage(store, filter, sort, 0, limit, result)
+}
+
+// storeFindPage is storeFind, skipping the first matches after sorting.
+func storeFindPage(store ModelStore, filter bson.M, sort string, skip, limit int, result interface{}) error {
+
+  if store, ok := store.(*mgoModelStore); ok {
+    maxTime := applyQueryOptions(store.opts).maxQueryTime()
+    return store.do(func(col *mgo.Collection) error {
+      query := col.Find(filter).SetMaxTime(maxTime)
+      if sort != "" {
+        query = query.Sort(sort)
+      }
+      if skip > 0 {
+        query = query.Skip(skip)
+      }
+      if limit > 0 {
+        query = query.Limit(limit)
+      }
+      return query.All(result)
+    })
+  }
+  if err := store.FindMany(filter, result); err != nil {
+    return err
+  }
+  if err := sortAndLimit(result, sort, 0); err != nil {
+    return err
+  }
+  slice := reflect.ValueOf(result).Elem()
+  if skip > slice.Len() {
+    skip = slice.Len()
+  }
+  slice.Set(slice.Slice(skip, slice.Len()))
+  return sortAndLimit(result, "", limit)
+}
+
+// selectorUpdater is implemented by stores which can atomically update the document matching a selector.
+type selectorUpdater interface {
+  updateWhere(selector, update bson.M) error
+}
+
+// findModifier is implemented by stores which can atomically find and modify a document, like mgo's
+// Query.Apply.
+type findModifier interface {
+  findAndModify(selector bson.M, change mgo.Change, result interface{}) (bool, error)
+}
+
+// mgoModelStore is a ModelStore backed by the database.
+type mgoModelStore struct {
+  client     string
+  database   string
+  collection string
+  ctx        context.Context
+  opts       []QueryOption
+}
+
+func (this *mgoModelStore) Insert(doc interface{}) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.Insert(doc)
+  })
+}
+
+func (this *mgoModelStore) FindOne(filter bson.M, result interface{}) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.Find(filter).One(result)
+  })
+}
+
+func (this *mgoModelStore) FindMany(filter bson.M, result interface{}) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.Find(filter).All(result)
+  })
+}
+
+func (this *mgoModelStore) Update(id bson.ObjectId, update bson.M) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.UpdateId(id, update)
+  })
+}
+
+func (this *mgoModelStore) Remove(id bson.ObjectId) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.RemoveId(id)
+  })
+}
+
+func (this *mgoModelStore) updateWhere(selector, update bson.M) error {
+  return this.do(func(col *mgo.Collection) error {
+    return col.Update(selector, update)
+  })
+}
+
+func (this *mgoModelStore) findAndModify(selector bson.M, change mgo.Change, result interface{}) (upserted bool, err error) {
+  err = this.do(func(col *mgo.Collection) error {
+    info, err := col.Find(selector).Apply(change, result)
+    upserted = info != nil && info.UpsertedId != nil
+    return err
+  })
+  return upserted, err
+}
+
+// do runs op against the store's collection with its context and options.
+func (this *mgoModelStore) do(op func(col *mgo.Collection) error) error {
+  return mgoDoWithOptions(this.ctx, this.client, this.database, this.collection, this.opts, op)
+}
+
+// InMemoryModelStore is a ModelStore which keeps documents in memory, for tests. Documents are stored
+// in their bson form, keyed by _id, which every document must have.
+//
+// Filters support equality on fields and dotted paths, $and, and the $in, $nin, $ne, $exists, $gt, $gte,
+// $lt, and $lte operators. Updates support $set, $setOnInsert, $unset, $inc, $push, $addToSet, and $pull,
+// or replace the document if they have no operators.
+type InMemoryModelStore struct {
+  docs sync.Map
+  // mu serializes writes to existing documents, so concurrent updates don't overwrite each other.
+  mu   sync.Mutex
+}
+
+// NewInMemoryModelStore creates an empty InMemoryModelStore.
+func NewInMemoryModelStore() *InMemoryModelStore {
+  return &InMemoryModelStore{}
+}
+
+// Insert stores the document, failing if one with the same _id already exists.
+func (this *InMemoryModelStore) Insert(doc interface{}) error {
+
+  stored, err := toBSONDoc(doc)
+  if err != nil {
+    return err
+  }
+  id, ok := stored["_id"].(bson.ObjectId)
+  if !ok {
+    return errors.New("can't insert document without an ObjectId _id")
+  }
+  if _, exists := this.docs.LoadOrStore(id, stored); exists {
+    return fmt.Errorf("can't insert duplicate _id %s", id.Hex())
+  }
+  return nil
+}
+
+// FindOne unmarshals the matching document with the lowest _id into result.
+func (this *InMemoryModelStore) FindOne(filter bson.M, result interface{}) error {
+
+  docs, err := this.find(filter)
+  if err != nil {
+    return err
+  }
+  if len(docs) == 0 {
+    return mgo.ErrNotFound
+  }
+  return fromBSONDoc(docs[0], result)
+}
+
+// FindMany unmarshals every matching document into result, ordered by _id.
+func (this *InMemoryModelStore) FindMany(filter bson.M, result interface{}) error {
+
+  docs, err := this.find(filter)
+  if err != nil {
+    return err
+  }
+  slice := reflect.ValueOf(result).Elem()
+  slice.Set(reflect.MakeSlice(slice.Type(), len(docs), len(docs)))
+  for i, doc := range docs {
+    elem := slice.Index(i)
+    if elem.Kind() == reflect.Ptr {
+      elem.Set(reflect.New(elem.Type().Elem()))
+    } else {
+      elem = elem.Addr()
+    }
+    if err := fromBSONDoc(doc, elem.Interface()); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Update applies the update to the document with the ID.
+func (this *InMemoryModelStore) Update(id bson.ObjectId, update bson.M) error {
+
+  return this.updateWhere(bson.M{"_id": id}, update)
+}
+
+// updateWhere applies the update to the matching document with the lowest _id. Matching and updating
+// happen under the lock, so a concurrent update can't change the document in between.
+func (this *InMemoryModelStore) updateWhere(selector, update bson.M) error {
+
+  this.mu.Lock()
+  defer this.mu.Unlock()
+
+  docs, err := this.find(selector)
+  if err != nil {
+    return err
+  }
+  if len(docs) == 0 {
+    return mgo.ErrNotFound
+  }
+  id := docs[0]["_id"].(bson.ObjectId)
+  updated, err := applyUpdate(docs[0], update)
+  if err != nil {
+    return err
+  }
+  updated["_id"] = id
+  this.docs.Store(id, updated)
+  return nil
+}
+
+// findAndModify applies the change to the matching document with the lowest _id, under the lock like
+// updateWhere. When nothing matches and the change upserts, it inserts a document made of the selector's
+// equality conditions with the update applied.
+func (this *InMemoryModelStore) findAndModify(selector bson.M, change mgo.Change, result interface{}) (bool, error) {
+
+  this.mu.Lock()
+  defer this.mu.Unlock()
+
+  update, ok := change.Update.(bson.M)
+  if !ok {
+    return false, fmt.Errorf("can't apply a %T update", change.Update)
+  }
+  docs, err := this.find(selector)
+  if err != nil {
+    return false, err
+  }
+
+  if len(docs) == 0 {
+    if !change.Upsert {
+      return false, mgo.ErrNotFound
+    }
+    base, err := toBSONDoc(selector)
+    if err != nil {
+      return false, err
+    }
+    for key, value := range base {
+      if operators, ok := value.(bson.M); strings.HasPrefix(key, "$") || ok && isOperatorDoc(operators) {
+        delete(base, key)
+      }
+    }
+    inserted, err := applyUpdate(base, upsertUpdate(update, true))
+    if err != nil {
+      return false, err
+    }
+    if _, ok := inserted["_id"].(bson.ObjectId); !ok {
+      inserted["_id"] = bson.NewObjectId()
+    }
+    this.docs.Store(inserted["_id"], inserted)
+    if !change.ReturnNew {
+      return true, nil
+    }
+    return true, fromBSONDoc(inserted, result)
+  }
+
+  // An update of only $setOnInsert fields leaves an existing document as it is.
+  id, updated := docs[0]["_id"].(bson.ObjectId), docs[0]
+  if update = upsertUpdate(update, false); len(update) > 0 {
+    if updated, err = applyUpdate(docs[0], update); err != nil {
+      return false, err
+    }
+    updated["_id"] = id
+    this.docs.Store(id, updated)
+  }
+  if change.ReturnNew {
+    return false, fromBSONDoc(updated, result)
+  }
+  return false, fromBSONDoc(docs[0], result)
+}
+
+// Remove removes the document with the ID.
+func (this *InMemoryModelStore) Remove(id bson.ObjectId) error {
+
+  this.mu.Lock()
+  defer this.mu.Unlock()
+
+  if _, ok := this.docs.Load(id); !ok {
+    return mgo.ErrNotFound
+  }
+  this.docs.Delete(id)
+  return nil
+}
+
+// find returns every document matching the filter, ordered by _id.
+func (this *InMemoryModelStore) find(filter bson.M) ([]bson.M, error) {
+
+  normalized, err := toBSONDoc(filter)
+  if err != nil {
+    return nil, err
+  }
+  docs := []bson.M{}
+  this.docs.Range(func(_, doc interface{}) bool {
+    var matched bool
+    if matched, err = matchesFilter(doc.(bson.M), normalized); matched {
+      docs = append(docs, doc.(bson.M))
+    }
+    return err == nil
+  })
+  if err != nil {
+    return nil, err
+  }
+  sort.Slice(docs, func(i, j int) bool {
+    return docs[i]["_id"].(bson.ObjectId) < docs[j]["_id"].(bson.ObjectId)
+  })
+  return docs, nil
+}
+
+// sortAndLimit sorts the slice pointed to by result by the bson field, prefixed with "-" for descending
+// order, then truncates it to the limit. An empty field leaves the order as it is, and a limit of 0
+// means no limit.
+func sortAndLimit(result interface{}, field string, limit int) error {
+
+  slice := reflect.ValueOf(result).Elem()
+  if field != "" {
+    descending := strings.HasPrefix(field, "-")
+    field = strings.TrimPrefix(field, "-")
+    values := make([]interface{}, slice.Len())
+    for i := range values {
+      doc, err := toBSONDoc(slice.Index(i).Interface())
+      if err != nil {
+        return err
+      }
+      values[i], _ = lookupPath(doc, field)
+    }
+
+    // Sort indices, then reorder the slice, so values stay paired with their elements.
+    order := make([]int, len(values))
+    for i := range order {
+      order[i] = i
+    }
+    sort.SliceStable(order, func(i, j int) bool {
+      if descending {
+        return compareValues(values[order[j]], values[order[i]]) < 0
+      }
+      return compareValues(values[order[i]], values[order[j]]) < 0
+    })
+    sorted := reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+    for i, from := range order {
+      sorted.Index(i).Set(slice.Index(from))
+    }
+    slice.Set(sorted)
+  }
+
+  if limit > 0 && slice.Len() > limit {
+    slice.Set(slice.Slice(0, limit))
+  }
+  return nil
+}
+
+// compareValues orders stored values for sortAndLimit: missing values first, then numbers, strings,
+// ObjectIds, booleans, and times, each in their natural order. Other values compare as equal.
+func compareValues(a, b interface{}) int {
+
+  if rankA, rankB := valueRank(a), valueRank(b); rankA != rankB {
+    return rankA - rankB
+  }
+
+  less, greater := false, false
+  switch a := a.(type) {
+  case int, int64, float64:
+    x, y := toFloat(a), toFloat(b)
+    less, greater = x < y, x > y
+  case string:
+    less, greater = a < b.(string), a > b.(string)
+  case bson.ObjectId:
+    less, greater = a < b.(bson.ObjectId), a > b.(bson.ObjectId)
+  case bool:
+    less, greater = !a && b.(bool), a && !b.(bool)
+  case time.Time:
+    less, greater = a.Before(b.(time.Time)), a.After(b.(time.Time))
+  }
+  switch {
+  case less:
+    return -1
+  case greater:
+    return 1
+  default:
+    return 0
+  }
+}
+
+// valueRank orders the types of stored values for compareValues.
+func valueRank(v interface{}) int {
+
+  switch v.(type) {
+  case nil:
+    return 0
+  case int, int64, float64:
+    return 1
+  case string:
+    return 2
+  case bson.ObjectId:
+    return 3
+  case bool:
+    return 4
+  case time.Time:
+    return 5
+  default:
+    return 6
+  }
+}
+
+// toFloat converts a stored number to a float64.
+func toFloat(v interface{}) float64 {
+
+  switch v := v.(type) {
+  case int:
+    return float64(v)
+  case int64:
+    return float64(v)
+  case float64:
+    return v
+  default:
+    return 0
+  }
+}
+
+// toBSONDoc converts a document into its bson form, so values compare the way they're stored.
+func toBSONDoc(doc interface{}) (bson.M, error) {
+
+  raw, err := bson.Marshal(doc)
+  if err != nil {
+    return nil, err
+  }
+  out := bson.M{}
+  return out, bson.Unmarshal(raw, out)
+}
+
+// fromBSONDoc unmarshals a stored document into result.
+func fromBSONDoc(doc bson.M, result interface{}) error {
+
+  raw, err := bson.Marshal(doc)
+  if err != nil {
+    return err
+  }
+  return bson.Unmarshal(raw, result)
+}
+
+// lookupPath gets the value at the dotted path within the document.
+func lookupPath(doc bson.M, path string) (interface{}, bool) {
+
+  var value interface{} = doc
+  for _, part := range strings.Split(path, ".") {
+    nested, ok := value.(bson.M)
+    if !ok {
+      return nil, false
+    }
+    if value, ok = nested[part]; !ok {
+      return nil, false
+    }
+  }
+  return value, true
+}
+
+// matchesFilter reports whether the document matches every condition in the filter.
+func matchesFilter(doc, filter bson.M) (bool, error) {
+
+  for path, want := range filter {
+    if path == "$and" {
+      clauses, ok := want.([]interface{})
+      if !ok {
+        return false, errors.New("$and needs an array")
+      }
+      for _, clause := range clauses {
+        clause, ok := clause.(bson.M)
+        if !ok {
+          return false, errors.New("$and needs documents")
+        }
+        if matched, err := matchesFilter(doc, clause); err != nil || !matched {
+          return false, err
+        }
+      }
+      continue
+    }
+    got, exists := lookupPath(doc, path)
+    operators, ok := want.(bson.M)
+    if !ok || !isOperatorDoc(operators) {
+      if !valuesEqual(got, want) {
+        return false, nil
+      }
+      continue
+    }
+    for operator, operand := range operators {
+      matched, err := matchesOperator(got, exists, operator, operand)
+      if err != nil || !matched {
+        return false, err
+      }
+    }
+  }
+  return true, nil
+}
+
+// matchesOperator reports whether a value matches a single query operator.
+func matchesOperator(got interface{}, exists bool, operator string, operand interface{}) (bool, error) {
+
+  switch operator {
+  case "$ne":
+    return !valuesEqual(got, operand), nil
+  case "$exists":
+    want, _ := operand.(bool)
+    return exists == want, nil
+  case "$in", "$nin":
+    candidates, ok := operand.([]interface{})
+    if !ok {
+      return false, fmt.Errorf("%s needs an array", operator)
+    }
+    found := false
+    for _, candidate := range candidates {
+      if valuesEqual(got, candidate) {
+        found = true
+        break
+      }
+    }
+    return found == (operator == "$in"), nil
+  case "$gt", "$gte", "$lt", "$lte":
+    // Like MongoDB, only values of the same type compare.
+    if !exists || valueRank(got) != valueRank(operand) {
+      return false, nil
+    }
+    cmp := compareValues(got, operand)
+    switch operator {
+    case "$gt":
+      return cmp > 0, nil
+    case "$gte":
+      return cmp >= 0, nil
+    case "$lt":
+      return cmp < 0, nil
+    default:
+      return cmp <= 0, nil
+    }
+  default:
+    return false, fmt.Errorf("unsupported query operator %s", operator)
+  }
+}
+
+// isOperatorDoc reports whether the document's keys are operators, like {$in: [...]}.
+func isOperatorDoc(doc bson.M) bool {
+
+  for key := range doc {
+    return strings.HasPrefix(key, "$")
+  }
+  return false
+}
+
+// valuesEqual compares stored values the way a query does: missing and nil are equal, and a value
+// matches an array containing it.
+func valuesEqual(got, want interface{}) bool {
+
+  if reflect.DeepEqual(got, want) {
+    return true
+  }
+  if items, ok := got.([]interface{}); ok {
+    for _, item := range items {
+      if reflect.DeepEqual(item, want) {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+// applyUpdate returns the document with the update applied.
+func applyUpdate(doc, update bson.M) (bson.M, error) {
+
+  normalized, err := toBSONDoc(update)
+  if err != nil {
+    return nil, err
+  }
+  if !isOperatorDoc(normalized) {
+    return normalized, nil
+  }
+
+  // Work on a copy, so a failed update leaves the stored document untouched.
+  out, err := toBSONDoc(doc)
+  if err != nil {
+    return nil, err
+  }
+  for operator, fields := range normalized {
+    fields, ok := fields.(bson.M)
+    if !ok {
+      return nil, fmt.Errorf("%s needs a document", operator)
+    }
+    for path, value := range fields {
+      parent, key, err := updateParent(out, path)
+      if err != nil {
+        return nil, fmt.Errorf("can't %s %s: %s", operator, path, err.Error())
+      }
+      switch operator {
+      case "$set":
+        parent[key] = value
+      case "$unset":
+        delete(parent, key)
+      case "$inc":
+        sum, err := addNumbers(parent[key], value)
+        if err != nil {
+          return nil, fmt.Errorf("can't $inc %s: %s", path, err.Error())
+        }
+        parent[key] = sum
+      case "$push", "$addToSet", "$pull":
+        items, ok := parent[key].([]interface{})
+        if !ok && parent[key] != nil {
+          return nil, fmt.Errorf("can't %s %s: it isn't an array", operator, path)
+        }
+        parent[key] = modifyArray(operator, items, value)
+      default:
+        return nil, fmt.Errorf("unsupported update operator %s", operator)
+      }
+    }
+  }
+  return out, nil
+}
+
+// upsertUpdate returns the update without its $setOnInsert fields, or with them merged into $set when
+// it's inserting a document.
+func upsertUpdate(update bson.M, inserting bool) bson.M {
+
+  onInsert, ok := update["$setOnInsert"].(bson.M)
+  if !ok {
+    return update
+  }
+  out := bson.M{}
+  for operator, fields := range update {
+    if operator != "$setOnInsert" {
+      out[operator] = fields
+    }
+  }
+  if inserting {
+    set := bson.M{}
+    if fields, ok := update["$set"].(bson.M); ok {
+      for path, value := range fields {
+        set[path] = value
+      }
+    }
+    for path, value := range onInsert {
+      set[path] = value
+    }
+    out["$set"] = set
+  }
+  return out
+}
+
+// modifyArray returns the items with the array update operator applied with the value.
+func modifyArray(operator string, items []interface{}, value interface{}) []interface{} {
+
+  modified := append([]interface{}{}, items...)
+  switch operator {
+  case "$pull":
+    kept := []interface{}{}
+    for _, item := range modified {
+      if !reflect.DeepEqual(item, value) {
+        kept = append(kept, item)
+      }
+    }
+    return kept
+  case "$addToSet":
+    for _, item := range modified {
+      if reflect.DeepEqual(item, value) {
+        return modified
+      }
+    }
+  }
+  return append(modified, value)
+}
+
+// updateParent returns the document holding the dotted path's last key, creating missing documents
+// on the way, along with that key. Paths into arrays, like "sec_owner_discord_ids.0", or through other
+// values which aren't documents, aren't supported, so they fail rather than replace the value.
+func updateParent(doc bson.M, path string) (bson.M, string, error) {
+
+  parts := strings.Split(path, ".")
+  for i, part := range parts[:len(parts)-1] {
+    if doc[part] == nil {
+      doc[part] = bson.M{}
+    }
+    nested, ok := doc[part].(bson.M)
+    if !ok {
+      return nil, "", fmt.Errorf("%s isn't a document", strings.Join(parts[:i+1], "."))
+    }
+    doc = nested
+  }
+  return doc, parts[len(parts)-1], nil
+}
+
+// addNumbers adds two stored numbers, keeping integers as integers. A missing value counts as 0.
+func addNumbers(a, b interface{}) (interface{}, error) {
+
+  if a == nil {
+    return b, nil
+  }
+  switch a := a.(type) {
+  case int:
+    if b, ok := b.(int); ok {
+      return a + b, nil
+    }
+  case int64:
+    switch b := b.(type) {
+    case int:
+      return a + int64(b), nil
+    case int64:
+      return a + b, nil
+    }
+  case float64:
+    if b, ok := b.(float64); ok {
+      return a + b, nil
+    }
+  }
+  return nil, fmt.Errorf("can't add %T and %T", a, b)
+}
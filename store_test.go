@@ -0,0 +1,185 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "strings"
+  "sync"
+  "testing"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+)
+
+// injectServerMemberStore injects an empty InMemoryModelStore for ServerMember for the rest of the test.
+func injectServerMemberStore(t *testing.T) *InMemoryModelStore {
+
+  store := NewInMemoryModelStore()
+  SetModelStore(ServerMemberColName, store)
+  t.Cleanup(func() { SetModelStore(ServerMemberColName, nil) })
+  return store
+}
+
+// newTestServerMember builds a valid ServerMember with snowflakes made of the digit.
+func newTestServerMember(digit string) *ServerMember {
+
+  return NewServerMemberWithDefaults(strings.Repeat(digit, 18), strings.Repeat("9", 18), strings.Repeat(digit, 17))
+}
+
+func TestInjectedStoreServerMemberLifecycle(t *testing.T) {
+
+  store := injectServerMemberStore(t)
+  ctx := context.Background()
+
+  // Create goes to the injected store.
+  member := newTestServerMember("1")
+  if err := member.Create(ctx); err != nil {
+    t.Fatalf("Create: %v", err)
+  }
+  stored := new(ServerMember)
+  if err := store.FindOne(bson.M{"_id": member.ID}, stored); err != nil {
+    t.Fatalf("created ServerMember isn't in the injected store: %v", err)
+  }
+
+  // Update and CacheGet see the same document, without Redis.
+  member.OwnerDiscordID = strings.Repeat("2", 18)
+  if err := member.Update(ctx, bson.M{"$set": bson.M{"owner_discord_id": member.OwnerDiscordID}}); err != nil {
+    t.Fatalf("Update: %v", err)
+  }
+  found, err := CacheGetServerMember("_id", member.ID.Hex(), true)
+  if err != nil {
+    t.Fatalf("CacheGetServerMember: %v", err)
+  }
+  if found.OwnerDiscordID != member.OwnerDiscordID || found.Version != 2 {
+    t.Errorf("got owner %q version %d, want %q version 2", found.OwnerDiscordID, found.Version, member.OwnerDiscordID)
+  }
+
+  // Find sorts and limits in memory.
+  other := newTestServerMember("3")
+  if err := other.Create(ctx); err != nil {
+    t.Fatalf("Create: %v", err)
+  }
+  results, err := FindServerMembers(ctx, bson.M{"discord_server_id": member.DiscordServerID}, "-discord_member_id", 1)
+  if err != nil {
+    t.Fatalf("FindServerMembers: %v", err)
+  }
+  if len(results) != 1 || results[0].ID != other.ID {
+    t.Errorf("got %d results, want only the ServerMember with the highest member ID", len(results))
+  }
+
+  // Delete removes it from the injected store.
+  if err := member.Delete(ctx); err != nil {
+    t.Fatalf("Delete: %v", err)
+  }
+  if _, err := CacheGetServerMember("_id", member.ID.Hex(), true); !errors.Is(err, mgo.ErrNotFound) {
+    t.Errorf("got %v after Delete, want not found", err)
+  }
+}
+
+func TestInjectedStoreServerMemberAtomicOperations(t *testing.T) {
+
+  injectServerMemberStore(t)
+  ctx := context.Background()
+
+  // FindOrCreate inserts once, then finds the same document.
+  member, created, err := FindOrCreateServerMember(strings.Repeat("1", 18), strings.Repeat("9", 18), strings.Repeat("1", 17))
+  if err != nil || !created {
+    t.Fatalf("FindOrCreateServerMember: got created %v, %v", created, err)
+  }
+  again, created, err := FindOrCreateServerMember(strings.Repeat("1", 18), strings.Repeat("9", 18), strings.Repeat("1", 17))
+  if err != nil || created || again.ID != member.ID {
+    t.Errorf("got created %v, %v finding it again, want the same ServerMember", created, err)
+  }
+
+  // Upsert updates the existing document, taking its ID and version.
+  upserted := newTestServerMember("1")
+  if err := upserted.Upsert(); err != nil {
+    t.Fatalf("Upsert: %v", err)
+  }
+  if upserted.ID != member.ID || upserted.Version != 2 {
+    t.Errorf("got ID %s version %d, want %s version 2", upserted.ID.Hex(), upserted.Version, member.ID.Hex())
+  }
+
+  // Secondary owners are modified in the store, and ownership released from it.
+  ownerID := strings.Repeat("2", 18)
+  if swapped, err := upserted.CompareAndSwap(ctx, "owner_discord_id", "", ownerID); err != nil || !swapped {
+    t.Fatalf("CompareAndSwap: got swapped %v, %v", swapped, err)
+  }
+  if err := upserted.AddSecOwner(ownerID); err != nil {
+    t.Fatalf("AddSecOwner: %v", err)
+  }
+  if err := upserted.AddSecOwner(ownerID); err != nil {
+    t.Fatalf("AddSecOwner: %v", err)
+  }
+  if len(upserted.SecOwnerDiscordIDs) != 1 {
+    t.Errorf("got secondary owners %v, want %s once", upserted.SecOwnerDiscordIDs, ownerID)
+  }
+  if released, err := ReleaseAllOwnership(ownerID); err != nil || released != 1 {
+    t.Errorf("ReleaseAllOwnership: got %d, %v, want 1 released", released, err)
+  }
+
+  // Pages and cursors read from the store.
+  other := newTestServerMember("3")
+  if err := other.Create(ctx); err != nil {
+    t.Fatalf("Create: %v", err)
+  }
+  page, total, err := FindServerMembersPage(bson.M{}, "-discord_member_id", 2, 1)
+  if err != nil || total != 2 || len(page) != 1 || page[0].ID != member.ID {
+    t.Errorf("FindServerMembersPage: got %d of %d, %v, want the second ServerMember of 2", len(page), total, err)
+  }
+  after, err := FindServerMembersAfter(member.ID, 0)
+  if err != nil || len(after) != 1 || after[0].ID != other.ID {
+    t.Errorf("FindServerMembersAfter: got %d, %v, want only the later ServerMember", len(after), err)
+  }
+}
+
+func TestInMemoryModelStoreConcurrentUpdates(t *testing.T) {
+
+  store := NewInMemoryModelStore()
+  id := bson.NewObjectId()
+  if err := store.Insert(bson.M{"_id": id, "count": 0}); err != nil {
+    t.Fatalf("Insert: %v", err)
+  }
+
+  const writers = 50
+  var wg sync.WaitGroup
+  for i := 0; i < writers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      if err := store.Update(id, bson.M{"$inc": bson.M{"count": 1}}); err != nil {
+        t.Errorf("Update: %v", err)
+      }
+    }()
+  }
+  wg.Wait()
+
+  stored := bson.M{}
+  if err := store.FindOne(bson.M{"_id": id}, &stored); err != nil {
+    t.Fatalf("FindOne: %v", err)
+  }
+  if stored["count"] != writers {
+    t.Errorf("got count %v, want %d", stored["count"], writers)
+  }
+}
+
+func TestApplyUpdateRejectsArrayIndexPaths(t *testing.T) {
+
+  member := newTestServerMember("1")
+  member.SecOwnerDiscordIDs = []string{strings.Repeat("2", 18)}
+  doc, err := toBSONDoc(member)
+  if err != nil {
+    t.Fatalf("toBSONDoc: %v", err)
+  }
+
+  // The array is left as it is, rather than replaced with a document keyed "0".
+  if _, err := applyUpdate(doc, bson.M{"$set": bson.M{"sec_owner_discord_ids.0": strings.Repeat("3", 18)}}); err == nil {
+    t.Errorf("got no error setting an array index")
+  }
+  if _, ok := doc["sec_owner_discord_ids"].([]interface{}); !ok {
+    t.Errorf("got sec_owner_discord_ids %v, want the array untouched", doc["sec_owner_discord_ids"])
+  }
+}
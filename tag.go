@@ -0,0 +1,730 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "regexp"
+  "strings"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// TagClientName is the name of the MgoDriver to use for Tag.
+const TagClientName = "main"
+
+// TagDBName is the name of the database to use for Tag.
+const TagDBName = "badpetbot"
+
+// TagColName is the name of the collection to use for Tag.
+const TagColName = "tags"
+
+// TagModelVersion is the current schema version of Tag documents, stored in their ModelVersion.
+const TagModelVersion = 1
+
+// tagNamePattern is what a Tag's Name must match.
+var tagNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// TagCol gets a collection reference for Tag.
+func TagCol() *mgo.Collection {
+  return net.MgoCol(TagClientName, TagDBName, TagColName)
+}
+
+// Register Tag, so tooling can find it.
+func init() {
+  RegisterModel("Tag", TagCol, func() interface{} { return new(Tag) })
+}
+
+// EnsureTagIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureTagIndices() error {
+
+  col := TagCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"discord_server_id", "name"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("Tag", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// EnsureTagTextIndex creates the text index on tags' names and content, which TextSearch needs. It is
+// idempotent.
+func EnsureTagTextIndex() error {
+
+  col := TagCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"$text:name", "$text:content"}, Background: true}); err != nil {
+    return wrapDBError("Tag", "EnsureTextIndex", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { discord_server_id: 1, name: 1 } unique
+// { name: "text", content: "text" }
+
+// Tag is a named text snippet saved by a member, which anyone in the server can retrieve by its name.
+type Tag struct {
+  // ID is a BSON ID generated in Create.
+  ID                bson.ObjectId   `bson:"_id"                   json:"_id"                   validate:"required"`
+  DiscordServerID   string          `bson:"discord_server_id"     json:"discord_server_id"     validate:"required,discord_id"`
+  // Name is unique per server. It must match tagNamePattern.
+  Name              string          `bson:"name"                  json:"name"                  validate:"required"`
+  Content           string          `bson:"content"               json:"content"               validate:"max=2000"`
+  CreatedByMemberID bson.ObjectId   `bson:"created_by_member_id"  json:"created_by_member_id"  validate:"required"`
+  UseCount          int64           `bson:"use_count"             json:"use_count"             validate:"-"`
+  CreatedAt         time.Time       `bson:"created_at"            json:"created_at"            validate:"required"`
+  UpdatedAt         time.Time       `bson:"updated_at"            json:"updated_at"            validate:"required"`
+  DeletedAt         *time.Time      `bson:"deleted_at"            json:"deleted_at"            validate:"-"`
+  Version           int             `bson:"version"               json:"version"               validate:"-"`
+  ModelVersion      int             `bson:"model_version"         json:"model_version"         validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *Tag) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Tag.Create", TagDBName, TagColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Tag", "Create", time.Now(), &err)
+  defer logSlowQuery("Tag", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = TagModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the Tag.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, TagClientName, TagDBName, TagColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("Tag", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *Tag) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *Tag) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *Tag) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Tag.Update", TagDBName, TagColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Tag", "Update", time.Now(), &err)
+  defer logSlowQuery("Tag", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, TagClientName, TagDBName, TagColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheTag(net.RedisGetClient(TagClientName), this.cacheKeys())
+  }
+  return wrapDBError("Tag", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *Tag) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "Tag.Delete", TagDBName, TagColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Tag", "Delete", time.Now(), &err)
+  defer logSlowQuery("Tag", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the Tag.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, TagClientName, TagDBName, TagColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheTag(net.RedisGetClient(TagClientName), this.cacheKeys())
+  }
+  return wrapDBError("Tag", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// IncrementUseCount atomically increments the tag's use count by one.
+func (this *Tag) IncrementUseCount() (err error) {
+
+  defer observeOperation("Tag", "IncrementUseCount", time.Now(), &err)
+
+  // Persist the increment.
+  err = mgoDo(context.Background(), TagClientName, TagDBName, TagColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$inc": bson.M{"use_count": 1}})
+  })
+
+  // Note the new count, and evict stale cache entries.
+  if err == nil {
+    this.UseCount++
+    go invalidateCacheTag(net.RedisGetClient(TagClientName), this.cacheKeys())
+  }
+  return wrapDBError("Tag", "IncrementUseCount", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *Tag) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *Tag) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *Tag) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("Tag", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, TagClientName, TagDBName, TagColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("Tag", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheTag(net.RedisGetClient(TagClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *Tag) Validate(ctx context.Context) error {
+
+  // Run the tag validations, then the rules tags can't express, collecting every failure.
+  err := wrapValidationError("Tag", newValidator().StructCtx(ctx, this))
+  invalid := &ModelValidationError{Model: "Tag"}
+  if !errors.As(err, &invalid) && err != nil {
+    return err
+  }
+  if this.Name != "" && !tagNamePattern.MatchString(this.Name) {
+    invalid.Errors = append(invalid.Errors, newValidationFieldError("Name", "name", "pattern", "", this.Name))
+  }
+  if len(invalid.Errors) > 0 {
+    return invalid
+  }
+  return nil
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set. The name pattern is
+// checked if Name is given.
+func (this *Tag) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("Tag", this, fields)
+  if err != nil {
+    return err
+  }
+  err = wrapValidationError("Tag", newValidator().StructPartialCtx(context.Background(), this, names...))
+  invalid := &ModelValidationError{Model: "Tag"}
+  if !errors.As(err, &invalid) && err != nil {
+    return err
+  }
+  for _, name := range names {
+    if name == "Name" && this.Name != "" && !tagNamePattern.MatchString(this.Name) {
+      invalid.Errors = append(invalid.Errors, newValidationFieldError("Name", "name", "pattern", "", this.Name))
+    }
+  }
+  if len(invalid.Errors) > 0 {
+    return invalid
+  }
+  return nil
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *Tag) Clone() *Tag {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*Tag)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *Tag) Diff(other *Tag) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *Tag) DiffDeep(other *Tag) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "use_count".
+func (this *Tag) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *Tag) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *Tag) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *Tag) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *Tag) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *Tag) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the Tag is uninitialized, having neither an ID nor a Name, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *Tag) IsZero() bool {
+  return this.ID == "" && this.Name == ""
+}
+
+// Age returns how long ago the Tag was created.
+func (this *Tag) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the Tag was last updated.
+func (this *Tag) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the Tag hasn't been updated for longer than the threshold.
+func (this *Tag) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetTag attempts to find the server's Tag with the given name in cache before
+// looking in the database and setting cache if found. Tags are cached by the composite key
+// discord_server_id:name. If "negCache" is true, will check for neg-cache first, and also set
+// neg-cache if the document wasn't found in the database either.
+func CacheGetTag(serverID, name string, negCache bool) (found *Tag, err error) {
+
+  client := net.RedisGetClient(TagClientName)
+  cacheKey := tagCacheKey(serverID, name)
+  _, span := startCacheSpan(context.Background(), "Tag.CacheGet", TagDBName, TagColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Tag", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("Tag", "CacheGet", "name", name, err)
+    } else if result != "" {
+      recordCacheResult("Tag", "discord_server_id:name", "neg_hit")
+      return nil, &ModelNotFoundError{Model: "Tag", Key: "name", Value: name}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Tag", "CacheGet", "name", name, err)
+  } else if result != "" {
+    recordCacheResult("Tag", "discord_server_id:name", "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    tag := new(Tag)
+    if err := json.Unmarshal([]byte(result), tag); err != nil {
+      return nil, wrapDBError("Tag", "CacheGet", "name", name, err)
+    }
+    return tag, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("Tag", "discord_server_id:name", "miss")
+  tag := new(Tag)
+  err = net.MgoCol(TagClientName, TagDBName, TagColName).Find(notDeleted(bson.M{
+    "discord_server_id": serverID,
+    "name":              name,
+  })).One(tag)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheTag(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheTag(client, cacheKey, tag)
+  }
+  if err != nil {
+    return nil, wrapDBError("Tag", "CacheGet", "name", name, err)
+  }
+  return tag, nil
+}
+
+// tagCacheKey builds the composite cache key for the server's tag with the given name.
+func tagCacheKey(serverID, name string) string {
+  return TagClientName+":"+TagDBName+":"+TagColName+":discord_server_id:name:"+serverID+":"+name
+}
+
+func fillCacheTag(client *redis.Client, key string, value *Tag) {
+  _, span := startCacheSpan(context.Background(), "Tag.fillCache", TagDBName, TagColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for Tag")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for Tag")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheTag(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "Tag.fillNegCache", TagDBName, TagColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for Tag")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheTag(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for Tag")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetTag.
+func (this *Tag) cacheKeys() []string {
+  return []string{
+    tagCacheKey(this.DiscordServerID, this.Name),
+  }
+}
+
+// Misc functions.
+
+// AggregateTags runs the aggregation pipeline on the Tag collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateTags(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Tag", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(TagClientName, TagDBName, TagColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Tag", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateTagOne runs the aggregation pipeline on the Tag collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateTagOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Tag", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(TagClientName, TagDBName, TagColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Tag", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyTag atomically applies the update to the first Tag matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyTag(selector, update bson.M, returnNew bool) (_ *Tag, err error) {
+
+  defer observeOperation("Tag", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(Tag)
+  _, err = TagCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("Tag", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheTag(net.RedisGetClient(TagClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindTags finds all Tags matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindTags(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*Tag, err error) {
+
+  defer observeOperation("Tag", "Find", time.Now(), &err)
+  defer logSlowQuery("Tag", "Find", filter)()
+
+  results := []*Tag{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, TagClientName, TagDBName, TagColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Tag", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindTagsWithDeleted finds all Tags matching the filter, including soft-deleted ones.
+func FindTagsWithDeleted(filter bson.M) (_ []*Tag, err error) {
+
+  defer observeOperation("Tag", "Find", time.Now(), &err)
+
+  results := []*Tag{}
+  if err := net.MgoCol(TagClientName, TagDBName, TagColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("Tag", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountTags counts the Tags matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountTags(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("Tag", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(TagClientName, TagDBName, TagColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("Tag", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// TagExists reports whether any Tag matches the filter, excluding soft-deleted ones.
+func TagExists(filter bson.M) (bool, error) {
+
+  count, err := CountTags(filter)
+  return count > 0, err
+}
+
+// DistinctTagFields finds the distinct values of the field among the Tags matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctTagFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("Tag", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, TagClientName, TagDBName, TagColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("Tag", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctTagStringFields is DistinctTagFields for string fields, like name. It fails if
+// any of the values isn't a string.
+func DistinctTagStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctTagFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactTagCollection runs the compact command against the Tag collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactTagCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "Tag", TagClientName, TagDBName, TagColName)
+}
+
+// TagCollectionStats returns the Tag collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func TagCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "Tag", TagClientName, TagDBName, TagColName)
+}
+
+// ExplainTagQuery returns MongoDB's explain output for finding the filter in the Tag collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainTagQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "Tag", TagClientName, TagDBName, TagColName, filter)
+}
+
+// UpdateManyTags applies the updates to every Tag matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateTags for that. The Tag cache is flushed.
+func UpdateManyTags(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "Tag", TagClientName, TagDBName, TagColName, filter, updates)
+}
+
+// BulkUpdateTags is UpdateManyTags, but validates each Tag as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateTags(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "Tag", TagClientName, TagDBName, TagColName, filter, updates, func() validatable { return new(Tag) })
+}
+
+// DeleteManyTags soft-deletes every Tag matching the filter in a single query, returning how many
+// were deleted. The Tag cache is evicted.
+func DeleteManyTags(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "Tag", TagClientName, TagDBName, TagColName, filter)
+}
+
+// FindTagByName finds the server's tag with the given name, using the cache.
+func FindTagByName(serverID, name string) (*Tag, error) {
+
+  return CacheGetTag(serverID, strings.ToLower(name), true)
+}
+
+// SearchTags finds the server's tags whose names contain the query, case-insensitively, sorted by name.
+// A limit of 0 means no limit.
+func SearchTags(serverID, query string, limit int) ([]*Tag, error) {
+
+  return FindTags(context.Background(), bson.M{
+    "discord_server_id": serverID,
+    "name":              bson.RegEx{Pattern: regexp.QuoteMeta(query), Options: "i"},
+  }, "name", limit)
+}
+
+// TextSearch finds the server's tags matching the query in their names or content, using the text
+// index created by EnsureTagTextIndex, most relevant first. A limit of 0 means no limit. This does not
+// touch the cache.
+func TextSearch(ctx context.Context, serverID, query string, limit int) (_ []*Tag, err error) {
+
+  defer observeOperation("Tag", "TextSearch", time.Now(), &err)
+
+  filter := bson.M{"discord_server_id": serverID, "$text": bson.M{"$search": query}}
+  results := []*Tag{}
+  err = mgoDo(ctx, TagClientName, TagDBName, TagColName, func(col *mgo.Collection) error {
+    return col.Find(notDeleted(filter)).
+      Select(bson.M{"score": bson.M{"$meta": "textScore"}}).
+      Sort("$textScore:score").
+      Limit(limit).
+      All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Tag", "TextSearch", "", "", err)
+  }
+  return results, nil
+}
+
+// FindTagsByCreator finds every tag created by the ServerMember, newest first.
+func FindTagsByCreator(creatorID bson.ObjectId) ([]*Tag, error) {
+
+  return FindTags(context.Background(), bson.M{"created_by_member_id": creatorID}, "-created_at", 0)
+}
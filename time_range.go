@@ -0,0 +1,54 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// TimeRangeOptions adjusts the results of time range queries like FindCreatedBetween. A nil
+// TimeRangeOptions uses the defaults.
+type TimeRangeOptions struct {
+  // Sort is the field to sort by, prefixed with "-" for descending order, as in mgo. Defaults to the
+  // queried timestamp, ascending.
+  Sort  string
+  // Limit is the maximum number of results. 0 means no limit.
+  Limit int
+}
+
+// FindCreatedBetween finds the ServerMembers created between from and to, inclusive, excluding
+// soft-deleted ones. This does not touch the cache.
+func FindCreatedBetween(ctx context.Context, from, to time.Time, opts *TimeRangeOptions) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindCreatedBetween", time.Now(), &err)
+
+  return findServerMembersBetween(ctx, "FindCreatedBetween", "created_at", from, to, opts)
+}
+
+// FindUpdatedBetween finds the ServerMembers last updated between from and to, inclusive, excluding
+// soft-deleted ones. This does not touch the cache.
+func FindUpdatedBetween(ctx context.Context, from, to time.Time, opts *TimeRangeOptions) (_ []*ServerMember, err error) {
+
+  defer observeOperation("ServerMember", "FindUpdatedBetween", time.Now(), &err)
+
+  return findServerMembersBetween(ctx, "FindUpdatedBetween", "updated_at", from, to, opts)
+}
+
+// findServerMembersBetween finds the ServerMembers whose timestamp field is between from and to.
+func findServerMembersBetween(ctx context.Context, op, field string, from, to time.Time, opts *TimeRangeOptions) ([]*ServerMember, error) {
+
+  sort, limit := field, 0
+  if opts != nil {
+    if opts.Sort != "" {
+      sort = opts.Sort
+    }
+    limit = opts.Limit
+  }
+
+  filter := bson.M{field: bson.M{"$gte": from, "$lte": to}}
+  return findServerMembers(ctx, op, filter, sort, limit)
+}
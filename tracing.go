@@ -0,0 +1,53 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+
+  // Import 3rd party packages.
+  "go.opentelemetry.io/otel"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope spans are started under.
+const tracerName = "github.com/badpetbot/gomodel"
+
+// otelEnabled gates all tracing. It is toggled via Configure and guarded by configMu.
+var otelEnabled bool
+
+// startSpan starts a child span of ctx for a database operation on the given database and collection.
+// If tracing is disabled, ctx is returned untouched along with a no-op span.
+func startSpan(ctx context.Context, name, database, collection string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+
+  configMu.RLock()
+  enabled := otelEnabled
+  configMu.RUnlock()
+  if !enabled {
+    return ctx, trace.SpanFromContext(context.Background())
+  }
+
+  attrs = append([]attribute.KeyValue{
+    attribute.String("db.system", "mongodb"),
+    attribute.String("db.name", database),
+    attribute.String("db.collection", collection),
+  }, attrs...)
+  return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startCacheSpan is like startSpan, but also records the cache key.
+func startCacheSpan(ctx context.Context, name, database, collection, cacheKey string) (context.Context, trace.Span) {
+  return startSpan(ctx, name, database, collection, attribute.String("cache.key", cacheKey))
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+
+  if err != nil {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+}
@@ -0,0 +1,22 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+)
+
+// ErrTransactionsUnsupported is returned by Transaction. The mgo driver (including the Nifty255 fork
+// this package uses) predates MongoDB 4.0 sessions and has no way to start, commit, or abort a
+// multi-document transaction.
+var ErrTransactionsUnsupported = errors.New("multi-document transactions are not supported by the mgo driver")
+
+// Transaction is meant to run fn inside a multi-document transaction, but the mgo driver can't start
+// one, so it always returns ErrTransactionsUnsupported without calling fn rather than running fn
+// without atomicity. Callers needing a ServerMember and a Leaderboard to change together should use a
+// two-phase update instead, starting with PrepareUpdate.
+func Transaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+
+  return ErrTransactionsUnsupported
+}
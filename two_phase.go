@@ -0,0 +1,577 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// Two-phase updates stand in for transactions, which the mgo driver can't start (see Transaction). To
+// change a ServerMember and a Leaderboard together, start a two-phase update by preparing the update on
+// one with PrepareUpdate, join the other to it with JoinUpdate, then commit both once both are
+// prepared, or roll back whichever were prepared if one fails.
+//
+// Each two-phase update has a coordinator document, which decides for every document prepared under
+// its token whether the update is committed or rolled back. The first CommitUpdate marks it committed
+// before applying anything, after which it can't be rolled back, and the first RollbackUpdate marks it
+// rolled back, after which it can't be committed. A prepared update is stored on its document, and
+// committing applies it and clears it in a single atomic update. After a crash, RecoverStalePrepares
+// finishes what was decided: committed updates are rolled forward, and the rest are rolled back.
+
+// TwoPhaseUpdateClientName is the name of the MgoDriver to use for two-phase update coordinators.
+const TwoPhaseUpdateClientName = "main"
+
+// TwoPhaseUpdateDBName is the name of the database to use for two-phase update coordinators.
+const TwoPhaseUpdateDBName = "badpetbot"
+
+// TwoPhaseUpdateColName is the name of the collection to use for two-phase update coordinators.
+const TwoPhaseUpdateColName = "two_phase_updates"
+
+// ErrUpdatePending is matched by the error PrepareUpdate and JoinUpdate return when the document
+// already has a prepared update, which must be committed or rolled back first.
+var ErrUpdatePending = errors.New("document already has a pending update")
+
+// ErrUnknownUpdateToken is matched by the error CommitUpdate, RollbackUpdate, and JoinUpdate return
+// when there's nothing to do for the token, because the document's update was already committed, rolled
+// back, or recovered, or the two-phase update was already decided the other way.
+var ErrUnknownUpdateToken = errors.New("no pending update for token")
+
+// ErrUpdateCommitted is matched by the error RollbackUpdate returns when the two-phase update was
+// already committed through another document, so every document prepared under it must be committed.
+var ErrUpdateCommitted = errors.New("two-phase update is already committed")
+
+// Two-phase update coordinator states.
+const (
+  twoPhasePending    = "pending"
+  twoPhaseCommitted  = "committed"
+  twoPhaseRolledBack = "rolled_back"
+)
+
+// EnsureTwoPhaseUpdateIndices creates the indices listed under INDICES if they don't exist yet. It is
+// idempotent.
+func EnsureTwoPhaseUpdateIndices() error {
+
+  col := net.MgoCol(TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName)
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"created_at"}, Background: true}); err != nil {
+    return wrapDBError("TwoPhaseUpdate", "EnsureIndices", "", "", err)
+  }
+  for _, tp := range twoPhaseCollections {
+    col := net.MgoCol(tp.client, tp.database, tp.collection)
+    if err := col.EnsureIndex(mgo.Index{Key: []string{pendingUpdateField+".token"}, Sparse: true, Background: true}); err != nil {
+      return wrapDBError(tp.model, "EnsureIndices", "", "", err)
+    }
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { created_at: 1 }
+// server_members and leaderboards: { pending_update.token: 1 } sparse
+
+// twoPhaseUpdate is a two-phase update's coordinator document. Its ID is the update's token.
+type twoPhaseUpdate struct {
+  Token     string    `bson:"_id"`
+  State     string    `bson:"state"`
+  CreatedAt time.Time `bson:"created_at"`
+}
+
+// pendingUpdateField is where a document's prepared update is stored.
+const pendingUpdateField = "pending_update"
+
+// pendingUpdate is a prepared update, as stored on its document. The updates are stored as raw BSON,
+// since their operator keys can't be stored as field names.
+type pendingUpdate struct {
+  Token      string    `bson:"token"`
+  Updates    []byte    `bson:"updates"`
+  PreparedAt time.Time `bson:"prepared_at"`
+}
+
+// twoPhaseCollection is a collection whose documents can hold prepared updates.
+type twoPhaseCollection struct {
+  model      string
+  client     string
+  database   string
+  collection string
+  // commit loads the document with the ID and commits the update prepared on it with the token.
+  commit     func(ctx context.Context, id bson.ObjectId, token string) error
+}
+
+// twoPhaseCollections are the collections RecoverStalePrepares looks in.
+var twoPhaseCollections = []twoPhaseCollection{
+  {"ServerMember", ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(ctx context.Context, id bson.ObjectId, token string) error {
+    member := new(ServerMember)
+    err := mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+      return col.FindId(id).One(member)
+    })
+    if err != nil {
+      return err
+    }
+    return member.CommitUpdate(ctx, token)
+  }},
+  {"Leaderboard", LeaderboardClientName, LeaderboardDBName, LeaderboardColName, func(ctx context.Context, id bson.ObjectId, token string) error {
+    leaderboard := new(Leaderboard)
+    err := mgoDo(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, func(col *mgo.Collection) error {
+      return col.FindId(id).One(leaderboard)
+    })
+    if err != nil {
+      return err
+    }
+    return leaderboard.CommitUpdate(ctx, token)
+  }},
+}
+
+// PrepareUpdate starts a two-phase update, storing the updates on the ServerMember without applying
+// them, and returns the token to join other documents to it, and to commit or roll it back with. The
+// ServerMember is validated as it will be once updated. The error matches ErrUpdatePending if it
+// already has a prepared update.
+func (this *ServerMember) PrepareUpdate(ctx context.Context, updates bson.M) (token string, err error) {
+
+  defer observeOperation("ServerMember", "PrepareUpdate", time.Now(), &err)
+
+  return startTwoPhaseUpdate(ctx, func(token string) error {
+    return this.prepareUpdate(ctx, token, updates)
+  })
+}
+
+// JoinUpdate stores the updates on the ServerMember without applying them, as part of the two-phase
+// update started with the token by another document's PrepareUpdate. The ServerMember is validated as
+// it will be once updated. The error matches ErrUpdatePending if it already has a prepared update, and
+// ErrUnknownUpdateToken if the two-phase update was already committed or rolled back.
+func (this *ServerMember) JoinUpdate(ctx context.Context, token string, updates bson.M) (err error) {
+
+  defer observeOperation("ServerMember", "JoinUpdate", time.Now(), &err)
+
+  return this.prepareUpdate(ctx, token, updates)
+}
+
+// prepareUpdate validates the ServerMember as it will be once updated, and stores the updates on it
+// under the token.
+func (this *ServerMember) prepareUpdate(ctx context.Context, token string, updates bson.M) error {
+
+  updated := new(ServerMember)
+  if err := applyToCopy(this, updates, updated); err != nil {
+    return err
+  }
+  if err := updated.Validate(ctx); err != nil {
+    return err
+  }
+  err := prepareUpdate(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, this.ID, token, updates)
+  return wrapDBError("ServerMember", "PrepareUpdate", "_id", this.ID.Hex(), err)
+}
+
+// CommitUpdate commits the two-phase update, then applies the updates prepared on the ServerMember with
+// the token, like Update, and clears them. The receiver is validated and refreshed as it will be once
+// updated, and left as it was if the commit fails. Once committed, the rest of the two-phase update's
+// documents must be committed too, which RecoverStalePrepares does if nobody else does.
+func (this *ServerMember) CommitUpdate(ctx context.Context, token string) (err error) {
+
+  defer observeOperation("ServerMember", "CommitUpdate", time.Now(), &err)
+
+  updates, err := pendingUpdates(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, this.ID, token)
+  if err != nil {
+    return wrapDBError("ServerMember", "CommitUpdate", "_id", this.ID.Hex(), err)
+  }
+  updated := new(ServerMember)
+  if err := applyToCopy(this, updates, updated); err != nil {
+    return err
+  }
+  if err := decideTwoPhaseUpdate(ctx, token, twoPhaseCommitted); err != nil {
+    return wrapDBError("ServerMember", "CommitUpdate", "_id", this.ID.Hex(), err)
+  }
+
+  before := *this
+  *this = *updated
+  if err := this.update(ctx, pendingSelector(this.ID, token), withPendingCleared(updates)); err != nil {
+    *this = before
+    return err
+  }
+  return nil
+}
+
+// RollbackUpdate rolls back the two-phase update, then clears the updates prepared on the ServerMember
+// with the token without applying them. The error matches ErrUpdateCommitted if the two-phase update was
+// already committed through another document.
+func (this *ServerMember) RollbackUpdate(ctx context.Context, token string) (err error) {
+
+  defer observeOperation("ServerMember", "RollbackUpdate", time.Now(), &err)
+
+  err = decideTwoPhaseUpdate(ctx, token, twoPhaseRolledBack)
+  if err == nil {
+    err = rollbackUpdate(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, this.ID, token)
+  }
+  return wrapDBError("ServerMember", "RollbackUpdate", "_id", this.ID.Hex(), err)
+}
+
+// PrepareUpdate starts a two-phase update, storing the updates on the Leaderboard without applying
+// them, and returns the token to join other documents to it, and to commit or roll it back with. The
+// Leaderboard is validated as it will be once updated. The error matches ErrUpdatePending if it already
+// has a prepared update.
+func (this *Leaderboard) PrepareUpdate(ctx context.Context, updates bson.M) (token string, err error) {
+
+  defer observeOperation("Leaderboard", "PrepareUpdate", time.Now(), &err)
+
+  return startTwoPhaseUpdate(ctx, func(token string) error {
+    return this.prepareUpdate(ctx, token, updates)
+  })
+}
+
+// JoinUpdate stores the updates on the Leaderboard without applying them, as part of the two-phase
+// update started with the token by another document's PrepareUpdate. The Leaderboard is validated as
+// it will be once updated. The error matches ErrUpdatePending if it already has a prepared update, and
+// ErrUnknownUpdateToken if the two-phase update was already committed or rolled back.
+func (this *Leaderboard) JoinUpdate(ctx context.Context, token string, updates bson.M) (err error) {
+
+  defer observeOperation("Leaderboard", "JoinUpdate", time.Now(), &err)
+
+  return this.prepareUpdate(ctx, token, updates)
+}
+
+// prepareUpdate validates the Leaderboard as it will be once updated, and stores the updates on it
+// under the token.
+func (this *Leaderboard) prepareUpdate(ctx context.Context, token string, updates bson.M) error {
+
+  updated := new(Leaderboard)
+  if err := applyToCopy(this, updates, updated); err != nil {
+    return err
+  }
+  if err := updated.Validate(ctx); err != nil {
+    return err
+  }
+  err := prepareUpdate(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, this.ID, token, updates)
+  return wrapDBError("Leaderboard", "PrepareUpdate", "_id", this.ID.Hex(), err)
+}
+
+// CommitUpdate commits the two-phase update, then applies the updates prepared on the Leaderboard with
+// the token, like Update, and clears them. The receiver is validated and refreshed as it will be once
+// updated, and left as it was if the commit fails. Once committed, the rest of the two-phase update's
+// documents must be committed too, which RecoverStalePrepares does if nobody else does.
+func (this *Leaderboard) CommitUpdate(ctx context.Context, token string) (err error) {
+
+  defer observeOperation("Leaderboard", "CommitUpdate", time.Now(), &err)
+
+  updates, err := pendingUpdates(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, this.ID, token)
+  if err != nil {
+    return wrapDBError("Leaderboard", "CommitUpdate", "_id", this.ID.Hex(), err)
+  }
+  updated := new(Leaderboard)
+  if err := applyToCopy(this, updates, updated); err != nil {
+    return err
+  }
+  if err := decideTwoPhaseUpdate(ctx, token, twoPhaseCommitted); err != nil {
+    return wrapDBError("Leaderboard", "CommitUpdate", "_id", this.ID.Hex(), err)
+  }
+
+  before := *this
+  *this = *updated
+  if err := this.update(ctx, pendingSelector(this.ID, token), withPendingCleared(updates)); err != nil {
+    *this = before
+    return err
+  }
+  return nil
+}
+
+// RollbackUpdate rolls back the two-phase update, then clears the updates prepared on the Leaderboard
+// with the token without applying them. The error matches ErrUpdateCommitted if the two-phase update
+// was already committed through another document.
+func (this *Leaderboard) RollbackUpdate(ctx context.Context, token string) (err error) {
+
+  defer observeOperation("Leaderboard", "RollbackUpdate", time.Now(), &err)
+
+  err = decideTwoPhaseUpdate(ctx, token, twoPhaseRolledBack)
+  if err == nil {
+    err = rollbackUpdate(ctx, LeaderboardClientName, LeaderboardDBName, LeaderboardColName, this.ID, token)
+  }
+  return wrapDBError("Leaderboard", "RollbackUpdate", "_id", this.ID.Hex(), err)
+}
+
+// RecoverStalePrepares finishes every two-phase update started longer than maxAge ago, on the
+// assumption that whoever started it crashed before finishing it. Committed ones are rolled forward,
+// committing the updates still prepared on their documents, and the rest are rolled back. Their
+// coordinators are removed once every document is finished. Prepared updates older than maxAge with no
+// coordinator left are rolled back too. It should be run periodically, with a maxAge well above how long
+// a two-phase update normally takes.
+func RecoverStalePrepares(ctx context.Context, maxAge time.Duration) error {
+
+  cutoff := clockNow().Add(-maxAge)
+  failures := []string{}
+
+  // Finish the stale two-phase updates.
+  stale := []twoPhaseUpdate{}
+  err := mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) error {
+    return col.Find(bson.M{"created_at": bson.M{"$lt": cutoff}}).All(&stale)
+  })
+  if err != nil {
+    return wrapDBError("TwoPhaseUpdate", "Recover", "", "", err)
+  }
+  for _, update := range stale {
+    if err := recoverTwoPhaseUpdate(ctx, update); err != nil {
+      failures = append(failures, update.Token+": "+err.Error())
+    }
+  }
+
+  // Roll back stale prepared updates whose coordinator is gone, which happens if whoever prepared them
+  // crashed before noticing their two-phase update was already finished.
+  for _, tp := range twoPhaseCollections {
+    if err := rollbackOrphanedPrepares(ctx, tp, cutoff); err != nil {
+      failures = append(failures, tp.collection+": "+err.Error())
+    }
+  }
+
+  if len(failures) > 0 {
+    return errors.New("error recovering stale prepared updates: " + strings.Join(failures, "; "))
+  }
+  return nil
+}
+
+// recoverTwoPhaseUpdate rolls the two-phase update forward if it was committed, or back otherwise, then
+// removes its coordinator.
+func recoverTwoPhaseUpdate(ctx context.Context, update twoPhaseUpdate) error {
+
+  // Roll back an undecided update, unless someone commits it first.
+  state := update.State
+  if state == twoPhasePending {
+    state = twoPhaseRolledBack
+    if err := decideTwoPhaseUpdate(ctx, update.Token, twoPhaseRolledBack); errors.Is(err, ErrUpdateCommitted) {
+      state = twoPhaseCommitted
+    } else if err != nil {
+      return err
+    }
+  }
+
+  // Finish every document still holding an update prepared under the token.
+  for _, tp := range twoPhaseCollections {
+    ids := []struct {
+      ID bson.ObjectId `bson:"_id"`
+    }{}
+    err := mgoDo(ctx, tp.client, tp.database, tp.collection, func(col *mgo.Collection) error {
+      return col.Find(bson.M{pendingUpdateField+".token": update.Token}).Select(bson.M{"_id": 1}).All(&ids)
+    })
+    if err != nil {
+      return err
+    }
+    for _, doc := range ids {
+      if state == twoPhaseCommitted {
+        err = tp.commit(ctx, doc.ID, update.Token)
+      } else {
+        err = rollbackUpdate(ctx, tp.client, tp.database, tp.collection, doc.ID, update.Token)
+      }
+      if err != nil && !errors.Is(err, ErrUnknownUpdateToken) {
+        return fmt.Errorf("%s %s: %s", tp.model, doc.ID.Hex(), err.Error())
+      }
+    }
+  }
+
+  return mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) error {
+    if err := col.RemoveId(update.Token); err != mgo.ErrNotFound {
+      return err
+    }
+    return nil
+  })
+}
+
+// rollbackOrphanedPrepares rolls back the collection's updates prepared before the cutoff whose
+// two-phase update has no coordinator.
+func rollbackOrphanedPrepares(ctx context.Context, tp twoPhaseCollection, cutoff time.Time) error {
+
+  stale := []struct {
+    ID      bson.ObjectId `bson:"_id"`
+    Pending pendingUpdate `bson:"pending_update"`
+  }{}
+  err := mgoDo(ctx, tp.client, tp.database, tp.collection, func(col *mgo.Collection) error {
+    selector := bson.M{pendingUpdateField+".prepared_at": bson.M{"$lt": cutoff}}
+    return col.Find(selector).Select(bson.M{"_id": 1, pendingUpdateField+".token": 1}).All(&stale)
+  })
+  if err != nil {
+    return err
+  }
+  for _, doc := range stale {
+    var count int
+    err := mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) (err error) {
+      count, err = col.FindId(doc.Pending.Token).Count()
+      return err
+    })
+    if err == nil && count == 0 {
+      err = rollbackUpdate(ctx, tp.client, tp.database, tp.collection, doc.ID, doc.Pending.Token)
+    }
+    if err != nil && !errors.Is(err, ErrUnknownUpdateToken) {
+      return err
+    }
+  }
+  return nil
+}
+
+// startTwoPhaseUpdate stores a pending coordinator for a new two-phase update, then prepares the first
+// document's update with its token. If preparing fails, the two-phase update is rolled back.
+func startTwoPhaseUpdate(ctx context.Context, prepare func(token string) error) (string, error) {
+
+  token := bson.NewObjectId().Hex()
+  err := mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) error {
+    return col.Insert(twoPhaseUpdate{Token: token, State: twoPhasePending, CreatedAt: clockNow()})
+  })
+  if err != nil {
+    return "", wrapDBError("TwoPhaseUpdate", "Start", "", "", err)
+  }
+
+  // Nothing was prepared if preparing failed, so only the coordinator needs rolling back. If that fails
+  // too, RecoverStalePrepares rolls it back later.
+  if err := prepare(token); err != nil {
+    if err := decideTwoPhaseUpdate(ctx, token, twoPhaseRolledBack); err != nil {
+      log.Warn().AnErr("startTwoPhaseUpdate", err).Msgf("Error rolling back two-phase update")
+    }
+    return "", err
+  }
+  return token, nil
+}
+
+// decideTwoPhaseUpdate moves the two-phase update from pending to the state, either committed or rolled
+// back. Deciding the same way twice is allowed. Rolling back a committed update fails with
+// ErrUpdateCommitted, and committing a rolled back or unknown one fails with ErrUnknownUpdateToken.
+func decideTwoPhaseUpdate(ctx context.Context, token, state string) error {
+
+  return mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) error {
+    err := col.Update(bson.M{"_id": token, "state": bson.M{"$in": []string{twoPhasePending, state}}}, bson.M{"$set": bson.M{"state": state}})
+    if err != mgo.ErrNotFound {
+      return err
+    }
+
+    // Tell a committed update apart from a missing one.
+    if state == twoPhaseRolledBack {
+      if count, err := col.Find(bson.M{"_id": token, "state": twoPhaseCommitted}).Count(); err != nil {
+        return err
+      } else if count > 0 {
+        return ErrUpdateCommitted
+      }
+    }
+    return ErrUnknownUpdateToken
+  })
+}
+
+// prepareUpdate stores the updates under the token on the document with the ID, if it has none
+// pending. The two-phase update is checked once they're stored, so RecoverStalePrepares finds them if
+// it was still pending, and they're rolled back straight away if it wasn't.
+func prepareUpdate(ctx context.Context, client, database, collection string, id bson.ObjectId, token string, updates bson.M) error {
+
+  if len(updates) == 0 {
+    return errors.New("can't prepare an empty update")
+  }
+  raw, err := bson.Marshal(updates)
+  if err != nil {
+    return err
+  }
+
+  pending := pendingUpdate{Token: token, Updates: raw, PreparedAt: clockNow()}
+  err = mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    err := col.Update(bson.M{"_id": id, pendingUpdateField: bson.M{"$exists": false}}, bson.M{"$set": bson.M{pendingUpdateField: pending}})
+    if err != mgo.ErrNotFound {
+      return err
+    }
+
+    // Tell a pending update apart from a missing document.
+    if count, err := col.FindId(id).Count(); err != nil {
+      return err
+    } else if count > 0 {
+      return ErrUpdatePending
+    }
+    return mgo.ErrNotFound
+  })
+  if err != nil {
+    return err
+  }
+
+  var count int
+  err = mgoDo(ctx, TwoPhaseUpdateClientName, TwoPhaseUpdateDBName, TwoPhaseUpdateColName, func(col *mgo.Collection) (err error) {
+    count, err = col.Find(bson.M{"_id": token, "state": twoPhasePending}).Count()
+    return err
+  })
+  if err == nil && count == 0 {
+    err = ErrUnknownUpdateToken
+    if rollbackErr := rollbackUpdate(ctx, client, database, collection, id, token); rollbackErr != nil {
+      err = rollbackErr
+    }
+  }
+  return err
+}
+
+// pendingUpdates loads the updates prepared with the token on the document with the ID.
+func pendingUpdates(ctx context.Context, client, database, collection string, id bson.ObjectId, token string) (bson.M, error) {
+
+  stored := struct {
+    Pending pendingUpdate `bson:"pending_update"`
+  }{}
+  err := mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Find(pendingSelector(id, token)).Select(bson.M{pendingUpdateField: 1}).One(&stored)
+  })
+  if err == mgo.ErrNotFound {
+    return nil, ErrUnknownUpdateToken
+  } else if err != nil {
+    return nil, err
+  }
+
+  updates := bson.M{}
+  if err := bson.Unmarshal(stored.Pending.Updates, &updates); err != nil {
+    return nil, fmt.Errorf("can't load pending update: %s", err.Error())
+  }
+  return updates, nil
+}
+
+// rollbackUpdate clears the updates prepared with the token on the document with the ID.
+func rollbackUpdate(ctx context.Context, client, database, collection string, id bson.ObjectId, token string) error {
+
+  err := mgoDo(ctx, client, database, collection, func(col *mgo.Collection) error {
+    return col.Update(pendingSelector(id, token), bson.M{"$unset": bson.M{pendingUpdateField: ""}})
+  })
+  if err == mgo.ErrNotFound {
+    return ErrUnknownUpdateToken
+  }
+  return err
+}
+
+// pendingSelector matches the document with the ID while it holds the updates prepared with the token.
+func pendingSelector(id bson.ObjectId, token string) bson.M {
+
+  return bson.M{"_id": id, pendingUpdateField+".token": token}
+}
+
+// withPendingCleared returns a copy of the updates which also clears the prepared update.
+func withPendingCleared(updates bson.M) bson.M {
+
+  cleared := make(bson.M, len(updates)+1)
+  for operator, fields := range updates {
+    cleared[operator] = fields
+  }
+  unset := bson.M{pendingUpdateField: ""}
+  if fields, ok := updates["$unset"].(bson.M); ok {
+    for k, v := range fields {
+      unset[k] = v
+    }
+  }
+  cleared["$unset"] = unset
+  return cleared
+}
+
+// applyToCopy applies the updates to the document in memory, storing the result in result.
+func applyToCopy(doc interface{}, updates bson.M, result interface{}) error {
+
+  stored, err := toBSONDoc(doc)
+  if err != nil {
+    return err
+  }
+  updated, err := applyUpdate(stored, updates)
+  if err != nil {
+    return err
+  }
+  return fromBSONDoc(updated, result)
+}
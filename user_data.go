@@ -0,0 +1,231 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+  "golang.org/x/sync/errgroup"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// UserDataExportTimeout is how long ExportUserData can take before giving up.
+const UserDataExportTimeout = 30*time.Second
+
+// userDataQuery finds one collection's documents belonging to a user.
+type userDataQuery struct {
+  client     string
+  database   string
+  collection string
+  filter     bson.M
+}
+
+// userDataQueries returns a query for each collection holding data about the Discord user, given the
+// user's ServerMember documents. ServerMembers themselves are not included.
+func userDataQueries(discordUserID string, members []bson.M) []userDataQuery {
+
+  memberIDs := []bson.ObjectId{}
+  discordIDs := []string{discordUserID}
+  for _, member := range members {
+    if id, ok := member["_id"].(bson.ObjectId); ok {
+      memberIDs = append(memberIDs, id)
+    }
+    if id, ok := member["discord_member_id"].(string); ok && id != "" {
+      discordIDs = append(discordIDs, id)
+    }
+  }
+
+  return []userDataQuery{
+    {WarningClientName, WarningDBName, WarningColName, bson.M{
+      "server_member_id": bson.M{"$in": memberIDs},
+    }},
+    {UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, bson.M{
+      "server_member_id": bson.M{"$in": memberIDs},
+    }},
+    {RoleAssignmentClientName, RoleAssignmentDBName, RoleAssignmentColName, bson.M{
+      "assigned_to_discord_id": bson.M{"$in": discordIDs},
+    }},
+    {TagClientName, TagDBName, TagColName, bson.M{
+      "created_by_member_id": bson.M{"$in": memberIDs},
+    }},
+    {LeaderboardClientName, LeaderboardDBName, LeaderboardColName, bson.M{
+      "discord_member_id": bson.M{"$in": discordIDs},
+    }},
+    {AuditLogEntryClientName, AuditLogEntryDBName, AuditLogEntryColName, bson.M{
+      "$or": []bson.M{
+        {"actor_discord_id": bson.M{"$in": discordIDs}},
+        {"target_id": bson.M{"$in": memberIDs}},
+      },
+    }},
+  }
+}
+
+// findUserMembers finds every one of the Discord user's ServerMember documents, including soft-deleted
+// ones and ones stored before encryption was enabled, as raw documents.
+func findUserMembers(ctx context.Context, discordUserID string) ([]bson.M, error) {
+
+  members := []bson.M{}
+  err := mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(bson.M{"discord_user_id": MatchEncrypted(discordUserID)}).All(&members)
+  })
+  return members, err
+}
+
+// ExportUserData collects every document stored about the Discord user, for data access requests. The
+// result maps each collection name to its documents, with ObjectIds converted to hex strings. Soft-
+// deleted documents are included. Collections are queried concurrently, and the export gives up after
+// UserDataExportTimeout.
+func ExportUserData(discordUserID string) (_ map[string]interface{}, err error) {
+
+  defer observeOperation("UserData", "Export", time.Now(), &err)
+
+  ctx, cancel := context.WithTimeout(context.Background(), UserDataExportTimeout)
+  defer cancel()
+
+  // Find the user's members first, since most other data hangs off of them.
+  members, err := findUserMembers(ctx, discordUserID)
+  if err != nil {
+    return nil, wrapDBError("UserData", "Export", "discord_user_id", discordUserID, err)
+  }
+
+  // Query the rest of the collections concurrently.
+  queries := userDataQueries(discordUserID, members)
+  results := make([][]bson.M, len(queries))
+  group, groupCtx := errgroup.WithContext(ctx)
+  for i, query := range queries {
+    i, query := i, query
+    group.Go(func() error {
+      return mgoDo(groupCtx, query.client, query.database, query.collection, func(col *mgo.Collection) error {
+        results[i] = []bson.M{}
+        return col.Find(query.filter).All(&results[i])
+      })
+    })
+  }
+  if err = group.Wait(); err != nil {
+    return nil, wrapDBError("UserData", "Export", "discord_user_id", discordUserID, err)
+  }
+
+  // Export the user's own ID in plaintext, rather than as stored.
+  for _, member := range members {
+    member["discord_user_id"] = discordUserID
+  }
+  export := map[string]interface{}{ServerMemberColName: exportDocuments(members)}
+  for i, query := range queries {
+    export[query.collection] = exportDocuments(results[i])
+  }
+  return export, nil
+}
+
+// exportDocuments converts raw documents into plain maps, with ObjectIds as hex strings.
+func exportDocuments(docs []bson.M) []map[string]interface{} {
+
+  out := make([]map[string]interface{}, len(docs))
+  for i, doc := range docs {
+    out[i] = exportValue(doc).(map[string]interface{})
+  }
+  return out
+}
+
+// exportValue converts a raw document value, recursing into nested documents and arrays.
+func exportValue(value interface{}) interface{} {
+
+  switch v := value.(type) {
+  case bson.ObjectId:
+    return v.Hex()
+  case bson.M:
+    return exportValue(map[string]interface{}(v))
+  case map[string]interface{}:
+    out := make(map[string]interface{}, len(v))
+    for key, nested := range v {
+      out[key] = exportValue(nested)
+    }
+    return out
+  case []interface{}:
+    out := make([]interface{}, len(v))
+    for i, nested := range v {
+      out[i] = exportValue(nested)
+    }
+    return out
+  default:
+    return v
+  }
+}
+
+// PurgeSummary describes what PurgeUserData removed.
+type PurgeSummary struct {
+  // Snapshot is the user's data as exported right before the purge.
+  Snapshot map[string]interface{}
+  // Removed is how many documents were removed from each collection.
+  Removed  map[string]int
+}
+
+// PurgeUserData permanently removes every document stored about the Discord user, for erasure requests,
+// and flushes the cache of every collection it removed documents from. The user's data is exported
+// first and returned in the summary. A failure in one collection doesn't stop the others, and every
+// failure is returned together.
+func PurgeUserData(discordUserID string) (_ *PurgeSummary, err error) {
+
+  defer observeOperation("UserData", "Purge", time.Now(), &err)
+
+  // Snapshot what exists.
+  snapshot, err := ExportUserData(discordUserID)
+  if err != nil {
+    return nil, err
+  }
+  summary := &PurgeSummary{Snapshot: snapshot, Removed: map[string]int{}}
+
+  ctx, cancel := context.WithTimeout(context.Background(), UserDataExportTimeout)
+  defer cancel()
+  members, err := findUserMembers(ctx, discordUserID)
+  if err != nil {
+    return summary, wrapDBError("UserData", "Purge", "discord_user_id", discordUserID, err)
+  }
+
+  // Remove the data hanging off of the user's members first, and the members themselves last, so a
+  // failed purge can be retried.
+  queries := userDataQueries(discordUserID, members)
+  queries = append(queries, userDataQuery{ServerMemberClientName, ServerMemberDBName, ServerMemberColName, bson.M{
+    "discord_user_id": MatchEncrypted(discordUserID),
+  }})
+  failures := []string{}
+  for _, query := range queries {
+    var info *mgo.ChangeInfo
+    err := mgoDo(ctx, query.client, query.database, query.collection, func(col *mgo.Collection) (err error) {
+      info, err = col.RemoveAll(query.filter)
+      return err
+    })
+    if err != nil {
+      failures = append(failures, query.collection+": "+err.Error())
+      continue
+    }
+    summary.Removed[query.collection] = info.Removed
+    if info.Removed > 0 {
+      flushCollectionCache(query.client, query.database, query.collection)
+    }
+  }
+  if len(failures) > 0 {
+    return summary, wrapDBError("UserData", "Purge", "discord_user_id", discordUserID, errors.New(strings.Join(failures, "; ")))
+  }
+  return summary, nil
+}
+
+// flushCollectionCache deletes every cache and neg-cache entry for the collection, logging failures.
+func flushCollectionCache(client, database, collection string) {
+
+  redisClient := net.RedisGetClient(client)
+  prefix := client+":"+database+":"+collection+":"
+  for _, pattern := range []string{prefix+"*", "neg:"+prefix+"*"} {
+    if _, err := deleteCachePattern(context.Background(), redisClient, pattern); err != nil {
+      log.Warn().AnErr("flushCache", err).Msgf("Error flushing cache for %s", collection)
+    }
+  }
+}
@@ -0,0 +1,707 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// UserPreferenceClientName is the name of the MgoDriver to use for UserPreference.
+const UserPreferenceClientName = "main"
+
+// UserPreferenceDBName is the name of the database to use for UserPreference.
+const UserPreferenceDBName = "badpetbot"
+
+// UserPreferenceColName is the name of the collection to use for UserPreference.
+const UserPreferenceColName = "user_preferences"
+
+// UserPreferenceModelVersion is the current schema version of UserPreference documents, stored in their ModelVersion.
+const UserPreferenceModelVersion = 1
+
+// userPreferenceFields maps each preference's bson field name to its in-memory field, for SetPreference.
+var userPreferenceFields = map[string]func(*UserPreference) *bool{
+  "opt_out_punishments":   func(p *UserPreference) *bool { return &p.OptOutPunishments },
+  "opt_out_leaderboard":   func(p *UserPreference) *bool { return &p.OptOutLeaderboard },
+  "opt_out_notifications": func(p *UserPreference) *bool { return &p.OptOutNotifications },
+}
+
+// UserPreferenceCol gets a collection reference for UserPreference.
+func UserPreferenceCol() *mgo.Collection {
+  return net.MgoCol(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName)
+}
+
+// Register UserPreference, so tooling can find it.
+func init() {
+  RegisterModel("UserPreference", UserPreferenceCol, func() interface{} { return new(UserPreference) })
+}
+
+// EnsureUserPreferenceIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureUserPreferenceIndices() error {
+
+  col := UserPreferenceCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"server_member_id"}, Unique: true, Background: true}); err != nil {
+    return wrapDBError("UserPreference", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { server_member_id: 1 } unique
+
+// UserPreference holds a ServerMember's opt-outs from bot interactions. Each ServerMember has at most one.
+type UserPreference struct {
+  // ID is a BSON ID generated in Create.
+  ID                  bson.ObjectId   `bson:"_id"                   json:"_id"                   validate:"required"`
+  ServerMemberID      bson.ObjectId   `bson:"server_member_id"      json:"server_member_id"      validate:"required"`
+  DiscordServerID     string          `bson:"discord_server_id"     json:"discord_server_id"     validate:"required,discord_id"`
+  OptOutPunishments   bool            `bson:"opt_out_punishments"   json:"opt_out_punishments"   validate:"-"`
+  OptOutLeaderboard   bool            `bson:"opt_out_leaderboard"   json:"opt_out_leaderboard"   validate:"-"`
+  OptOutNotifications bool            `bson:"opt_out_notifications" json:"opt_out_notifications" validate:"-"`
+  CreatedAt           time.Time       `bson:"created_at"            json:"created_at"            validate:"required"`
+  UpdatedAt           time.Time       `bson:"updated_at"            json:"updated_at"            validate:"required"`
+  DeletedAt           *time.Time      `bson:"deleted_at"            json:"deleted_at"            validate:"-"`
+  Version             int             `bson:"version"               json:"version"               validate:"-"`
+  ModelVersion        int             `bson:"model_version"         json:"model_version"         validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *UserPreference) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "UserPreference.Create", UserPreferenceDBName, UserPreferenceColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("UserPreference", "Create", time.Now(), &err)
+  defer logSlowQuery("UserPreference", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = UserPreferenceModelVersion
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the UserPreference.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("UserPreference", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *UserPreference) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *UserPreference) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *UserPreference) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "UserPreference.Update", UserPreferenceDBName, UserPreferenceColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("UserPreference", "Update", time.Now(), &err)
+  defer logSlowQuery("UserPreference", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheUserPreference(net.RedisGetClient(UserPreferenceClientName), this.cacheKeys())
+  }
+  return wrapDBError("UserPreference", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *UserPreference) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "UserPreference.Delete", UserPreferenceDBName, UserPreferenceColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("UserPreference", "Delete", time.Now(), &err)
+  defer logSlowQuery("UserPreference", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the UserPreference.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheUserPreference(net.RedisGetClient(UserPreferenceClientName), this.cacheKeys())
+  }
+  return wrapDBError("UserPreference", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SetPreference sets the single named preference, by its bson field name (e.g. "opt_out_leaderboard"),
+// leaving the others untouched. Only the fields in userPreferenceFields can be set.
+func (this *UserPreference) SetPreference(field string, value bool) error {
+
+  target, ok := userPreferenceFields[field]
+  if !ok {
+    return fmt.Errorf("can't set unknown UserPreference field %q", field)
+  }
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{field: value}}); err != nil {
+    return err
+  }
+  *target(this) = value
+  return nil
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *UserPreference) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *UserPreference) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *UserPreference) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("UserPreference", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("UserPreference", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheUserPreference(net.RedisGetClient(UserPreferenceClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *UserPreference) Validate(ctx context.Context) error {
+
+  return wrapValidationError("UserPreference", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *UserPreference) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("UserPreference", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("UserPreference", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *UserPreference) Clone() *UserPreference {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*UserPreference)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *UserPreference) Diff(other *UserPreference) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *UserPreference) DiffDeep(other *UserPreference) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g.
+// "opt_out_leaderboard".
+func (this *UserPreference) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *UserPreference) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *UserPreference) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *UserPreference) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *UserPreference) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *UserPreference) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the UserPreference is uninitialized, having neither an ID nor a ServerMemberID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *UserPreference) IsZero() bool {
+  return this.ID == "" && this.ServerMemberID == ""
+}
+
+// Age returns how long ago the UserPreference was created.
+func (this *UserPreference) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the UserPreference was last updated.
+func (this *UserPreference) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the UserPreference hasn't been updated for longer than the threshold.
+func (this *UserPreference) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetUserPreference attempts to find a UserPreference by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetUserPreference(key, value string, negCache bool) (found *UserPreference, err error) {
+
+  client := net.RedisGetClient(UserPreferenceClientName)
+  cacheKey := UserPreferenceClientName+":"+UserPreferenceDBName+":"+UserPreferenceColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "UserPreference.CacheGet", UserPreferenceDBName, UserPreferenceColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("UserPreference", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("UserPreference", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("UserPreference", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "UserPreference", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("UserPreference", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("UserPreference", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    userPreference := new(UserPreference)
+    if err := json.Unmarshal([]byte(result), userPreference); err != nil {
+      return nil, wrapDBError("UserPreference", "CacheGet", key, value, err)
+    }
+    return userPreference, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("UserPreference", key, "miss")
+  userPreference := new(UserPreference)
+  err = net.MgoCol(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(userPreference)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheUserPreference(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheUserPreference(client, cacheKey, userPreference)
+  }
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "CacheGet", key, value, err)
+  }
+  return userPreference, nil
+}
+
+func fillCacheUserPreference(client *redis.Client, key string, value *UserPreference) {
+  _, span := startCacheSpan(context.Background(), "UserPreference.fillCache", UserPreferenceDBName, UserPreferenceColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for UserPreference")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for UserPreference")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheUserPreference(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "UserPreference.fillNegCache", UserPreferenceDBName, UserPreferenceColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for UserPreference")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheUserPreference(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for UserPreference")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetUserPreference.
+func (this *UserPreference) cacheKeys() []string {
+  prefix := UserPreferenceClientName+":"+UserPreferenceDBName+":"+UserPreferenceColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+    prefix+"server_member_id:"+this.ServerMemberID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateUserPreferences runs the aggregation pipeline on the UserPreference collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateUserPreferences(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("UserPreference", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, pipeline, opts)
+  defer done()
+  return wrapDBError("UserPreference", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateUserPreferenceOne runs the aggregation pipeline on the UserPreference collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateUserPreferenceOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("UserPreference", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, pipeline, opts)
+  defer done()
+  return wrapDBError("UserPreference", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyUserPreference atomically applies the update to the first UserPreference matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyUserPreference(selector, update bson.M, returnNew bool) (_ *UserPreference, err error) {
+
+  defer observeOperation("UserPreference", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(UserPreference)
+  _, err = UserPreferenceCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheUserPreference(net.RedisGetClient(UserPreferenceClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindUserPreferences finds all UserPreferences matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindUserPreferences(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*UserPreference, err error) {
+
+  defer observeOperation("UserPreference", "Find", time.Now(), &err)
+  defer logSlowQuery("UserPreference", "Find", filter)()
+
+  results := []*UserPreference{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindUserPreferencesWithDeleted finds all UserPreferences matching the filter, including soft-deleted ones.
+func FindUserPreferencesWithDeleted(filter bson.M) (_ []*UserPreference, err error) {
+
+  defer observeOperation("UserPreference", "Find", time.Now(), &err)
+
+  results := []*UserPreference{}
+  if err := net.MgoCol(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("UserPreference", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountUserPreferences counts the UserPreferences matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountUserPreferences(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("UserPreference", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("UserPreference", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// UserPreferenceExists reports whether any UserPreference matches the filter, excluding soft-deleted ones.
+func UserPreferenceExists(filter bson.M) (bool, error) {
+
+  count, err := CountUserPreferences(filter)
+  return count > 0, err
+}
+
+// DistinctUserPreferenceFields finds the distinct values of the field among the UserPreferences matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctUserPreferenceFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("UserPreference", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctUserPreferenceStringFields is DistinctUserPreferenceFields for string fields, like discord_server_id. It fails if
+// any of the values isn't a string.
+func DistinctUserPreferenceStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctUserPreferenceFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactUserPreferenceCollection runs the compact command against the UserPreference collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactUserPreferenceCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName)
+}
+
+// UserPreferenceCollectionStats returns the UserPreference collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func UserPreferenceCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName)
+}
+
+// ExplainUserPreferenceQuery returns MongoDB's explain output for finding the filter in the UserPreference collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainUserPreferenceQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, filter)
+}
+
+// UpdateManyUserPreferences applies the updates to every UserPreference matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateUserPreferences for that. The UserPreference cache is flushed.
+func UpdateManyUserPreferences(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, filter, updates)
+}
+
+// BulkUpdateUserPreferences is UpdateManyUserPreferences, but validates each UserPreference as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateUserPreferences(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, filter, updates, func() validatable { return new(UserPreference) })
+}
+
+// DeleteManyUserPreferences soft-deletes every UserPreference matching the filter in a single query, returning how many
+// were deleted. The UserPreference cache is evicted.
+func DeleteManyUserPreferences(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "UserPreference", UserPreferenceClientName, UserPreferenceDBName, UserPreferenceColName, filter)
+}
+
+// FindOrCreateUserPreference returns the ServerMember's preferences, creating them with every opt-out
+// disabled first if there are none. The ServerMember must exist. Concurrent callers for the same
+// ServerMember all get the same document. Soft-deleted preferences aren't returned, but still hold the
+// unique index on server_member_id, so they must be restored or purged before new ones can be created;
+// until then, a not-found error is returned.
+func FindOrCreateUserPreference(memberID bson.ObjectId) (_ *UserPreference, err error) {
+
+  defer observeOperation("UserPreference", "FindOrCreate", time.Now(), &err)
+
+  col := UserPreferenceCol()
+  selector := notDeleted(bson.M{"server_member_id": memberID})
+
+  // Return the existing document if there is one.
+  existing := new(UserPreference)
+  if err := col.Find(selector).One(existing); err == nil {
+    return existing, nil
+  } else if err != mgo.ErrNotFound {
+    return nil, wrapDBError("UserPreference", "FindOrCreate", "server_member_id", memberID.Hex(), err)
+  }
+
+  // Look up the member's server, which the preferences are scoped to.
+  member := new(ServerMember)
+  if err := ServerMemberCol().FindId(memberID).Select(bson.M{"discord_server_id": 1}).One(member); err != nil {
+    return nil, wrapDBError("ServerMember", "FindOrCreateUserPreference", "_id", memberID.Hex(), err)
+  }
+
+  // Build the default document the same way Create would.
+  now := clockNow()
+  doc := &UserPreference{
+    ID:              bson.NewObjectId(),
+    ServerMemberID:  memberID,
+    DiscordServerID: member.DiscordServerID,
+    CreatedAt:       now,
+    UpdatedAt:       now,
+    Version:         1,
+    ModelVersion:    UserPreferenceModelVersion,
+  }
+  if err := doc.Validate(context.Background()); err != nil {
+    return nil, err
+  }
+
+  // Insert it only if nobody else did in the meantime.
+  fields, err := bsonFields(doc)
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "FindOrCreate", "server_member_id", memberID.Hex(), err)
+  }
+  result := new(UserPreference)
+  _, err = col.Find(selector).Apply(mgo.Change{
+    Update:    bson.M{"$setOnInsert": fields},
+    Upsert:    true,
+    ReturnNew: true,
+  }, result)
+
+  // Concurrent upserts can both miss, in which case the unique index rejects all but one insert. The
+  // losers find the winner's document.
+  if mgo.IsDup(err) {
+    err = col.Find(selector).One(result)
+  }
+  if err != nil {
+    return nil, wrapDBError("UserPreference", "FindOrCreate", "server_member_id", memberID.Hex(), err)
+  }
+  return result, nil
+}
@@ -0,0 +1,209 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "fmt"
+  "reflect"
+  "strings"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo/bson"
+)
+
+// bsonFields marshals the given document and returns its stored fields as a bson.M, minus any of
+// the omitted keys. Embeddables tagged "omitalways" are never included.
+func bsonFields(doc interface{}, omit ...string) (bson.M, error) {
+
+  raw, err := bson.Marshal(doc)
+  if err != nil {
+    return nil, err
+  }
+  fields := bson.M{}
+  if err := bson.Unmarshal(raw, &fields); err != nil {
+    return nil, err
+  }
+  for _, key := range omit {
+    delete(fields, key)
+  }
+  return fields, nil
+}
+
+// notDeleted returns a copy of the given filter which excludes soft-deleted documents, unless the
+// filter already has its own condition on "deleted_at".
+func notDeleted(filter bson.M) bson.M {
+
+  out := make(bson.M, len(filter)+1)
+  for k, v := range filter {
+    out[k] = v
+  }
+  if _, ok := out["deleted_at"]; !ok {
+    out["deleted_at"] = nil
+  }
+  return out
+}
+
+// bsonQueryValue converts a cache lookup value into what's stored in the database for the given key.
+// Values for "_id" and other ObjectId references (like "server_id") are converted from hex to ObjectIds,
+// everything else is used as-is. Discord IDs are decimal snowflakes, so they're never mistaken for hex.
+func bsonQueryValue(key, value string) interface{} {
+
+  if strings.HasSuffix(key, "_id") && bson.IsObjectIdHex(value) {
+    return bson.ObjectIdHex(value)
+  }
+  return value
+}
+
+// bsonValueString converts a stored field value into the string form used in cache keys.
+func bsonValueString(value interface{}) string {
+
+  switch v := value.(type) {
+  case bson.ObjectId:
+    return v.Hex()
+  case string:
+    return v
+  default:
+    return fmt.Sprint(v)
+  }
+}
+
+// versionSelector matches the given document version. Documents stored before versioning have no
+// version field, so they are matched as version 0.
+func versionSelector(version int) interface{} {
+
+  if version == 0 {
+    return bson.M{"$in": []interface{}{0, nil}}
+  }
+  return version
+}
+
+// NewMatchStage wraps the filter in a $match aggregation stage.
+func NewMatchStage(filter bson.M) bson.M {
+
+  return bson.M{"$match": filter}
+}
+
+// deepCopy returns a copy of v sharing no pointers, slices, or maps with it. Unexported struct fields
+// are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+
+  switch v.Kind() {
+  case reflect.Ptr:
+    if v.IsNil() {
+      return v
+    }
+    out := reflect.New(v.Elem().Type())
+    out.Elem().Set(deepCopy(v.Elem()))
+    return out
+  case reflect.Slice:
+    if v.IsNil() {
+      return v
+    }
+    out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+    for i := 0; i < v.Len(); i++ {
+      out.Index(i).Set(deepCopy(v.Index(i)))
+    }
+    return out
+  case reflect.Map:
+    if v.IsNil() {
+      return v
+    }
+    out := reflect.MakeMapWithSize(v.Type(), v.Len())
+    for _, key := range v.MapKeys() {
+      out.SetMapIndex(key, deepCopy(v.MapIndex(key)))
+    }
+    return out
+  case reflect.Interface:
+    if v.IsNil() {
+      return v
+    }
+    out := reflect.New(v.Type()).Elem()
+    out.Set(deepCopy(v.Elem()))
+    return out
+  case reflect.Struct:
+    out := reflect.New(v.Type()).Elem()
+    out.Set(v)
+    for i := 0; i < v.NumField(); i++ {
+      if out.Field(i).CanSet() {
+        out.Field(i).Set(deepCopy(v.Field(i)))
+      }
+    }
+    return out
+  default:
+    return v
+  }
+}
+
+// bsonFieldName returns the field's bson key, and whether it's an embeddable (tagged "omitalways").
+// An empty name means the field isn't stored.
+func bsonFieldName(field reflect.StructField) (name string, embeddable bool) {
+
+  if field.PkgPath != "" {
+    return "", false
+  }
+  parts := strings.Split(field.Tag.Get("bson"), ",")
+  name = parts[0]
+  if name == "-" {
+    return "", false
+  }
+  if name == "" {
+    name = strings.ToLower(field.Name)
+  }
+  for _, flag := range parts[1:] {
+    if flag == "omitalways" {
+      embeddable = true
+    }
+  }
+  return name, embeddable
+}
+
+// diffFields compares every exported field of the structs pointed to by this and other, returning
+// other's value for each one that differs, keyed by bson field name. Embeddables are skipped unless
+// deep is true, in which case they're compared too, recursing into embedded documents with dotted keys.
+func diffFields(this, other interface{}, deep bool) bson.M {
+
+  diff := bson.M{}
+  diffInto(diff, "", reflect.ValueOf(this).Elem(), reflect.ValueOf(other).Elem(), deep)
+  return diff
+}
+
+// diffInto adds the differences between the structs a and b to diff, prefixing keys with prefix.
+func diffInto(diff bson.M, prefix string, a, b reflect.Value, deep bool) {
+
+  for i := 0; i < a.NumField(); i++ {
+    name, embeddable := bsonFieldName(a.Type().Field(i))
+    if name == "" || (embeddable && !deep) {
+      continue
+    }
+    fieldA, fieldB := a.Field(i), b.Field(i)
+
+    // Recurse into embedded documents both sides have.
+    if embeddable && fieldA.Kind() == reflect.Ptr && !fieldA.IsNil() && !fieldB.IsNil() {
+      diffInto(diff, prefix+name+".", fieldA.Elem(), fieldB.Elem(), deep)
+      continue
+    }
+    if !reflect.DeepEqual(fieldA.Interface(), fieldB.Interface()) {
+      diff[prefix+name] = fieldB.Interface()
+    }
+  }
+}
+
+// partialFieldNames resolves field names, given as Go field names or bson field names, into the Go field
+// names of the struct pointed to by doc, for validating only those fields.
+func partialFieldNames(model string, doc interface{}, fields []string) ([]string, error) {
+
+  t := reflect.TypeOf(doc).Elem()
+  names := make([]string, len(fields))
+  for i, field := range fields {
+    for j := 0; j < t.NumField(); j++ {
+      if bsonName, _ := bsonFieldName(t.Field(j)); t.Field(j).Name == field || bsonName == field {
+        names[i] = t.Field(j).Name
+        break
+      }
+    }
+    if names[i] == "" {
+      return nil, fmt.Errorf("can't validate unknown %s field %q", model, field)
+    }
+  }
+  return names, nil
+}
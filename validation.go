@@ -0,0 +1,58 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "reflect"
+  "regexp"
+  "sync"
+
+  // Import 3rd party packages.
+  validator "github.com/go-playground/validator/v10"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/validation"
+)
+
+// discordIDPattern matches Discord snowflakes.
+var discordIDPattern = regexp.MustCompile(`^\d{17,20}$`)
+
+// RegisterDiscordValidators registers gomodel's Discord validations on the validator:
+//
+// discord_id: the field is a Discord snowflake, a 17 to 20 digit numeric string.
+func RegisterDiscordValidators(v *validator.Validate) error {
+
+  return v.RegisterValidation("discord_id", func(f validator.FieldLevel) bool {
+    return discordIDPattern.MatchString(f.Field().String())
+  })
+}
+
+// modelValidator is built once by newValidator. Building a validator isn't safe to do concurrently,
+// but using a built one is.
+var modelValidator *validator.Validate
+var modelValidatorOnce sync.Once
+
+// Build the validator at startup, so a validation which can't be registered fails loudly there rather
+// than on the first Validate.
+func init() {
+  newValidator()
+}
+
+// newValidator returns the validator with the common validations and gomodel's own registered, building
+// it on first use. Fields are named by their bson names in validation errors. It panics if gomodel's
+// validations can't be registered, which is a programming error.
+func newValidator() *validator.Validate {
+
+  modelValidatorOnce.Do(func() {
+    v := validation.NewValidator()
+    if err := RegisterDiscordValidators(v); err != nil {
+      panic("can't register Discord validators: " + err.Error())
+    }
+    v.RegisterTagNameFunc(func(field reflect.StructField) string {
+      name, _ := bsonFieldName(field)
+      return name
+    })
+    modelValidator = v
+  })
+  return modelValidator
+}
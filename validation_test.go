@@ -0,0 +1,51 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "strings"
+  "testing"
+)
+
+func TestDiscordIDValidation(t *testing.T) {
+
+  cases := []struct {
+    name  string
+    id    string
+    valid bool
+  }{
+    {"empty", "", false},
+    {"16 digits", strings.Repeat("1", 16), false},
+    {"17 digits", strings.Repeat("1", 17), true},
+    {"20 digits", strings.Repeat("1", 20), true},
+    {"21 digits", strings.Repeat("1", 21), false},
+    {"letters", "12345678901234567a", false},
+    {"spaces", "1234567890 1234567", false},
+    {"negative", "-12345678901234567", false},
+  }
+
+  v := newValidator()
+  for _, c := range cases {
+    t.Run(c.name, func(t *testing.T) {
+      doc := struct {
+        ID string `bson:"id" validate:"required,discord_id"`
+      }{c.id}
+      if err := v.Struct(doc); (err == nil) != c.valid {
+        t.Errorf("validating %q: got error %v, want valid %v", c.id, err, c.valid)
+      }
+    })
+  }
+}
+
+func TestDiscordIDValidationOnModels(t *testing.T) {
+
+  member := NewServerMemberWithDefaults(strings.Repeat("1", 18), strings.Repeat("2", 18), strings.Repeat("3", 18))
+  if err := member.Validate(context.Background()); err != nil {
+    t.Fatalf("valid ServerMember failed validation: %v", err)
+  }
+  member.DiscordServerID = "not a snowflake"
+  if err := member.Validate(context.Background()); err == nil {
+    t.Fatal("ServerMember with an invalid discord_server_id passed validation")
+  }
+}
@@ -0,0 +1,104 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "strings"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/rs/zerolog/log"
+  "golang.org/x/sync/errgroup"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// CacheWarmConcurrency is how many documents are written to cache at once while warming it.
+const CacheWarmConcurrency = 16
+
+// WarmCacheForServer loads every ServerMember in the Discord server into cache, under their
+// discord_member_id and discord_user_id keys, so the first requests after a restart don't all hit the
+// database. Soft-deleted members are skipped.
+func WarmCacheForServer(ctx context.Context, serverID string) error {
+
+  _, err := warmCacheForServer(ctx, serverID)
+  return err
+}
+
+// warmCacheForServer warms the Discord server's ServerMembers, returning how many were loaded.
+func warmCacheForServer(ctx context.Context, serverID string) (loaded int, err error) {
+
+  defer observeOperation("ServerMember", "WarmCache", time.Now(), &err)
+  start := time.Now()
+
+  members := []*ServerMember{}
+  err = mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(bson.M{"discord_server_id": serverID, "deleted_at": nil}).All(&members)
+  })
+  if err != nil {
+    return 0, wrapDBError("ServerMember", "WarmCache", "discord_server_id", serverID, err)
+  }
+
+  // Fill the cache with a bounded number of members at a time.
+  client := net.RedisGetClient(ServerMemberClientName)
+  prefix := ServerMemberClientName+":"+ServerMemberDBName+":"+ServerMemberColName+":"
+  group, groupCtx := errgroup.WithContext(ctx)
+  group.SetLimit(CacheWarmConcurrency)
+  for _, member := range members {
+    member := member
+    group.Go(func() error {
+      if err := groupCtx.Err(); err != nil {
+        return err
+      }
+      fillCacheServerMember(client, prefix+"discord_member_id:"+member.DiscordMemberID, member)
+      fillCacheServerMember(client, prefix+"discord_user_id:"+string(member.DiscordUserID), member)
+      return nil
+    })
+  }
+  if err = group.Wait(); err != nil {
+    return 0, err
+  }
+
+  log.Info().Int("loaded", len(members)).Dur("took", time.Since(start)).Msgf("Warmed ServerMember cache for Server %s", serverID)
+  return len(members), nil
+}
+
+// WarmCacheAll warms the cache for every Discord server with ServerMembers, one server at a time. A
+// failure for one server doesn't stop the others, and every failure is returned together.
+func WarmCacheAll(ctx context.Context) error {
+
+  start := time.Now()
+  serverIDs := []string{}
+  err := mgoDo(ctx, ServerMemberClientName, ServerMemberDBName, ServerMemberColName, func(col *mgo.Collection) error {
+    return col.Find(bson.M{"deleted_at": nil}).Distinct("discord_server_id", &serverIDs)
+  })
+  if err != nil {
+    return wrapDBError("ServerMember", "WarmCache", "", "", err)
+  }
+
+  total := 0
+  failures := []string{}
+  for _, serverID := range serverIDs {
+    if ctx.Err() != nil {
+      return ctx.Err()
+    }
+    loaded, err := warmCacheForServer(ctx, serverID)
+    if err != nil {
+      log.Error().AnErr("warmCache", err).Msgf("Error warming ServerMember cache for Server %s", serverID)
+      failures = append(failures, serverID+": "+err.Error())
+      continue
+    }
+    total += loaded
+  }
+
+  log.Info().Int("servers", len(serverIDs)).Int("loaded", total).Dur("took", time.Since(start)).Msg("Warmed ServerMember cache")
+  if len(failures) > 0 {
+    return errors.New("error warming cache: " + strings.Join(failures, "; "))
+  }
+  return nil
+}
@@ -0,0 +1,698 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "encoding/json"
+  "errors"
+  "reflect"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// WarningClientName is the name of the MgoDriver to use for Warning.
+const WarningClientName = "main"
+
+// WarningDBName is the name of the database to use for Warning.
+const WarningDBName = "badpetbot"
+
+// WarningColName is the name of the collection to use for Warning.
+const WarningColName = "warnings"
+
+// WarningModelVersion is the current schema version of Warning documents, stored in their ModelVersion.
+const WarningModelVersion = 1
+
+// WarningCol gets a collection reference for Warning.
+func WarningCol() *mgo.Collection {
+  return net.MgoCol(WarningClientName, WarningDBName, WarningColName)
+}
+
+// Register Warning, so tooling can find it.
+func init() {
+  RegisterModel("Warning", WarningCol, func() interface{} { return new(Warning) })
+}
+
+// EnsureWarningIndices creates the indices listed under INDICES if they don't exist yet. It is idempotent.
+func EnsureWarningIndices() error {
+
+  col := WarningCol()
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"server_member_id"}, Background: true}); err != nil {
+    return wrapDBError("Warning", "EnsureIndices", "", "", err)
+  }
+  if err := col.EnsureIndex(mgo.Index{Key: []string{"expires_at"}, Background: true}); err != nil {
+    return wrapDBError("Warning", "EnsureIndices", "", "", err)
+  }
+  return nil
+}
+
+// INDICES:
+// { _id: 1 }
+// { server_member_id: 1 }
+// { expires_at: 1 }
+
+// Warning is a moderation strike issued against a ServerMember. Warnings accumulate by weight until
+// they expire, and can trigger automatic escalation.
+type Warning struct {
+  // ID is a BSON ID generated in Create.
+  ID                bson.ObjectId   `bson:"_id"                   json:"_id"                    validate:"required"`
+  ServerMemberID    bson.ObjectId   `bson:"server_member_id"      json:"server_member_id"       validate:"required"`
+  DiscordServerID   string          `bson:"discord_server_id"     json:"discord_server_id"      validate:"required,discord_id"`
+  IssuedByDiscordID string          `bson:"issued_by_discord_id"  json:"issued_by_discord_id"   validate:"required,discord_id"`
+  Reason            string          `bson:"reason"                json:"reason"                 validate:"-"`
+  Weight            int             `bson:"weight"                json:"weight"                 validate:"gte=1"`
+  // ExpiresAt is when the warning stops counting. Nil means it never expires.
+  ExpiresAt         *time.Time      `bson:"expires_at"            json:"expires_at"             validate:"-"`
+  CreatedAt         time.Time       `bson:"created_at"            json:"created_at"             validate:"required"`
+  UpdatedAt         time.Time       `bson:"updated_at"            json:"updated_at"             validate:"required"`
+  DeletedAt         *time.Time      `bson:"deleted_at"            json:"deleted_at"             validate:"-"`
+  Version           int             `bson:"version"               json:"version"                validate:"-"`
+  ModelVersion      int             `bson:"model_version"         json:"model_version"          validate:"-"`
+}
+
+// Create persists the document in the database. It runs validations and prevents persistence if they
+// do not pass.
+// Options like WithWriteConcern adjust how the insert runs.
+func (this *Warning) Create(ctx context.Context, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Warning.Create", WarningDBName, WarningColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Warning", "Create", time.Now(), &err)
+  defer logSlowQuery("Warning", "Create", nil)()
+
+  // Ensure ID, timestamps, and tokens.
+  this.ID = bson.NewObjectId()
+  now := clockNow()
+  this.CreatedAt = now
+  this.UpdatedAt = now
+  this.Version = 1
+  this.ModelVersion = WarningModelVersion
+
+  // Ensure defaults.
+  if this.Weight == 0 {
+    this.Weight = 1
+  }
+
+  // Run validations and return if they fail.
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the Warning.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, WarningClientName, WarningDBName, WarningColName, opts, func(col *mgo.Collection) error {
+      return col.Insert(this)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+  return wrapDBError("Warning", "Create", "_id", this.ID.Hex(), err)
+}
+
+// Update updates the document in the database. Important note, this function does NOT prepend
+// the provided updates with "$set" or any other operator. The document's Version is incremented.
+// Options like WithWriteConcern adjust how the update runs.
+func (this *Warning) Update(ctx context.Context, updates bson.M, opts ...QueryOption) error {
+
+  return this.update(ctx, bson.M{"_id": this.ID}, updates, opts...)
+}
+
+// UpdateWithVersion is like Update, but only applies the updates if the stored document's Version is
+// still expectedVersion, returning ErrVersionConflict otherwise.
+func (this *Warning) UpdateWithVersion(updates bson.M, expectedVersion int) error {
+
+  err := this.update(context.Background(), bson.M{"_id": this.ID, "version": versionSelector(expectedVersion)}, updates)
+  if errors.Is(err, mgo.ErrNotFound) {
+    return ErrVersionConflict
+  }
+  return err
+}
+
+// update applies the updates to the document matching the selector, setting updated-at and
+// incrementing the version.
+func (this *Warning) update(ctx context.Context, selector, updates bson.M, opts ...QueryOption) (err error) {
+
+  ctx, span := startSpan(ctx, "Warning.Update", WarningDBName, WarningColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Warning", "Update", time.Now(), &err)
+  defer logSlowQuery("Warning", "Update", selector)()
+
+  // Update updated-at timestamp and version. The timestamp is restored if the updates aren't persisted,
+  // which is whenever the version isn't incremented.
+  previousUpdatedAt, previousVersion := this.UpdatedAt, this.Version
+  this.UpdatedAt = clockNow()
+  defer func() {
+    if this.Version == previousVersion {
+      this.UpdatedAt = previousUpdatedAt
+    }
+  }()
+  _, setting := updates["$set"]
+  if !setting {
+    updates["$set"] = bson.M{}
+  }
+  updates["$set"].(bson.M)["updated_at"] = this.UpdatedAt
+  _, incrementing := updates["$inc"]
+  if !incrementing {
+    updates["$inc"] = bson.M{}
+  }
+  updates["$inc"].(bson.M)["version"] = 1
+
+  if err := this.Validate(ctx); err != nil {
+    return err
+  }
+
+  // Persist the updates.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDoWithOptions(ctx, WarningClientName, WarningDBName, WarningColName, opts, func(col *mgo.Collection) error {
+      return col.Update(selector, updates)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Note the new version and evict stale cache entries.
+  if err == nil {
+    this.Version++
+    go invalidateCacheWarning(net.RedisGetClient(WarningClientName), this.cacheKeys())
+  }
+  return wrapDBError("Warning", "Update", "_id", this.ID.Hex(), err)
+}
+
+// Delete permanently removes the document from the database.
+func (this *Warning) Delete(ctx context.Context) (err error) {
+
+  ctx, span := startSpan(ctx, "Warning.Delete", WarningDBName, WarningColName)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Warning", "Delete", time.Now(), &err)
+  defer logSlowQuery("Warning", "Delete", bson.M{"_id": this.ID})()
+
+  // Delete the Warning.
+  retry := getConfig().Retry
+  err = withRetry(ctx, func() error {
+    return mgoDo(ctx, WarningClientName, WarningDBName, WarningColName, func(col *mgo.Collection) error {
+      return col.RemoveId(this.ID)
+    })
+  }, retry.MaxAttempts, retry.BaseDelay)
+
+  // Evict stale cache entries.
+  if err == nil {
+    go invalidateCacheWarning(net.RedisGetClient(WarningClientName), this.cacheKeys())
+  }
+  return wrapDBError("Warning", "Delete", "_id", this.ID.Hex(), err)
+}
+
+// SoftDelete marks the document as deleted without removing it from the database. Soft-deleted
+// documents are excluded from queries unless explicitly included.
+func (this *Warning) SoftDelete() error {
+
+  now := clockNow()
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": now}}); err != nil {
+    return err
+  }
+  this.DeletedAt = &now
+  return nil
+}
+
+// Restore clears a previous soft-delete, making the document visible to queries again.
+func (this *Warning) Restore() error {
+
+  if err := this.Update(context.Background(), bson.M{"$set": bson.M{"deleted_at": nil}}); err != nil {
+    return err
+  }
+  this.DeletedAt = nil
+  return nil
+}
+
+// MarkUpdatedNow sets only the document's UpdatedAt to now, e.g. to keep it from being considered stale.
+// Nothing else changed, so it isn't validated and its Version isn't incremented. Cache entries are evicted.
+func (this *Warning) MarkUpdatedNow(ctx context.Context) (err error) {
+
+  defer observeOperation("Warning", "MarkUpdatedNow", time.Now(), &err)
+
+  now := clockNow()
+  err = mgoDo(ctx, WarningClientName, WarningDBName, WarningColName, func(col *mgo.Collection) error {
+    return col.UpdateId(this.ID, bson.M{"$set": bson.M{"updated_at": now}})
+  })
+  if err != nil {
+    return wrapDBError("Warning", "MarkUpdatedNow", "_id", this.ID.Hex(), err)
+  }
+  this.UpdatedAt = now
+  go invalidateCacheWarning(net.RedisGetClient(WarningClientName), this.cacheKeys())
+  return nil
+}
+
+// Validate runs validations against the model's fields.
+func (this *Warning) Validate(ctx context.Context) error {
+
+  return wrapValidationError("Warning", newValidator().StructCtx(ctx, this))
+}
+
+// ValidatePartial runs validations against only the given fields, named by Go field name or bson field
+// name, so documents being partially updated don't fail on fields which aren't set.
+func (this *Warning) ValidatePartial(fields ...string) error {
+
+  names, err := partialFieldNames("Warning", this, fields)
+  if err != nil {
+    return err
+  }
+  return wrapValidationError("Warning", newValidator().StructPartialCtx(context.Background(), this, names...))
+}
+
+// Clone returns a deep copy of the document, sharing no slices or pointers with it, with ID and
+// timestamps zeroed so it can be created as a new document.
+func (this *Warning) Clone() *Warning {
+
+  clone := deepCopy(reflect.ValueOf(this)).Interface().(*Warning)
+  clone.ID = ""
+  clone.CreatedAt = time.Time{}
+  clone.UpdatedAt = time.Time{}
+  return clone
+}
+
+// Diff returns other's value for every field which differs from this document's, keyed by bson field
+// name. Embeddables are skipped.
+func (this *Warning) Diff(other *Warning) bson.M {
+
+  return diffFields(this, other, false)
+}
+
+// DiffDeep is like Diff, but also compares embeddables, using dotted keys for their fields.
+func (this *Warning) DiffDeep(other *Warning) bson.M {
+
+  return diffFields(this, other, true)
+}
+
+// ToFlatMap flattens the document into a single-level map keyed by bson field name, e.g. "weight". A nil
+// ExpiresAt produces no key.
+func (this *Warning) ToFlatMap() map[string]interface{} {
+
+  return toFlatMap(this)
+}
+
+// FromFlatMap populates the document from a map in the form produced by ToFlatMap. Fields without a
+// key in the map are left as they are.
+func (this *Warning) FromFlatMap(m map[string]interface{}) error {
+
+  return fromFlatMap(this, m)
+}
+
+// SerializeForAPI encodes the document as JSON for API responses, with ObjectIds as hex strings and
+// times as RFC3339. Embeddables which weren't loaded and fields tagged json_api:"-" are left out.
+func (this *Warning) SerializeForAPI() ([]byte, error) {
+
+  return serializeForAPI(this)
+}
+
+// DeserializeFromAPI populates the document from an API payload in the form produced by SerializeForAPI,
+// then runs validations against it.
+func (this *Warning) DeserializeFromAPI(b []byte) error {
+
+  if err := deserializeFromAPI(this, b); err != nil {
+    return err
+  }
+  return this.Validate(context.Background())
+}
+
+// CSVHeaders returns the document's CSV column names, its bson field names, in a stable order.
+func (this *Warning) CSVHeaders() []string {
+
+  return csvHeaders(this)
+}
+
+// ToCSVRow returns the document's values in the same order as CSVHeaders.
+func (this *Warning) ToCSVRow() []string {
+
+  return toCSVRow(this)
+}
+
+// IsZero reports whether the Warning is uninitialized, having neither an ID nor a ServerMemberID, e.g. after a
+// partially failed deserialization. Check for nil first: doc == nil || doc.IsZero().
+func (this *Warning) IsZero() bool {
+  return this.ID == "" && this.ServerMemberID == ""
+}
+
+// Age returns how long ago the Warning was created.
+func (this *Warning) Age() time.Duration {
+  return clockNow().Sub(this.CreatedAt)
+}
+
+// TimeSinceUpdate returns how long ago the Warning was last updated.
+func (this *Warning) TimeSinceUpdate() time.Duration {
+  return clockNow().Sub(this.UpdatedAt)
+}
+
+// IsStale reports whether the Warning hasn't been updated for longer than the threshold.
+func (this *Warning) IsStale(threshold time.Duration) bool {
+  return this.TimeSinceUpdate() > threshold
+}
+
+// Cache functions.
+
+// CacheGetWarning attempts to find a Warning by the key and value specified in cache before looking
+// in the database and setting cache if found. If "negCache" is true, will check for neg-cache
+// first, and also set neg-cache if the document wasn't found in the database either.
+func CacheGetWarning(key, value string, negCache bool) (found *Warning, err error) {
+
+  client := net.RedisGetClient(WarningClientName)
+  cacheKey := WarningClientName+":"+WarningDBName+":"+WarningColName+":"+key+":"+value
+  _, span := startCacheSpan(context.Background(), "Warning.CacheGet", WarningDBName, WarningColName, cacheKey)
+  defer func() { endSpan(span, err) }()
+  defer observeOperation("Warning", "CacheGet", time.Now(), &err)
+
+  // Return not-found early if neg-cache exists.
+  if negCache {
+    if result, err := client.Get("neg:"+cacheKey).Result(); err != nil && err != redis.Nil {
+      return nil, wrapDBError("Warning", "CacheGet", key, value, err)
+    } else if result != "" {
+      recordCacheResult("Warning", key, "neg_hit")
+      return nil, &ModelNotFoundError{Model: "Warning", Key: key, Value: value}
+    }
+  }
+
+  // Return what's in cache if it's found.
+  if result, err := client.Get(cacheKey).Result(); err != nil && err != redis.Nil {
+    return nil, wrapDBError("Warning", "CacheGet", key, value, err)
+  } else if result != "" {
+    recordCacheResult("Warning", key, "hit")
+    go refreshCacheTTL(client, cacheKey, getConfig().DefaultCacheTTL)
+    warning := new(Warning)
+    if err := json.Unmarshal([]byte(result), warning); err != nil {
+      return nil, wrapDBError("Warning", "CacheGet", key, value, err)
+    }
+    return warning, nil
+  }
+
+  // Get what's in the database.
+  recordCacheResult("Warning", key, "miss")
+  warning := new(Warning)
+  err = net.MgoCol(WarningClientName, WarningDBName, WarningColName).Find(notDeleted(bson.M{
+    key: bsonQueryValue(key, value),
+  })).One(warning)
+
+  // If it wasn't found and negCache is true, fill neg cache.
+  if err == mgo.ErrNotFound && negCache {
+    go fillNegCacheWarning(client, cacheKey)
+
+  // Else if there's no error, fill cache.
+  } else if err == nil {
+    go fillCacheWarning(client, cacheKey, warning)
+  }
+  if err != nil {
+    return nil, wrapDBError("Warning", "CacheGet", key, value, err)
+  }
+  return warning, nil
+}
+
+func fillCacheWarning(client *redis.Client, key string, value *Warning) {
+  _, span := startCacheSpan(context.Background(), "Warning.fillCache", WarningDBName, WarningColName, key)
+  serialized, err := json.Marshal(value)
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error serializing cache for Warning")
+    endSpan(span, err)
+    return
+  }
+  err = client.Set(key, string(serialized), getConfig().DefaultCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillCache", err).Msgf("Error filling cache for Warning")
+  }
+  endSpan(span, err)
+}
+
+func fillNegCacheWarning(client *redis.Client, key string) {
+  _, span := startCacheSpan(context.Background(), "Warning.fillNegCache", WarningDBName, WarningColName, "neg:"+key)
+  err := client.Set("neg:"+key, "neg", getConfig().DefaultNegCacheTTL).Err()
+  if err != nil {
+    log.Warn().AnErr("fillNegCache", err).Msgf("Error filling neg cache for Warning")
+  }
+  endSpan(span, err)
+}
+
+func invalidateCacheWarning(client *redis.Client, keys []string) {
+  if err := client.Del(keys...).Err(); err != nil {
+    log.Warn().AnErr("invalidateCache", err).Msgf("Error invalidating cache for Warning")
+  }
+}
+
+// cacheKeys returns every cache key the document can be cached under by CacheGetWarning.
+func (this *Warning) cacheKeys() []string {
+  prefix := WarningClientName+":"+WarningDBName+":"+WarningColName+":"
+  return []string{
+    prefix+"_id:"+this.ID.Hex(),
+  }
+}
+
+// Misc functions.
+
+// AggregateWarnings runs the aggregation pipeline on the Warning collection, unmarshalling every result
+// into result, which must be a pointer to a slice. Soft-deleted documents are not filtered out, so
+// pipelines should start with their own $match if that matters.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateWarnings(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Warning", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(WarningClientName, WarningDBName, WarningColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Warning", "Aggregate", "", "", pipe.All(result))
+}
+
+// AggregateWarningOne runs the aggregation pipeline on the Warning collection, unmarshalling the first
+// result into result. Returns a ModelNotFoundError if there were no results.
+// Options like WithMaxTime adjust how the pipeline runs.
+func AggregateWarningOne(pipeline []bson.M, result interface{}, opts ...QueryOption) (err error) {
+
+  defer observeOperation("Warning", "Aggregate", time.Now(), &err)
+
+  pipe, done := optionsPipe(WarningClientName, WarningDBName, WarningColName, pipeline, opts)
+  defer done()
+  return wrapDBError("Warning", "Aggregate", "", "", pipe.One(result))
+}
+
+// FindAndModifyWarning atomically applies the update to the first Warning matching the selector and returns
+// it, as it was after the update if returnNew is true, or before it otherwise. Like Update, updated-at
+// is set and the version is incremented. Returns a ModelNotFoundError if nothing matched.
+func FindAndModifyWarning(selector, update bson.M, returnNew bool) (_ *Warning, err error) {
+
+  defer observeOperation("Warning", "FindAndModify", time.Now(), &err)
+
+  // Set updated-at and increment the version.
+  if _, setting := update["$set"]; !setting {
+    update["$set"] = bson.M{}
+  }
+  update["$set"].(bson.M)["updated_at"] = clockNow()
+  if _, incrementing := update["$inc"]; !incrementing {
+    update["$inc"] = bson.M{}
+  }
+  update["$inc"].(bson.M)["version"] = 1
+
+  // Apply the update, then evict stale cache entries.
+  result := new(Warning)
+  _, err = WarningCol().Find(selector).Apply(mgo.Change{Update: update, ReturnNew: returnNew}, result)
+  if err != nil {
+    return nil, wrapDBError("Warning", "FindAndModify", "", "", err)
+  }
+  go invalidateCacheWarning(net.RedisGetClient(WarningClientName), result.cacheKeys())
+  return result, nil
+}
+
+// FindWarnings finds all Warnings matching the filter, excluding soft-deleted ones. An empty sort leaves
+// the default order, and a limit of 0 means no limit. This does not touch the cache.
+// Options like WithReadPreference and WithMaxTime adjust how the query runs.
+func FindWarnings(ctx context.Context, filter bson.M, sort string, limit int, opts ...QueryOption) (_ []*Warning, err error) {
+
+  defer observeOperation("Warning", "Find", time.Now(), &err)
+  defer logSlowQuery("Warning", "Find", filter)()
+
+  results := []*Warning{}
+  maxTime := applyQueryOptions(opts).maxQueryTime()
+  err = mgoDoWithOptions(ctx, WarningClientName, WarningDBName, WarningColName, opts, func(col *mgo.Collection) error {
+    query := col.Find(notDeleted(filter)).SetMaxTime(maxTime)
+    if sort != "" {
+      query = query.Sort(sort)
+    }
+    if limit > 0 {
+      query = query.Limit(limit)
+    }
+    return query.All(&results)
+  })
+  if err != nil {
+    return nil, wrapDBError("Warning", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// FindWarningsWithDeleted finds all Warnings matching the filter, including soft-deleted ones.
+func FindWarningsWithDeleted(filter bson.M) (_ []*Warning, err error) {
+
+  defer observeOperation("Warning", "Find", time.Now(), &err)
+
+  results := []*Warning{}
+  if err := net.MgoCol(WarningClientName, WarningDBName, WarningColName).Find(filter).All(&results); err != nil {
+    return nil, wrapDBError("Warning", "Find", "", "", err)
+  }
+  return results, nil
+}
+
+// CountWarnings counts the Warnings matching the filter, excluding soft-deleted ones. This does not touch
+// the cache.
+func CountWarnings(filter bson.M, opts ...QueryOption) (_ int, err error) {
+
+  defer observeOperation("Warning", "Count", time.Now(), &err)
+
+  query, done := optionsQuery(WarningClientName, WarningDBName, WarningColName, notDeleted(filter), opts)
+  defer done()
+  count, err := query.Count()
+  if err != nil {
+    return 0, wrapDBError("Warning", "Count", "", "", err)
+  }
+  return count, nil
+}
+
+// WarningExists reports whether any Warning matches the filter, excluding soft-deleted ones.
+func WarningExists(filter bson.M) (bool, error) {
+
+  count, err := CountWarnings(filter)
+  return count > 0, err
+}
+
+// DistinctWarningFields finds the distinct values of the field among the Warnings matching the filter, excluding
+// soft-deleted ones. This does not touch the cache.
+func DistinctWarningFields(ctx context.Context, field string, filter bson.M) (_ []interface{}, err error) {
+
+  defer observeOperation("Warning", "Distinct", time.Now(), &err)
+
+  values, err := distinctValues(ctx, WarningClientName, WarningDBName, WarningColName, field, notDeleted(filter))
+  if err != nil {
+    return nil, wrapDBError("Warning", "Distinct", "", "", err)
+  }
+  return values, nil
+}
+
+// DistinctWarningStringFields is DistinctWarningFields for string fields, like reason. It fails if
+// any of the values isn't a string.
+func DistinctWarningStringFields(ctx context.Context, field string, filter bson.M) ([]string, error) {
+
+  values, err := DistinctWarningFields(ctx, field, filter)
+  if err != nil {
+    return nil, err
+  }
+  return distinctStrings(field, values)
+}
+
+// CompactWarningCollection runs the compact command against the Warning collection, defragmenting it. It
+// blocks the collection while it runs, so it's refused unless Config.AdminCommandsEnabled is set.
+func CompactWarningCollection(ctx context.Context) error {
+
+  return compactCollection(ctx, "Warning", WarningClientName, WarningDBName, WarningColName)
+}
+
+// WarningCollectionStats returns the Warning collection's size and storage statistics. It's refused unless
+// Config.AdminCommandsEnabled is set.
+func WarningCollectionStats(ctx context.Context) (*CollStats, error) {
+
+  return collectionStats(ctx, "Warning", WarningClientName, WarningDBName, WarningColName)
+}
+
+// ExplainWarningQuery returns MongoDB's explain output for finding the filter in the Warning collection, e.g.
+// to pass to WinningPlan. It's refused unless Config.DebugMode is set.
+func ExplainWarningQuery(ctx context.Context, filter bson.M) (bson.M, error) {
+
+  return explainQuery(ctx, "Warning", WarningClientName, WarningDBName, WarningColName, filter)
+}
+
+// UpdateManyWarnings applies the updates to every Warning matching the filter, excluding soft-deleted ones, in
+// a single query, returning how many were updated. Like Update, the updates need their own operators,
+// and updated-at and the version are set too. Documents aren't validated, since that would mean
+// fetching each of them; see BulkUpdateWarnings for that. The Warning cache is flushed.
+func UpdateManyWarnings(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return updateMany(ctx, "Warning", WarningClientName, WarningDBName, WarningColName, filter, updates)
+}
+
+// BulkUpdateWarnings is UpdateManyWarnings, but validates each Warning as it would be once updated, skipping
+// those which wouldn't pass. It fetches and updates documents one at a time, so it's much slower. Only
+// the $set, $unset, and $inc operators are supported. Every failure is returned together.
+func BulkUpdateWarnings(ctx context.Context, filter, updates bson.M) (int, error) {
+
+  return bulkUpdate(ctx, "Warning", WarningClientName, WarningDBName, WarningColName, filter, updates, func() validatable { return new(Warning) })
+}
+
+// DeleteManyWarnings soft-deletes every Warning matching the filter in a single query, returning how many
+// were deleted. The Warning cache is evicted.
+func DeleteManyWarnings(ctx context.Context, filter bson.M) (int, error) {
+
+  return softDeleteMany(ctx, "Warning", WarningClientName, WarningDBName, WarningColName, filter)
+}
+
+// activeWarningsFilter matches the ServerMember's warnings which haven't expired.
+func activeWarningsFilter(serverMemberID bson.ObjectId) bson.M {
+  return bson.M{
+    "server_member_id": serverMemberID,
+    "$or": []bson.M{
+      {"expires_at": nil},
+      {"expires_at": bson.M{"$gt": clockNow()}},
+    },
+  }
+}
+
+// FindActiveWarningsByMember finds every warning against the ServerMember which hasn't expired.
+func FindActiveWarningsByMember(serverMemberID bson.ObjectId) ([]*Warning, error) {
+
+  return FindWarnings(context.Background(), activeWarningsFilter(serverMemberID), "created_at", 0)
+}
+
+// TotalWeight sums the weight of every warning against the ServerMember which hasn't expired.
+func TotalWeight(serverMemberID bson.ObjectId) (total int, err error) {
+
+  defer observeOperation("Warning", "TotalWeight", time.Now(), &err)
+
+  result := struct {
+    Total int `bson:"total"`
+  }{}
+  err = WarningCol().Pipe([]bson.M{
+    {"$match": notDeleted(activeWarningsFilter(serverMemberID))},
+    {"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$weight"}}},
+  }).One(&result)
+  if err == mgo.ErrNotFound {
+    return 0, nil
+  } else if err != nil {
+    return 0, wrapDBError("Warning", "TotalWeight", "server_member_id", serverMemberID.Hex(), err)
+  }
+  return result.Total, nil
+}
+
+// ExpireWarnings permanently removes every warning which has expired, returning how many were removed.
+func ExpireWarnings() (removed int, err error) {
+
+  defer observeOperation("Warning", "Expire", time.Now(), &err)
+
+  // Find the expired warnings first, so their cache entries can be evicted.
+  col := WarningCol()
+  expired := []*Warning{}
+  if err = col.Find(bson.M{"expires_at": bson.M{"$lte": clockNow()}}).Select(bson.M{"_id": 1}).All(&expired); err != nil {
+    return 0, wrapDBError("Warning", "Expire", "", "", err)
+  }
+  if len(expired) == 0 {
+    return 0, nil
+  }
+  ids := make([]bson.ObjectId, len(expired))
+  keys := []string{}
+  for i, warning := range expired {
+    ids[i] = warning.ID
+    keys = append(keys, warning.cacheKeys()...)
+  }
+
+  // Remove them and evict stale cache entries.
+  info, err := col.RemoveAll(bson.M{"_id": bson.M{"$in": ids}})
+  if err != nil {
+    return 0, wrapDBError("Warning", "Expire", "", "", err)
+  }
+  go invalidateCacheWarning(net.RedisGetClient(WarningClientName), keys)
+  return info.Removed, nil
+}
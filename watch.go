@@ -0,0 +1,205 @@
+package gomodel
+
+import (
+
+  // Import builtin packages.
+  "context"
+  "errors"
+  "sync"
+  "time"
+
+  // Import 3rd party packages.
+  "github.com/globalsign/mgo"
+  "github.com/globalsign/mgo/bson"
+  "github.com/go-redis/redis"
+  "github.com/rs/zerolog/log"
+
+  // Import internal packages.
+  "github.com/badpetbot/gocommon/net"
+)
+
+// watchMaxAwait is how long a change stream waits for events before checking whether its context is done.
+const watchMaxAwait = time.Second
+
+// watchMaxReconnectDelay caps the backoff between change stream reconnect attempts.
+const watchMaxReconnectDelay = 30*time.Second
+
+// watchHeartbeatTimeout is how long a change stream can go without a heartbeat before it's reported as
+// dead. Streams beat at least every watchMaxAwait while they're open.
+const watchHeartbeatTimeout = 10*watchMaxAwait
+
+// ErrWatcherRunning is returned when starting a watcher which is already running.
+var ErrWatcherRunning = errors.New("change stream watcher is already running")
+
+// watcher tracks a running change stream, so its health can be reported and it can be stopped. Only one
+// stream can run per watcher at a time.
+type watcher struct {
+  mu        sync.Mutex
+  running   bool
+  heartbeat time.Time
+  lastEvent time.Time
+  cancel    context.CancelFunc
+  done      chan struct{}
+}
+
+// start marks the watcher as running, returning the context to watch with, which stop cancels, and the
+// func to call once watching has stopped.
+func (this *watcher) start(ctx context.Context) (context.Context, func(), error) {
+
+  this.mu.Lock()
+  defer this.mu.Unlock()
+  if this.running {
+    return nil, nil, ErrWatcherRunning
+  }
+  ctx, cancel := context.WithCancel(ctx)
+  done := make(chan struct{})
+  this.running, this.heartbeat, this.lastEvent, this.cancel, this.done = true, clockNow(), time.Time{}, cancel, done
+
+  return ctx, func() {
+    this.mu.Lock()
+    this.running = false
+    this.mu.Unlock()
+    cancel()
+    close(done)
+  }, nil
+}
+
+// beat notes that the stream is alive. It's a no-op on a nil watcher.
+func (this *watcher) beat() {
+
+  if this == nil {
+    return
+  }
+  this.mu.Lock()
+  this.heartbeat = clockNow()
+  this.mu.Unlock()
+}
+
+// received notes that the stream handled an event. It's a no-op on a nil watcher.
+func (this *watcher) received() {
+
+  if this == nil {
+    return
+  }
+  now := clockNow()
+  this.mu.Lock()
+  this.heartbeat, this.lastEvent = now, now
+  this.mu.Unlock()
+}
+
+// health reports whether the stream is running and has beaten within watchHeartbeatTimeout, and when it
+// last handled an event.
+func (this *watcher) health() (bool, time.Time) {
+
+  this.mu.Lock()
+  defer this.mu.Unlock()
+  return this.running && clockNow().Sub(this.heartbeat) < watchHeartbeatTimeout, this.lastEvent
+}
+
+// stop cancels the running stream and waits for it to return, or for ctx to be done. It's a no-op if
+// no stream is running.
+func (this *watcher) stop(ctx context.Context) error {
+
+  this.mu.Lock()
+  running, cancel, done := this.running, this.cancel, this.done
+  this.mu.Unlock()
+  if !running {
+    return nil
+  }
+
+  cancel()
+  select {
+  case <-done:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
+
+// watchCollection opens a change stream on the collection with the pipeline and calls handle with each
+// raw event until ctx is done, returning nil then. The resume token is persisted to Redis after every
+// handled event, so a restarted watcher continues where the last one left off. If the stream fails,
+// it's reopened from the last token with a growing backoff, logging each attempt. w, if not nil, is kept
+// up to date with the stream's health.
+func watchCollection(ctx context.Context, w *watcher, model, client, database, collection string, pipeline []bson.M, handle func(event bson.Raw) error) error {
+
+  redisClient := net.RedisGetClient(client)
+  tokenKey := client+":"+database+":"+collection+":resume_token"
+  delay := getConfig().Retry.BaseDelay
+  for attempt := 1; ; attempt++ {
+
+    // Open the stream, resuming from the persisted token if there is one.
+    err := watchOnce(ctx, w, redisClient, tokenKey, client, database, collection, pipeline, handle, func() {
+      attempt = 0
+      delay = getConfig().Retry.BaseDelay
+    })
+    if ctx.Err() != nil {
+      return nil
+    }
+
+    // Back off and reconnect.
+    log.Warn().AnErr("watch", err).Int("attempt", attempt).Dur("delay", delay).Msgf("Reconnecting %s change stream", model)
+    select {
+    case <-ctx.Done():
+      return nil
+    case <-time.After(delay):
+    }
+    if delay *= 2; delay > watchMaxReconnectDelay {
+      delay = watchMaxReconnectDelay
+    }
+  }
+}
+
+// watchOnce runs a single change stream until it fails or ctx is done. healthy is called after every
+// handled event, and w beats while the stream is open.
+func watchOnce(ctx context.Context, w *watcher, redisClient *redis.Client, tokenKey, client, database, collection string, pipeline []bson.M, handle func(event bson.Raw) error, healthy func()) error {
+
+  options := mgo.ChangeStreamOptions{FullDocument: mgo.UpdateLookup, MaxAwaitTimeMS: watchMaxAwait}
+  token, err := loadResumeToken(redisClient, tokenKey)
+  if err != nil {
+    return err
+  }
+  options.ResumeAfter = token
+
+  session := net.MgoGetSession(client).Copy()
+  defer session.Close()
+  stream, err := session.DB(database).C(collection).Watch(pipeline, options)
+  if err != nil {
+    return err
+  }
+  defer stream.Close()
+
+  for ctx.Err() == nil {
+    w.beat()
+    event := bson.Raw{}
+    if !stream.Next(&event) {
+      if err := stream.Err(); err != nil {
+        return err
+      }
+      continue
+    }
+    if err := handle(event); err != nil {
+      return err
+    }
+    if token := stream.ResumeToken(); token != nil {
+      if err := redisClient.Set(tokenKey, token.Data, 0).Err(); err != nil {
+        log.Warn().AnErr("watch", err).Msgf("Error persisting resume token for %s", collection)
+      }
+    }
+    w.received()
+    healthy()
+  }
+  return ctx.Err()
+}
+
+// loadResumeToken gets the persisted resume token, or nil if there isn't one.
+func loadResumeToken(redisClient *redis.Client, tokenKey string) (*bson.Raw, error) {
+
+  data, err := redisClient.Get(tokenKey).Bytes()
+  if err == redis.Nil {
+    return nil, nil
+  } else if err != nil {
+    return nil, err
+  }
+  return &bson.Raw{Kind: 0x03, Data: data}, nil
+}